@@ -0,0 +1,173 @@
+package accesscontrol
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// apiKeyCheckInterval limits the modification checks of the key file.
+const apiKeyCheckInterval = time.Second
+
+var (
+	ErrorAPIKeyInvalid = errors.New("invalid api key")
+
+	_ AccessControl = &APIKey{}
+)
+
+type apiKeyEntry struct {
+	hash []byte
+	data Claims
+}
+
+// APIKey validates static keys against a file of SHA-256 hashed keys. The file is a JSON list
+// of objects with the hex encoded "hash" and additional metadata like "owner" or "scopes",
+// it is reloaded on modification. The key header is removed from validated requests.
+type APIKey struct {
+	file      string
+	log       logrus.FieldLogger
+	name      string
+	source    Source
+	sourceKey string
+
+	mu        sync.RWMutex
+	keys      []apiKeyEntry
+	lastCheck time.Time
+	modTime   time.Time
+}
+
+func NewAPIKey(name, file string, src Source, srcKey string, log logrus.FieldLogger) (*APIKey, error) {
+	if file == "" {
+		return nil, errors.New("missing keys_file")
+	}
+
+	if src == Unknown {
+		return nil, ErrorUnknownSource
+	}
+
+	a := &APIKey{
+		file:      file,
+		log:       log,
+		name:      name,
+		source:    src,
+		sourceKey: srcKey,
+	}
+
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Validate reads the key from the configured source and compares its hash with all known keys.
+func (a *APIKey) Validate(req *http.Request) error {
+	key, err := getToken(req, a.source, a.sourceKey)
+	if err != nil {
+		return err
+	}
+
+	a.reload()
+
+	hash := sha256.Sum256([]byte(key))
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	// compare all entries to not leak the position of a matching key
+	var match *apiKeyEntry
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(hash[:], a.keys[i].hash) == 1 {
+			match = &a.keys[i]
+		}
+	}
+
+	if match == nil {
+		return ErrorAPIKeyInvalid
+	}
+
+	// the key is not meant for the backend, query parameters are already removed by getToken
+	if a.source == Header {
+		req.Header.Del(a.sourceKey)
+	}
+
+	setContextData(req, a.name, match.data)
+	return nil
+}
+
+// reload loads the key file on modification, the known keys are kept and the error is logged once per modification.
+func (a *APIKey) reload() {
+	a.mu.Lock()
+	if time.Since(a.lastCheck) < apiKeyCheckInterval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastCheck = time.Now()
+	modTime := a.modTime
+	a.mu.Unlock()
+
+	info, err := os.Stat(a.file)
+	if err != nil || info.ModTime().Equal(modTime) {
+		return
+	}
+
+	if err = a.load(); err != nil {
+		a.mu.Lock()
+		a.modTime = info.ModTime()
+		a.mu.Unlock()
+
+		if a.log != nil {
+			a.log.WithField("api_key", a.name).Errorf("reloading keys_file failed: %v", err)
+		}
+	}
+}
+
+func (a *APIKey) load() error {
+	info, err := os.Stat(a.file)
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(a.file)
+	if err != nil {
+		return err
+	}
+
+	var list []map[string]interface{}
+	if err = json.Unmarshal(b, &list); err != nil {
+		return fmt.Errorf("%s: %v", a.file, err)
+	}
+
+	keys := make([]apiKeyEntry, 0, len(list))
+	for i, item := range list {
+		h, _ := item["hash"].(string)
+		hash, err := hex.DecodeString(strings.TrimPrefix(h, "sha256:"))
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("%s: entry %d: hash must be a hex encoded sha256 sum", a.file, i)
+		}
+
+		data := make(Claims, len(item))
+		for k, v := range item {
+			if k != "hash" {
+				data[k] = v
+			}
+		}
+		keys = append(keys, apiKeyEntry{hash: hash, data: data})
+	}
+
+	a.mu.Lock()
+	a.keys = keys
+	a.modTime = info.ModTime()
+	a.mu.Unlock()
+	return nil
+}
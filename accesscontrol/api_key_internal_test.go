@@ -0,0 +1,84 @@
+package accesscontrol
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestAPIKey_Reload(t *testing.T) {
+	file, err := ioutil.TempFile("", "api_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	writeKeys := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(file.Name(), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file.Name(), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger, hook := logrustest.NewNullLogger()
+
+	oldHash, newHash := sha256.Sum256([]byte("old")), sha256.Sum256([]byte("new"))
+	writeKeys(`[{"hash":"`+hex.EncodeToString(oldHash[:])+`"}]`, time.Now().Add(-time.Hour))
+
+	a, err := NewAPIKey("key", file.Name(), Header, "X-API-Key", logger.WithContext(context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validate := func(key string) error {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", key)
+		return a.Validate(req)
+	}
+
+	if err = validate("old"); err != nil {
+		t.Fatal(err)
+	}
+
+	writeKeys(`[{"hash":"`+hex.EncodeToString(newHash[:])+`"}]`, time.Now())
+	a.lastCheck = time.Time{}
+
+	if err = validate("new"); err != nil {
+		t.Errorf("expected the reloaded key, got: %v", err)
+	}
+	if err = validate("old"); err != ErrorAPIKeyInvalid {
+		t.Errorf("expected the removed key to be invalid, got: %v", err)
+	}
+
+	// invalid files keep the known keys
+	writeKeys(`[{"hash":"invalid"}]`, time.Now().Add(time.Minute))
+	a.lastCheck = time.Time{}
+
+	if err = validate("new"); err != nil {
+		t.Errorf("expected the previous keys on an invalid file, got: %v", err)
+	}
+
+	if entries := hook.AllEntries(); len(entries) != 1 || entries[0].Level != logrus.ErrorLevel {
+		t.Errorf("expected one logged reload error, got: %v", entries)
+	}
+
+	// the error is logged once per modification
+	a.lastCheck = time.Time{}
+	if err = validate("new"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(hook.AllEntries()); n != 1 {
+		t.Errorf("expected one logged reload error, got: %d", n)
+	}
+}
@@ -0,0 +1,98 @@
+package accesscontrol_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	ac "github.com/avenga/couper/accesscontrol"
+)
+
+func TestAPIKey_Validate(t *testing.T) {
+	hash := sha256.Sum256([]byte("my-key"))
+	file := newKeysFile(t, `[
+  {"hash": "`+hex.EncodeToString(hash[:])+`", "owner": "billing", "scopes": ["invoices"]},
+  {"hash": "sha256:`+hex.EncodeToString(make([]byte, sha256.Size))+`", "owner": "other"}
+]`)
+	defer os.Remove(file)
+
+	headerKey, err := ac.NewAPIKey("key", file, ac.Header, "X-API-Key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queryKey, err := ac.NewAPIKey("key", file, ac.QueryParam, "api_key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ac      *ac.APIKey
+		header  string
+		url     string
+		wantErr error
+	}{
+		{"valid header", headerKey, "my-key", "/", nil},
+		{"invalid header", headerKey, "other-key", "/", ac.ErrorAPIKeyInvalid},
+		{"missing header", headerKey, "", "/", ac.ErrorEmptyToken},
+		{"valid query", queryKey, "", "/?api_key=my-key&a=b", nil},
+		{"invalid query", queryKey, "", "/?api_key=other-key", ac.ErrorAPIKeyInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set("X-API-Key", tt.header)
+			}
+
+			if err := tt.ac.Validate(req); err != tt.wantErr {
+				subT.Fatalf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if req.URL.Query().Get("api_key") != "" {
+				subT.Error("expected the api_key query parameter to be removed")
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if req.Header.Get("X-API-Key") != "" {
+				subT.Error("expected the X-API-Key header to be removed")
+			}
+
+			acMap, _ := req.Context().Value(ac.ContextAccessControlKey).(map[string]interface{})
+			data, _ := acMap["key"].(ac.Claims)
+			if data["owner"] != "billing" || data["hash"] != nil {
+				subT.Errorf("unexpected context data: %#v", data)
+			}
+		})
+	}
+
+	for _, content := range []string{`{}`, `[{"hash": "abc"}]`, `[{"owner": "me"}]`} {
+		invalid := newKeysFile(t, content)
+		if _, err = ac.NewAPIKey("key", invalid, ac.Header, "X-API-Key", nil); err == nil {
+			t.Errorf("expected an error for %s", content)
+		}
+		os.Remove(invalid)
+	}
+}
+
+func newKeysFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "api_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if _, err = file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
@@ -8,10 +8,17 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 var _ AccessControl = &BasicAuth{}
 
+// htFileCheckInterval limits the modification checks of the htpasswd file.
+const htFileCheckInterval = time.Second
+
 var (
 	ErrorBasicAuthMissingCredentials = errors.New("missing credentials")
 	ErrorBasicAuthNotConfigured      = errors.New("handler not configured")
@@ -54,16 +61,24 @@ func (e BasicAuthError) Error() string {
 
 // BasicAuth represents an AC-BasicAuth object
 type BasicAuth struct {
-	htFile htData
-	name   string
-	user   string
-	pass   string
-	realm  string
+	file    string
+	lockout *Lockout
+	log     logrus.FieldLogger
+	name    string
+	user    string
+	pass    string
+	realm   string
+
+	mu        sync.RWMutex
+	htFile    htData
+	lastCheck time.Time
+	modTime   time.Time
 }
 
 // NewBasicAuth creates a new AC-BasicAuth object
 func NewBasicAuth(name, user, pass, file, realm string) (*BasicAuth, error) {
 	ba := &BasicAuth{
+		file:   file,
 		htFile: make(htData),
 		name:   name,
 		user:   user,
@@ -75,12 +90,71 @@ func NewBasicAuth(name, user, pass, file, realm string) (*BasicAuth, error) {
 		return ba, nil
 	}
 
+	if err := ba.load(); err != nil {
+		return nil, err
+	}
+	return ba, nil
+}
+
+// load parses the htpasswd file and replaces the current data on success.
+func (ba *BasicAuth) load() error {
+	data, err := parseHTFile(ba.file)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(ba.file)
+	if err != nil {
+		return err
+	}
+
+	ba.mu.Lock()
+	ba.htFile = data
+	ba.modTime = info.ModTime()
+	ba.mu.Unlock()
+	return nil
+}
+
+// reload loads a modified htpasswd file, the previous data is kept if the file cannot be parsed
+// and the error is logged once per modification.
+func (ba *BasicAuth) reload() {
+	if ba.file == "" {
+		return
+	}
+
+	ba.mu.Lock()
+	if time.Since(ba.lastCheck) < htFileCheckInterval {
+		ba.mu.Unlock()
+		return
+	}
+	ba.lastCheck = time.Now()
+	modTime := ba.modTime
+	ba.mu.Unlock()
+
+	info, err := os.Stat(ba.file)
+	if err != nil || info.ModTime().Equal(modTime) {
+		return
+	}
+
+	if err = ba.load(); err != nil {
+		ba.mu.Lock()
+		ba.modTime = info.ModTime()
+		ba.mu.Unlock()
+
+		if ba.log != nil {
+			ba.log.WithField("basic_auth", ba.name).Errorf("reloading htpasswd_file failed: %v", err)
+		}
+	}
+}
+
+func parseHTFile(file string) (htData, error) {
 	fp, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
 	defer fp.Close()
 
+	data := make(htData)
 	scanner := bufio.NewScanner(fp)
 	var lineNr int
 	for scanner.Scan() {
@@ -99,7 +173,7 @@ func NewBasicAuth(name, user, pass, file, realm string) (*BasicAuth, error) {
 			return nil, &BasicAuthHTParseError{code: InvalidLine, error: fmt.Errorf("%s:%d", file, lineNr)}
 		}
 
-		if _, ok := ba.htFile[up[0]]; ok {
+		if _, ok := data[up[0]]; ok {
 			return nil, &BasicAuthHTParseError{code: MultipleUser, error: fmt.Errorf("%s:%d: %q", file, lineNr, up[0])}
 		}
 
@@ -117,14 +191,28 @@ func NewBasicAuth(name, user, pass, file, realm string) (*BasicAuth, error) {
 				return nil, &BasicAuthHTParseError{code: MalformedPassword, error: fmt.Errorf("%s:%d: user %q", file, lineNr, up[0])}
 			}
 
-			ba.htFile[up[0]] = pwd{
+			data[up[0]] = pwd{
 				pwdOrig:   []byte(up[1]),
 				pwdPrefix: prefix,
 				pwdSalt:   parts[0],
 				pwdType:   pwdType,
 			}
-		case pwdTypeBcrypt:
-			ba.htFile[up[0]] = pwd{
+		case pwdTypeArgon2:
+			if _, err = parseArgon2(up[1]); err != nil {
+				return nil, &BasicAuthHTParseError{code: MalformedPassword, error: fmt.Errorf("%s:%d: user %q", file, lineNr, up[0])}
+			}
+			fallthrough
+		case pwdTypeBcrypt, pwdTypeSHA1:
+			data[up[0]] = pwd{
+				pwdOrig: []byte(up[1]),
+				pwdType: pwdType,
+			}
+		case pwdTypeSHACrypt:
+			if _, err = parseSHACrypt(up[1]); err != nil {
+				return nil, &BasicAuthHTParseError{code: MalformedPassword, error: fmt.Errorf("%s:%d: user %q", file, lineNr, up[0])}
+			}
+
+			data[up[0]] = pwd{
 				pwdOrig: []byte(up[1]),
 				pwdType: pwdType,
 			}
@@ -136,8 +224,10 @@ func NewBasicAuth(name, user, pass, file, realm string) (*BasicAuth, error) {
 		}
 	}
 
-	err = scanner.Err()
-	return ba, err
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
 // Validate implements the AccessControl interface
@@ -151,16 +241,45 @@ func (ba *BasicAuth) Validate(req *http.Request) error {
 		return &BasicAuthError{error: ErrorBasicAuthMissingCredentials, Realm: ba.realm}
 	}
 
-	if ba.user == user {
-		if subtle.ConstantTimeCompare([]byte(ba.pass), []byte(pass)) == 1 {
-			return nil
+	// locked attempts are rejected before the expensive password verification
+	if ba.lockout != nil {
+		if err := ba.lockout.Check(req, user); err != nil {
+			return err
+		}
+	}
+
+	if !ba.validate(user, pass) {
+		if ba.lockout != nil {
+			ba.lockout.Failure(req, user)
 		}
 		return &BasicAuthError{error: ErrorBasicAuthUnauthorized, Realm: ba.realm}
 	}
 
-	if validateAccessData(user, pass, ba.htFile) {
-		return nil
+	if ba.lockout != nil {
+		ba.lockout.Success(req, user)
+	}
+	return nil
+}
+
+// SetLogger sets the logger for errors of htpasswd file reloads.
+func (ba *BasicAuth) SetLogger(log logrus.FieldLogger) {
+	ba.log = log
+}
+
+// SetLockout enables the brute-force protection with the given lockout.
+func (ba *BasicAuth) SetLockout(lockout *Lockout) {
+	ba.lockout = lockout
+}
+
+func (ba *BasicAuth) validate(user, pass string) bool {
+	if ba.user == user {
+		return subtle.ConstantTimeCompare([]byte(ba.pass), []byte(pass)) == 1
 	}
 
-	return &BasicAuthError{error: ErrorBasicAuthUnauthorized, Realm: ba.realm}
+	ba.reload()
+
+	ba.mu.RLock()
+	data := ba.htFile
+	ba.mu.RUnlock()
+	return validateAccessData(user, pass, data)
 }
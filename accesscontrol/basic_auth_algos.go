@@ -3,26 +3,39 @@ package accesscontrol
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/sha1"
 	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
 	pwdPrefixApr1     = "$apr1$"
+	pwdPrefixArgon2i  = "$argon2i$"
+	pwdPrefixArgon2id = "$argon2id$"
 	pwdPrefixBcrypt2a = "$2a$"
 	pwdPrefixBcrypt2b = "$2b$"
 	pwdPrefixBcrypt2x = "$2x$"
 	pwdPrefixBcrypt2y = "$2y$"
 	pwdPrefixMD5      = "$1$"
+	pwdPrefixSHA1     = "{SHA}"
+	pwdPrefixSHA256   = "$5$"
+	pwdPrefixSHA512   = "$6$"
 )
 
 const (
 	pwdTypeUnknown = iota
 	pwdTypeApr1
+	pwdTypeArgon2
 	pwdTypeBcrypt
 	pwdTypeMD5
+	pwdTypeSHA1
+	pwdTypeSHACrypt
 )
 
 const (
@@ -33,11 +46,27 @@ const (
 
 var pwdPrefixes = map[string]int{
 	pwdPrefixApr1:     pwdTypeApr1,
+	pwdPrefixArgon2i:  pwdTypeArgon2,
+	pwdPrefixArgon2id: pwdTypeArgon2,
 	pwdPrefixBcrypt2a: pwdTypeBcrypt,
 	pwdPrefixBcrypt2b: pwdTypeBcrypt,
 	pwdPrefixBcrypt2x: pwdTypeBcrypt,
 	pwdPrefixBcrypt2y: pwdTypeBcrypt,
 	pwdPrefixMD5:      pwdTypeMD5,
+	pwdPrefixSHA1:     pwdTypeSHA1,
+	pwdPrefixSHA256:   pwdTypeSHACrypt,
+	pwdPrefixSHA512:   pwdTypeSHACrypt,
+}
+
+// argon2Params holds the parameters of an argon2 hash in the PHC string format,
+// e.g. $argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>.
+type argon2Params struct {
+	hash    []byte
+	id      bool
+	memory  uint32
+	salt    []byte
+	threads uint8
+	time    uint32
 }
 
 type htData map[string]pwd
@@ -69,10 +98,26 @@ func validateAccessData(plainUser, plainPass string, data htData) bool {
 				if subtle.ConstantTimeCompare(apr1MD5(plainPass, pass.pwdSalt, pass.pwdPrefix), pass.pwdOrig) == 1 {
 					return true
 				}
+			case pwdTypeArgon2:
+				if params, err := parseArgon2(string(pass.pwdOrig)); err == nil &&
+					subtle.ConstantTimeCompare(params.key(plainPass), params.hash) == 1 {
+					return true
+				}
 			case pwdTypeBcrypt:
 				if err := bcrypt.CompareHashAndPassword(pass.pwdOrig, []byte(plainPass)); err == nil {
 					return true
 				}
+			case pwdTypeSHA1:
+				sum := sha1.Sum([]byte(plainPass))
+				encoded := pwdPrefixSHA1 + base64.StdEncoding.EncodeToString(sum[:])
+				if subtle.ConstantTimeCompare([]byte(encoded), pass.pwdOrig) == 1 {
+					return true
+				}
+			case pwdTypeSHACrypt:
+				if crypted, err := shaCrypt(plainPass, string(pass.pwdOrig)); err == nil &&
+					subtle.ConstantTimeCompare(crypted, pass.pwdOrig) == 1 {
+					return true
+				}
 			}
 		}
 	}
@@ -80,6 +125,46 @@ func validateAccessData(plainUser, plainPass string, data htData) bool {
 	return false
 }
 
+func parseArgon2(encoded string) (*argon2Params, error) {
+	errMalformed := errors.New("malformed argon2 password")
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return nil, errMalformed
+	}
+
+	params := &argon2Params{id: parts[1] == "argon2id"}
+	if !params.id && parts[1] != "argon2i" {
+		return nil, errMalformed
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errMalformed
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil ||
+		params.time == 0 || params.threads == 0 {
+		return nil, errMalformed
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errMalformed
+	}
+	if params.hash, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.hash) == 0 {
+		return nil, errMalformed
+	}
+	return params, nil
+}
+
+func (a *argon2Params) key(pass string) []byte {
+	if a.id {
+		return argon2.IDKey([]byte(pass), a.salt, a.time, a.memory, a.threads, uint32(len(a.hash)))
+	}
+	return argon2.Key([]byte(pass), a.salt, a.time, a.memory, a.threads, uint32(len(a.hash)))
+}
+
 func apr1MD5(pass, salt, pref string) []byte {
 	var passLen int = len(pass)
 
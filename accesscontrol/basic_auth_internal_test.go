@@ -1,6 +1,14 @@
 package accesscontrol
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
 
 func Test_Apr1MD5(t *testing.T) {
 	var exp, res string
@@ -65,3 +73,100 @@ func Test_ValidateAccessData(t *testing.T) {
 		t.Error("Unexpected validation failure")
 	}
 }
+
+func Test_SHACrypt(t *testing.T) {
+	// test vectors of https://www.akkadia.org/drepper/SHA-crypt.txt
+	for _, exp := range []string{
+		"$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5",
+		"$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA",
+		"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1",
+		"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v.",
+	} {
+		res, err := shaCrypt("Hello world!", exp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(res) != exp {
+			t.Errorf("Got unexpected password: '%s', want '%s'", res, exp)
+		}
+	}
+}
+
+func Test_Argon2(t *testing.T) {
+	// test vectors of golang.org/x/crypto/argon2 in the PHC string format
+	for _, exp := range []string{
+		"$argon2i$v=19$m=64,t=2,p=2$c29tZXNhbHQ$IInz54p5lyD4CvgGVTEo8psTLK/kDQWf",
+		"$argon2id$v=19$m=64,t=2,p=2$c29tZXNhbHQ$NQrDciL0Nsy1wJcvHr079rlYvyBxhBNi",
+	} {
+		params, err := parseArgon2(exp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := params.key("password"); string(res) != string(params.hash) {
+			t.Errorf("Got unexpected hash for '%s'", exp)
+		}
+		if res := params.key("passwort"); string(res) == string(params.hash) {
+			t.Errorf("Expected hash mismatch for '%s'", exp)
+		}
+	}
+}
+
+func Test_BasicAuthReload(t *testing.T) {
+	file, err := ioutil.TempFile("", "htpasswd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	writeFile := func(content string, modTime time.Time) {
+		if err := ioutil.WriteFile(file.Name(), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file.Name(), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// $ htpasswd -bs .htpasswd john my-pass
+	writeFile("john:{SHA}FcrlzJC8GZi2VJ8fE+4LjKSPmvc=\n", time.Now().Add(-time.Hour))
+
+	ba, err := NewBasicAuth("ba", "", "", file.Name(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, hook := logrustest.NewNullLogger()
+	ba.SetLogger(logger)
+
+	if !ba.validate("john", "my-pass") {
+		t.Fatal("expected a valid user")
+	}
+
+	writeFile("jane:{SHA}FcrlzJC8GZi2VJ8fE+4LjKSPmvc=\n", time.Now())
+	ba.lastCheck = time.Time{}
+
+	if !ba.validate("jane", "my-pass") || ba.validate("john", "my-pass") {
+		t.Error("expected the reloaded users")
+	}
+
+	// the previous data is kept on parse errors
+	writeFile("jane:$unsupported$\n", time.Now().Add(time.Minute))
+	ba.lastCheck = time.Time{}
+
+	if !ba.validate("jane", "my-pass") {
+		t.Error("expected the previous users on parse errors")
+	}
+
+	if entries := hook.AllEntries(); len(entries) != 1 || entries[0].Level != logrus.ErrorLevel {
+		t.Errorf("expected one logged reload error, got: %v", entries)
+	}
+
+	// the error is logged once per modification
+	ba.lastCheck = time.Time{}
+	if !ba.validate("jane", "my-pass") {
+		t.Error("expected the previous users on parse errors")
+	}
+	if n := len(hook.AllEntries()); n != 1 {
+		t.Errorf("expected one logged reload error, got: %d", n)
+	}
+}
@@ -0,0 +1,167 @@
+package accesscontrol
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultLockoutDuration is the lockout after reaching the max failures.
+	DefaultLockoutDuration = time.Minute
+	// maxLockoutDuration limits the doubling of the lockout duration for further failures.
+	maxLockoutDuration = time.Hour
+	// maxLockoutEntries triggers the removal of outdated failure counters.
+	maxLockoutEntries = 10000
+)
+
+var ErrorBasicAuthLocked = errors.New("too many failed attempts")
+
+// BasicAuthLockedError is returned for locked users or client addresses.
+type BasicAuthLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *BasicAuthLockedError) Error() string {
+	return ErrorBasicAuthLocked.Error()
+}
+
+type failures struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Lockout counts failed authentication attempts per user and client ip. Reaching the max failures
+// locks the user or ip for the lockout duration which doubles with every further failure.
+type Lockout struct {
+	duration    time.Duration
+	log         logrus.FieldLogger
+	maxFailures int
+	name        string
+
+	mu      sync.Mutex
+	entries map[string]*failures
+}
+
+func NewLockout(name string, maxFailures int, duration time.Duration, log logrus.FieldLogger) *Lockout {
+	if duration <= 0 {
+		duration = DefaultLockoutDuration
+	}
+
+	return &Lockout{
+		duration:    duration,
+		entries:     make(map[string]*failures),
+		log:         log,
+		maxFailures: maxFailures,
+		name:        name,
+	}
+}
+
+// Check returns a BasicAuthLockedError if the user or client ip is locked.
+func (l *Lockout) Check(req *http.Request, user string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range lockoutKeys(req, user) {
+		if f, exist := l.entries[key]; exist && now.Before(f.lockedUntil) {
+			if d := f.lockedUntil.Sub(now); d > retryAfter {
+				retryAfter = d
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return &BasicAuthLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Failure counts a failed attempt for the user and client ip.
+func (l *Lockout) Failure(req *http.Request, user string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.cleanup(now)
+
+	for _, key := range lockoutKeys(req, user) {
+		f, exist := l.entries[key]
+		if !exist || now.Sub(f.lastFailure) > l.maxDuration() {
+			f = &failures{}
+			l.entries[key] = f
+		}
+
+		f.count++
+		f.lastFailure = now
+
+		if f.count < l.maxFailures {
+			continue
+		}
+
+		lockout := l.duration << uint(f.count-l.maxFailures)
+		if lockout > l.maxDuration() || lockout <= 0 {
+			lockout = l.maxDuration()
+		}
+		f.lockedUntil = now.Add(lockout)
+
+		if l.log != nil {
+			l.log.WithFields(logrus.Fields{
+				"basic_auth": l.name,
+				"failures":   f.count,
+				"key":        key,
+				"lockout":    lockout.String(),
+			}).Warn("basic_auth lockout")
+		}
+	}
+}
+
+// Success resets the failure counter of the user. The failures of the client ip are kept,
+// otherwise a valid login would allow unlimited attempts for other users.
+func (l *Lockout) Success(_ *http.Request, user string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, userLockoutKey(user))
+}
+
+func (l *Lockout) maxDuration() time.Duration {
+	if l.duration > maxLockoutDuration {
+		return l.duration
+	}
+	return maxLockoutDuration
+}
+
+func (l *Lockout) cleanup(now time.Time) {
+	if len(l.entries) < maxLockoutEntries {
+		return
+	}
+
+	for key, f := range l.entries {
+		if now.After(f.lockedUntil) && now.Sub(f.lastFailure) > l.maxDuration() {
+			delete(l.entries, key)
+		}
+	}
+
+	// keep the locked entries only
+	if len(l.entries) >= maxLockoutEntries {
+		for key, f := range l.entries {
+			if now.After(f.lockedUntil) {
+				delete(l.entries, key)
+			}
+		}
+	}
+}
+
+func lockoutKeys(req *http.Request, user string) []string {
+	return []string{userLockoutKey(user), "ip:" + getClientIP(req)}
+}
+
+func userLockoutKey(user string) string {
+	return "user:" + user
+}
@@ -0,0 +1,34 @@
+package accesscontrol
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLockout_Exponential(t *testing.T) {
+	lockout := NewLockout("ba", 1, time.Second, nil)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		lockout.Failure(req, "user")
+
+		err, ok := lockout.Check(req, "user").(*BasicAuthLockedError)
+		if !ok || err.RetryAfter > expected || err.RetryAfter < expected-time.Millisecond*100 {
+			t.Fatalf("expected a lockout of %s, got: %v", expected, err)
+		}
+
+		// expire the lockout
+		for _, f := range lockout.entries {
+			f.lockedUntil = time.Now()
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		lockout.Failure(req, "user")
+	}
+	if err := lockout.Check(req, "user").(*BasicAuthLockedError); err.RetryAfter > maxLockoutDuration {
+		t.Errorf("expected the lockout to be limited, got: %s", err.RetryAfter)
+	}
+}
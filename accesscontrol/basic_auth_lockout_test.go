@@ -0,0 +1,110 @@
+package accesscontrol_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	ac "github.com/avenga/couper/accesscontrol"
+)
+
+func TestBasicAuth_Lockout(t *testing.T) {
+	ba, err := ac.NewBasicAuth("ba", "user", "pass", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log, hook := logrustest.NewNullLogger()
+	ba.SetLockout(ac.NewLockout("ba", 2, time.Minute, log))
+
+	newReq := func(user, pass, remoteAddr string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.SetBasicAuth(user, pass)
+		return req
+	}
+
+	// a successful attempt resets the user failures
+	for i, pass := range []string{"wrong", "pass", "wrong", "pass"} {
+		err = ba.Validate(newReq("user", pass, fmt.Sprintf("192.0.2.%d:1234", 10+i)))
+		if _, locked := err.(*ac.BasicAuthLockedError); locked {
+			t.Fatalf("expected no lockout, got: %v", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, ok := ba.Validate(newReq("user", "wrong", "192.0.2.1:1234")).(*ac.BasicAuthError); !ok {
+			t.Fatal("expected a basic auth error before the lockout")
+		}
+	}
+
+	tests := []struct {
+		name       string
+		user       string
+		pass       string
+		remoteAddr string
+		wantLocked bool
+	}{
+		{"locked user and ip", "user", "pass", "192.0.2.1:1234", true},
+		{"locked user", "user", "pass", "192.0.2.2:1234", true},
+		{"locked ip", "other", "pass", "192.0.2.1:1234", true},
+		{"other user and ip", "other", "wrong", "192.0.2.2:1234", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			err := ba.Validate(newReq(tt.user, tt.pass, tt.remoteAddr))
+			lockedErr, locked := err.(*ac.BasicAuthLockedError)
+			if locked != tt.wantLocked {
+				subT.Fatalf("expected locked: %v, got: %v", tt.wantLocked, err)
+			}
+			if locked && (lockedErr.RetryAfter <= 0 || lockedErr.RetryAfter > time.Minute) {
+				subT.Errorf("unexpected retry after: %s", lockedErr.RetryAfter)
+			}
+		})
+	}
+
+	var lockouts int
+	for _, entry := range hook.AllEntries() {
+		if entry.Message == "basic_auth lockout" {
+			lockouts++
+		}
+	}
+	if lockouts != 2 {
+		t.Errorf("expected lockout log entries for the user and ip, got: %d", lockouts)
+	}
+}
+
+func TestBasicAuth_Lockout_Success(t *testing.T) {
+	ba, err := ac.NewBasicAuth("ba", "user", "pass", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ba.SetLockout(ac.NewLockout("ba", 2, time.Minute, nil))
+
+	newReq := func(user, pass string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.SetBasicAuth(user, pass)
+		return req
+	}
+
+	// a valid login between the attempts for other users must not reset the ip failures
+	for _, user := range []string{"other", "user", "another"} {
+		pass := "wrong"
+		if user == "user" {
+			pass = "pass"
+		}
+		if _, locked := ba.Validate(newReq(user, pass)).(*ac.BasicAuthLockedError); locked {
+			t.Fatalf("expected no lockout for %q", user)
+		}
+	}
+
+	if _, locked := ba.Validate(newReq("user", "pass")).(*ac.BasicAuthLockedError); !locked {
+		t.Error("expected a locked ip")
+	}
+}
@@ -0,0 +1,198 @@
+package accesscontrol
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SHA-crypt constants, see https://www.akkadia.org/drepper/SHA-crypt.txt
+const (
+	shaCryptDefaultRounds = 5000
+	shaCryptMaxRounds     = 999999999
+	shaCryptMaxSaltLength = 16
+	shaCryptMinRounds     = 1000
+	shaCryptRoundsPrefix  = "rounds="
+)
+
+var errorMalformedSHACrypt = errors.New("malformed sha-crypt password")
+
+// shaCrypt256Order and shaCrypt512Order define the byte permutation of the encoded digest.
+var (
+	shaCrypt256Order = [][3]int{
+		{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+		{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+	}
+	shaCrypt512Order = [][3]int{
+		{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+		{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+		{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+		{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+		{62, 20, 41},
+	}
+)
+
+type shaCryptParams struct {
+	customRounds bool
+	newHash      func() hash.Hash
+	prefix       string
+	rounds       int
+	salt         []byte
+}
+
+// parseSHACrypt reads the algorithm, rounds and salt of a SHA-256 ("$5$") or SHA-512 ("$6$") crypt string.
+func parseSHACrypt(crypted string) (*shaCryptParams, error) {
+	params := &shaCryptParams{rounds: shaCryptDefaultRounds}
+	switch {
+	case strings.HasPrefix(crypted, pwdPrefixSHA256):
+		params.newHash, params.prefix = sha256.New, pwdPrefixSHA256
+	case strings.HasPrefix(crypted, pwdPrefixSHA512):
+		params.newHash, params.prefix = sha512.New, pwdPrefixSHA512
+	default:
+		return nil, errorMalformedSHACrypt
+	}
+
+	parts := strings.Split(strings.TrimPrefix(crypted, params.prefix), "$")
+	if strings.HasPrefix(parts[0], shaCryptRoundsPrefix) {
+		rounds, err := strconv.Atoi(strings.TrimPrefix(parts[0], shaCryptRoundsPrefix))
+		if err != nil {
+			return nil, errorMalformedSHACrypt
+		}
+		params.rounds, params.customRounds = rounds, true
+		parts = parts[1:]
+
+		if params.rounds < shaCryptMinRounds {
+			params.rounds = shaCryptMinRounds
+		} else if params.rounds > shaCryptMaxRounds {
+			params.rounds = shaCryptMaxRounds
+		}
+	}
+
+	if len(parts) != 2 {
+		return nil, errorMalformedSHACrypt
+	}
+
+	params.salt = []byte(parts[0])
+	if len(params.salt) > shaCryptMaxSaltLength {
+		params.salt = params.salt[:shaCryptMaxSaltLength]
+	}
+	return params, nil
+}
+
+// shaCrypt computes the crypt string of the given password with the parameters of the given crypt string.
+func shaCrypt(pass, crypted string) ([]byte, error) {
+	params, err := parseSHACrypt(crypted)
+	if err != nil {
+		return nil, err
+	}
+
+	newHash, prefix, rounds, salt := params.newHash, params.prefix, params.rounds, params.salt
+	password := []byte(pass)
+
+	h := newHash()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(password)
+	b := h.Sum(nil)
+
+	h.Reset()
+	h.Write(password)
+	h.Write(salt)
+	for i := len(password); i > 0; i -= len(b) {
+		if i > len(b) {
+			h.Write(b)
+		} else {
+			h.Write(b[:i])
+		}
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			h.Write(b)
+		} else {
+			h.Write(password)
+		}
+	}
+	a := h.Sum(nil)
+
+	h.Reset()
+	for i := 0; i < len(password); i++ {
+		h.Write(password)
+	}
+	p := repeatBytes(h.Sum(nil), len(password))
+
+	h.Reset()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(salt)
+	}
+	s := repeatBytes(h.Sum(nil), len(salt))
+
+	c := a
+	for i := 0; i < rounds; i++ {
+		h.Reset()
+		if i&1 == 1 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 == 1 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(nil)
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString(prefix)
+	if params.customRounds {
+		buf.WriteString(shaCryptRoundsPrefix + strconv.Itoa(rounds) + "$")
+	}
+	buf.Write(salt)
+	buf.WriteByte('$')
+
+	order := shaCrypt256Order
+	if len(c) == sha512.Size {
+		order = shaCrypt512Order
+	}
+	for _, o := range order {
+		writeCrypt64(&buf, c[o[0]], c[o[1]], c[o[2]], 4)
+	}
+	if len(c) == sha512.Size {
+		writeCrypt64(&buf, 0, 0, c[63], 2)
+	} else {
+		writeCrypt64(&buf, 0, c[31], c[30], 3)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeCrypt64 writes n characters of the given 24 bits with the crypt base64 alphabet.
+func writeCrypt64(buf *bytes.Buffer, b2, b1, b0 byte, n int) {
+	v := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for i := 0; i < n; i++ {
+		buf.WriteByte(aprCharacters[v&0x3f])
+		v >>= 6
+	}
+}
+
+func repeatBytes(b []byte, length int) []byte {
+	result := make([]byte, 0, length)
+	for len(result) < length {
+		n := length - len(result)
+		if n > len(b) {
+			n = len(b)
+		}
+		result = append(result, b[:n]...)
+	}
+	return result
+}
@@ -24,6 +24,8 @@ func Test_NewBasicAuth(t *testing.T) {
 		{"name", "user", "pass", "testdata/htpasswd_err_invalid", "Basic", "basic auth ht parse error: invalidLine: testdata/htpasswd_err_invalid:1", true},
 		{"name", "user", "pass", "testdata/htpasswd_err_too_long", "Basic", "basic auth ht parse error: lineTooLong: testdata/htpasswd_err_too_long:1", true},
 		{"name", "user", "pass", "testdata/htpasswd_err_malformed", "Basic", `basic auth ht parse error: malformedPassword: testdata/htpasswd_err_malformed:1: user "foo"`, true},
+		{"name", "user", "pass", "testdata/htpasswd_err_malformed_argon2", "Basic", `basic auth ht parse error: malformedPassword: testdata/htpasswd_err_malformed_argon2:1: user "foo"`, true},
+		{"name", "user", "pass", "testdata/htpasswd_err_malformed_sha_crypt", "Basic", `basic auth ht parse error: malformedPassword: testdata/htpasswd_err_malformed_sha_crypt:1: user "foo"`, true},
 		{"name", "user", "pass", "testdata/htpasswd_err_multi", "Basic", `basic auth ht parse error: multipleUser: testdata/htpasswd_err_multi:2: "foo"`, true},
 		{"name", "user", "pass", "testdata/htpasswd_err_unsupported", "Basic", "basic auth ht parse error: notSupported: testdata/htpasswd_err_unsupported:1: unknown password algorithm", true},
 	} {
@@ -68,18 +70,32 @@ func Test_Validate(t *testing.T) {
 		{"Basic X", authError},
 		{"Basic " + b64.StdEncoding.EncodeToString([]byte("usr:pwd:foo")), authError},
 		{"Basic " + b64.StdEncoding.EncodeToString([]byte("user:pass")), nil},
-		{"bAsIc   " + b64.StdEncoding.EncodeToString([]byte("user:pass")), nil},
-		{"Asdfg " + b64.StdEncoding.EncodeToString([]byte("user:bass")), nil},
+		{"bAsIc " + b64.StdEncoding.EncodeToString([]byte("user:pass")), nil},
+		{"bAsIc   " + b64.StdEncoding.EncodeToString([]byte("user:pass")), authError},
+		{"Asdfg " + b64.StdEncoding.EncodeToString([]byte("user:pass")), authError},
 		{"Basic " + b64.StdEncoding.EncodeToString([]byte("user:bass")), authError},
 		{"Basic " + b64.StdEncoding.EncodeToString([]byte("john:my-pass")), nil},
 		{"Basic " + b64.StdEncoding.EncodeToString([]byte("john:my-bass")), authError},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("sha1:my-pass")), nil},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("sha1:my-bass")), authError},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("sha256:my-pass")), nil},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("sha256:my-bass")), authError},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("sha512:my-pass")), nil},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("sha512:my-bass")), authError},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("argon2i:my-pass")), nil},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("argon2i:my-bass")), authError},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("argon2id:my-pass")), nil},
+		{"Basic " + b64.StdEncoding.EncodeToString([]byte("argon2id:my-bass")), authError},
 	} {
 		t.Run(testcase.headerValue, func(t *testing.T) {
 			req.Header.Set("Authorization", testcase.headerValue)
 			err := ba.Validate(req)
-			if testcase.expErr != nil && !errors.As(err, &testcase.expErr) {
+			if testcase.expErr != nil && !errors.As(err, &authError) {
 				t.Errorf("Expected Unauthorized error, got: %v", err)
 			}
+			if testcase.expErr == nil && err != nil {
+				t.Errorf("Expected no error, got: %v", err)
+			}
 		})
 	}
 }
@@ -0,0 +1,53 @@
+package accesscontrol
+
+import (
+	"errors"
+	"net/http"
+)
+
+var _ AccessControl = &Group{}
+
+// Group is an alternative combination of access controls, one successful member is sufficient.
+type Group struct {
+	members List
+	name    string
+}
+
+func NewGroup(name string, members ...AccessControl) (*Group, error) {
+	if len(members) == 0 {
+		return nil, errors.New("missing access controls")
+	}
+	return &Group{members: members, name: name}, nil
+}
+
+// Validate passes the request to the members in order until one succeeds. Otherwise the first
+// error of a member with given but invalid credentials is returned, the first error if all credentials are missing.
+func (g *Group) Validate(req *http.Request) error {
+	var result error
+	for _, member := range g.members {
+		err := member.Validate(req)
+		if err == nil {
+			return nil
+		}
+
+		if result == nil || (isMissingCredentials(result) && !isMissingCredentials(err)) {
+			result = err
+		}
+	}
+	return result
+}
+
+func isMissingCredentials(err error) bool {
+	switch e := err.(type) {
+	case *BasicAuthError:
+		return e.error == ErrorBasicAuthMissingCredentials
+	case *OIDCRedirectError:
+		return true
+	}
+
+	switch err {
+	case ErrorEmptyToken, ErrorMissingSession, ErrorMTLSMissingCertificate:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package accesscontrol_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go/v4"
+
+	ac "github.com/avenga/couper/accesscontrol"
+)
+
+func TestGroup_Validate(t *testing.T) {
+	key := []byte("secret")
+	j, err := ac.NewJWT("HS256", "token", nil, nil, ac.Header, "Authorization", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ba, err := ac.NewBasicAuth("legacy", "user", "pass", "", "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err := ac.NewGroup("token_or_legacy", ba, j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "me"}).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization func(req *http.Request)
+		wantErr       bool
+		wantBasicAuth bool
+	}{
+		{"jwt", func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) }, false, false},
+		{"basic auth", func(req *http.Request) { req.SetBasicAuth("user", "pass") }, false, false},
+		{"invalid basic auth", func(req *http.Request) { req.SetBasicAuth("user", "wrong") }, true, true},
+		{"invalid jwt", func(req *http.Request) { req.Header.Set("Authorization", "Bearer invalid") }, true, false},
+		{"missing credentials", func(req *http.Request) {}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.authorization(req)
+
+			err := group.Validate(req)
+			if (err != nil) != tt.wantErr {
+				subT.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if _, isBasicAuth := err.(*ac.BasicAuthError); isBasicAuth != tt.wantBasicAuth {
+				subT.Errorf("expected basic auth error: %v, got: %v", tt.wantBasicAuth, err)
+			}
+		})
+	}
+
+	if _, err = ac.NewGroup("empty"); err == nil {
+		t.Error("expected an error without members")
+	}
+}
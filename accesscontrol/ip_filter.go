@@ -0,0 +1,79 @@
+package accesscontrol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/avenga/couper/config/request"
+	"github.com/avenga/couper/utils"
+)
+
+var (
+	ErrorIPDenied = errors.New("client ip is not allowed")
+
+	_ AccessControl = &IPFilter{}
+)
+
+// IPFilter validates the client ip against allow and deny networks. Denied networks take precedence,
+// an empty allow list permits all other addresses.
+type IPFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+	name  string
+}
+
+func NewIPFilter(name string, allow, deny []string) (*IPFilter, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, errors.New("either allow or deny must be specified")
+	}
+
+	allowNets, err := utils.ParseNetworks(allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow: %v", err)
+	}
+
+	denyNets, err := utils.ParseNetworks(deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny: %v", err)
+	}
+
+	return &IPFilter{
+		allow: allowNets,
+		deny:  denyNets,
+		name:  name,
+	}, nil
+}
+
+// Validate checks the client ip against the configured networks.
+func (f *IPFilter) Validate(req *http.Request) error {
+	clientIP := getClientIP(req)
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return ErrorIPDenied
+	}
+
+	if utils.ContainsIP(f.deny, ip) {
+		return ErrorIPDenied
+	}
+
+	if len(f.allow) > 0 && !utils.ContainsIP(f.allow, ip) {
+		return ErrorIPDenied
+	}
+
+	setContextData(req, f.name, Claims{"client_ip": clientIP})
+	return nil
+}
+
+// getClientIP returns the client ip which is resolved by the server, the RemoteAddr host otherwise.
+func getClientIP(req *http.Request) string {
+	if clientIP, ok := req.Context().Value(request.ClientIP).(string); ok {
+		return clientIP
+	}
+
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
@@ -0,0 +1,65 @@
+package accesscontrol_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ac "github.com/avenga/couper/accesscontrol"
+	"github.com/avenga/couper/config/request"
+)
+
+func TestIPFilter_Validate(t *testing.T) {
+	filter, err := ac.NewIPFilter("office", []string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"}, []string{"10.0.1.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	denyOnly, err := ac.NewIPFilter("blocked", nil, []string{"198.51.100.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		filter     *ac.IPFilter
+		remoteAddr string
+		clientIP   string
+		wantErr    bool
+	}{
+		{"allowed network", filter, "10.1.2.3:1234", "", false},
+		{"allowed address", filter, "192.0.2.1:1234", "", false},
+		{"allowed ipv6", filter, "[2001:db8::1]:1234", "", false},
+		{"denied subnet", filter, "10.0.1.2:1234", "", true},
+		{"not allowed", filter, "192.0.2.2:1234", "", true},
+		{"resolved client ip", filter, "192.0.2.2:1234", "10.1.2.3", false},
+		{"resolved denied client ip", filter, "10.1.2.3:1234", "192.0.2.2", true},
+		{"deny only", denyOnly, "192.0.2.2:1234", "", false},
+		{"deny only denied", denyOnly, "198.51.100.7:1234", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.clientIP != "" {
+				*req = *req.WithContext(context.WithValue(req.Context(), request.ClientIP, tt.clientIP))
+			}
+
+			if err := tt.filter.Validate(req); (err != nil) != tt.wantErr {
+				subT.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	for _, networks := range [][]string{{"10.0.0.0/33"}, {"not-an-ip"}} {
+		if _, err = ac.NewIPFilter("invalid", networks, nil); err == nil {
+			t.Errorf("expected an error for %v", networks)
+		}
+	}
+
+	if _, err = ac.NewIPFilter("empty", nil, nil); err == nil {
+		t.Error("expected an error without networks")
+	}
+}
@@ -0,0 +1,312 @@
+package accesscontrol
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJWKSTTL is the max age of a loaded key set.
+	DefaultJWKSTTL = time.Hour
+	// jwksRefreshInterval limits the reloads of a key set for unknown key ids.
+	jwksRefreshInterval = time.Second * 10
+	jwksTimeout         = time.Second * 10
+)
+
+var (
+	ErrorJWKSKeyNotFound       = errors.New("no matching key found in jwks")
+	ErrorJWKSMissingKid        = errors.New("missing kid header")
+	ErrorJWKUnsupportedKeyType = errors.New("unsupported key type")
+)
+
+// JWK represents a single JSON Web Key, see https://tools.ietf.org/html/rfc7517.
+type JWK struct {
+	Alg string   `json:"alg"`
+	Crv string   `json:"crv"`
+	E   string   `json:"e"`
+	K   string   `json:"k"`
+	Kid string   `json:"kid"`
+	Kty string   `json:"kty"`
+	N   string   `json:"n"`
+	Use string   `json:"use"`
+	X   string   `json:"x"`
+	X5c []string `json:"x5c"`
+	Y   string   `json:"y"`
+}
+
+type jwkSet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+type jwksKey struct {
+	alg string
+	key interface{}
+}
+
+// JWKS loads a JSON Web Key Set from an url or a file and caches its keys.
+// Unknown key ids trigger a reload which is limited to one per jwksRefreshInterval.
+// Concurrent lookups wait for the running reload instead of fetching the set again.
+type JWKS struct {
+	client *http.Client
+	file   string
+	ttl    time.Duration
+	url    string
+
+	mu        sync.RWMutex
+	expires   time.Time
+	keys      map[string]jwksKey
+	lastFetch time.Time
+	loadErr   error
+	loading   chan struct{}
+}
+
+// NewJWKS creates a key set for either the given url or the given file.
+// Files are loaded immediately, urls on the first lookup.
+func NewJWKS(url, file string, ttl time.Duration) (*JWKS, error) {
+	if (url == "") == (file == "") {
+		return nil, errors.New("either jwks_url or jwks_file must be specified")
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultJWKSTTL
+	}
+
+	jwks := &JWKS{
+		client: &http.Client{Timeout: jwksTimeout},
+		file:   file,
+		ttl:    ttl,
+		url:    url,
+	}
+
+	if file != "" {
+		if err := jwks.reload(); err != nil {
+			return nil, err
+		}
+	}
+	return jwks, nil
+}
+
+// Key returns the key with the given id which is usable for the given algorithm.
+// An empty id selects the key if the set contains exactly one key.
+func (j *JWKS) Key(kid, alg string) (interface{}, error) {
+	j.mu.RLock()
+	key, found := j.lookup(kid)
+	valid := time.Now().Before(j.expires)
+	j.mu.RUnlock()
+
+	if !found || !valid {
+		err := j.reload()
+
+		j.mu.RLock()
+		key, found = j.lookup(kid)
+		j.mu.RUnlock()
+
+		if err != nil && !found {
+			return nil, err
+		}
+	}
+
+	if !found {
+		if kid == "" {
+			return nil, ErrorJWKSMissingKid
+		}
+		return nil, ErrorJWKSKeyNotFound
+	}
+
+	if (key.alg != "" && key.alg != alg) || !isKeyTypeOf(key.key, NewAlgorithm(alg)) {
+		return nil, fmt.Errorf("jwks: key %q is not usable for algorithm %q", kid, alg)
+	}
+	return key.key, nil
+}
+
+func (j *JWKS) lookup(kid string) (jwksKey, bool) {
+	if kid == "" {
+		if len(j.keys) != 1 {
+			return jwksKey{}, false
+		}
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+// reload replaces the keys with the current key set at most once per jwksRefreshInterval,
+// the old keys are kept on errors. The set is loaded without holding the lock.
+func (j *JWKS) reload() error {
+	j.mu.Lock()
+	if loading := j.loading; loading != nil {
+		j.mu.Unlock()
+		<-loading
+
+		j.mu.RLock()
+		defer j.mu.RUnlock()
+		return j.loadErr
+	}
+
+	if time.Since(j.lastFetch) < jwksRefreshInterval {
+		j.mu.Unlock()
+		return nil
+	}
+
+	fetched := time.Now()
+	loading := make(chan struct{})
+	j.lastFetch, j.loading = fetched, loading
+	j.mu.Unlock()
+
+	keys, err := j.load()
+
+	j.mu.Lock()
+	if err == nil {
+		j.keys = keys
+		j.expires = fetched.Add(j.ttl)
+	}
+	j.loadErr, j.loading = err, nil
+	j.mu.Unlock()
+
+	close(loading)
+	return err
+}
+
+// load reads and parses the current key set.
+func (j *JWKS) load() (map[string]jwksKey, error) {
+	var raw []byte
+	var err error
+	if j.file != "" {
+		raw, err = ioutil.ReadFile(j.file)
+	} else {
+		raw, err = j.fetch()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwks: %v", err)
+	}
+
+	var set jwkSet
+	if err = json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("jwks: %v", err)
+	}
+
+	keys := make(map[string]jwksKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err == ErrorJWKUnsupportedKeyType {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: %v", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = jwksKey{alg: jwk.Alg, key: key}
+	}
+	return keys, nil
+}
+
+func (j *JWKS) fetch() ([]byte, error) {
+	res, err := j.client.Get(j.url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// PublicKey returns the verification key of the JWK.
+func (k *JWK) PublicKey() (interface{}, error) {
+	if len(k.X5c) > 0 {
+		der, err := base64.StdEncoding.DecodeString(k.X5c[0])
+		if err != nil {
+			return nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, ErrorJWKUnsupportedKeyType
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		pubKey := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(pubKey.X, pubKey.Y) {
+			return nil, errors.New("invalid curve point")
+		}
+		return pubKey, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, ErrorJWKUnsupportedKeyType
+		}
+		x, err := decodeBase64URL(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return decodeBase64URL(k.K)
+	default:
+		return nil, ErrorJWKUnsupportedKeyType
+	}
+}
+
+func decodeBase64URL(value string) ([]byte, error) {
+	if value == "" {
+		return nil, errors.New("missing key parameter")
+	}
+	return base64.RawURLEncoding.DecodeString(value)
+}
@@ -0,0 +1,93 @@
+package accesscontrol
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestJWKS_Refresh(t *testing.T) {
+	var fetches int32
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			_, _ = rw.Write([]byte(`{"keys":[{"kty":"oct","kid":"old","k":"c2VjcmV0"}]}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"keys":[{"kty":"oct","kid":"new","k":"c2VjcmV0"},{"kty":"EC","kid":"ec"}]}`))
+	}))
+	defer origin.Close()
+
+	jwks, err := NewJWKS(origin.URL, "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = jwks.Key("old", "HS256"); err != nil {
+		t.Fatal(err)
+	}
+
+	// rate limited
+	if _, err = jwks.Key("new", "HS256"); err != ErrorJWKSKeyNotFound {
+		t.Errorf("expected ErrorJWKSKeyNotFound, got: %v", err)
+	}
+
+	jwks.lastFetch = jwks.lastFetch.Add(-jwksRefreshInterval)
+	if _, err = jwks.Key("new", "HS256"); err != nil {
+		t.Errorf("expected the rotated key, got: %v", err)
+	}
+
+	if _, err = jwks.Key("old", "HS256"); err != ErrorJWKSKeyNotFound {
+		t.Errorf("expected ErrorJWKSKeyNotFound for the removed key, got: %v", err)
+	}
+
+	// expired sets are reloaded, the keys are kept on errors
+	origin.Close()
+	jwks.expires = time.Now()
+	jwks.lastFetch = jwks.lastFetch.Add(-jwksRefreshInterval)
+	if _, err = jwks.Key("new", "HS256"); err != nil {
+		t.Errorf("expected the cached key, got: %v", err)
+	}
+
+	if n := atomic.LoadInt32(&fetches); n != 2 {
+		t.Errorf("expected 2 jwks requests, got: %d", n)
+	}
+}
+
+func TestJWKS_ConcurrentRefresh(t *testing.T) {
+	var fetches int32
+	release := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		_, _ = rw.Write([]byte(`{"keys":[{"kty":"oct","kid":"key","k":"c2VjcmV0"}]}`))
+	}))
+	defer origin.Close()
+
+	jwks, err := NewJWKS(origin.URL, "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := jwks.Key("key", "HS256")
+			errs <- err
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+
+	for i := 0; i < 10; i++ {
+		if err = <-errs; err != nil {
+			t.Errorf("expected the fetched key, got: %v", err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("expected one jwks request, got: %d", n)
+	}
+}
@@ -0,0 +1,152 @@
+package accesscontrol_test
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/v4"
+
+	ac "github.com/avenga/couper/accesscontrol"
+)
+
+func TestJWT_ValidateJWKS(t *testing.T) {
+	_, key1 := newRSAKeyPair()
+	_, key2 := newRSAKeyPair()
+	_, unknownKey := newRSAKeyPair()
+
+	var fetches int32
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write(newJWKS(map[string]*rsa.PrivateKey{"key1": key1, "key2": key2}))
+	}))
+	defer origin.Close()
+
+	jwks, err := ac.NewJWKS(origin.URL, "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j, err := ac.NewJWTFromJWKS("RS256", "test_ac", nil, nil, ac.Header, "Authorization", jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		kid     string
+		key     *rsa.PrivateKey
+		wantErr bool
+	}{
+		{"kid key1", "key1", key1, false},
+		{"kid key2", "key2", key2, false},
+		{"kid key1 /w key2 signature", "key1", key2, true},
+		{"unknown kid", "key3", unknownKey, true},
+		{"other unknown kid", "key4", unknownKey, true},
+		{"missing kid", "", key1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "me"})
+			if tt.kid != "" {
+				tok.Header["kid"] = tt.kid
+			}
+			token, err := tok.SignedString(tt.key)
+			if err != nil {
+				subT.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			if err = j.Validate(req); (err != nil) != tt.wantErr {
+				subT.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// the set is cached and reloads for unknown key ids are rate limited
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("expected 1 jwks request, got: %d", n)
+	}
+}
+
+func TestNewJWKS_File(t *testing.T) {
+	_, key := newRSAKeyPair()
+
+	file, err := ioutil.TempFile("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(newJWKS(map[string]*rsa.PrivateKey{"file-key": key})); err != nil {
+		t.Fatal(err)
+	}
+	if err = file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := ac.NewJWKS("", file.Name(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey, err := jwks.Key("", "RS256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pubKey.(*rsa.PublicKey).N.Cmp(key.N) != 0 {
+		t.Error("expected the public key of the key set")
+	}
+
+	if _, err = jwks.Key("file-key", "RS512"); err == nil {
+		t.Error("expected an error for a key with another algorithm")
+	}
+
+	if _, err = ac.NewJWKS("", file.Name()+".missing", 0); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	if _, err = ac.NewJWKS("", "", 0); err == nil {
+		t.Error("expected an error without url and file")
+	}
+}
+
+func newJWKS(keys map[string]*rsa.PrivateKey) []byte {
+	type jwk struct {
+		Alg string `json:"alg"`
+		E   string `json:"e"`
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		Use string `json:"use"`
+	}
+
+	var list []jwk
+	for kid, key := range keys {
+		list = append(list, jwk{
+			Alg: "RS256",
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			Kid: kid,
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			Use: "sig",
+		})
+	}
+
+	b, err := json.Marshal(map[string]interface{}{"keys": list})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
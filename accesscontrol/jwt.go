@@ -1,7 +1,9 @@
 package accesscontrol
 
 import (
-	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
@@ -9,25 +11,16 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go/v4"
 )
 
-const (
-	Unknown Source = iota - 1
-	Cookie
-	Header
-)
-
 var (
-	ErrorBearerRequired = errors.New("authorization header value must start with 'Bearer '")
-	ErrorEmptyToken     = errors.New("empty token")
-	ErrorMissingKey     = errors.New("either key_file or key must be specified")
-	ErrorNotConfigured  = errors.New("jwt handler not configured")
-	ErrorNotSupported   = errors.New("only RSA and HMAC key encodings are supported")
-	ErrorUnknownSource  = errors.New("unknown source definition")
+	ErrorEmptyToken    = errors.New("empty token")
+	ErrorMissingKey    = errors.New("either key_file, key, jwks_url or jwks_file must be specified")
+	ErrorNotConfigured = errors.New("jwt handler not configured")
+	ErrorNotSupported  = errors.New("only RSA, ECDSA, Ed25519 and HMAC key encodings are supported")
 
 	_ AccessControl = &JWT{}
 )
@@ -35,7 +28,6 @@ var (
 type (
 	Algorithm int
 	Claims    map[string]interface{}
-	Source    int
 )
 
 type JWT struct {
@@ -43,12 +35,13 @@ type JWT struct {
 	claims         Claims
 	claimsRequired []string
 	ignoreExp      bool
+	jwks           *JWKS
 	source         Source
 	sourceKey      string
 	hmacSecret     []byte
 	name           string
 	parser         *jwt.Parser
-	pubKey         *rsa.PublicKey
+	pubKey         interface{}
 }
 
 // NewJWT parses the key and creates Validation obj which can be referenced in related handlers.
@@ -86,59 +79,55 @@ func NewJWT(algorithm, name string, claims Claims, reqClaims []string, src Sourc
 		return jwtObj, nil
 	}
 
-	pubKey, err := parsePublicPEMKey(key)
-	if err != nil && (err != jwt.ErrKeyMustBePEMEncoded || err != jwt.ErrNotRSAPublicKey) {
-		cert, err := x509.ParseCertificate(key)
-		if err != nil {
-			decKey, err := base64.StdEncoding.DecodeString(string(key))
-			if err != nil {
-				return nil, ErrorNotSupported
-			}
-			cert, err = x509.ParseCertificate(decKey)
-			if err != nil {
-				return nil, err
-			}
-		}
-		rsaPubKey, _ := cert.PublicKey.(*rsa.PublicKey)
-		pubKey = &rsa.PublicKey{N: rsaPubKey.N, E: rsaPubKey.E}
+	pubKey, err := parsePublicKey(key, algo)
+	if err != nil {
+		return nil, err
 	}
 	jwtObj.pubKey = pubKey
-	return jwtObj, err
+	return jwtObj, nil
+}
+
+// NewJWTFromJWKS creates a JWT access control which selects the validation key by the kid header of the token.
+func NewJWTFromJWKS(algorithm, name string, claims Claims, reqClaims []string, src Source, srcKey string, jwks *JWKS) (*JWT, error) {
+	if jwks == nil {
+		return nil, ErrorMissingKey
+	}
+
+	if src == Unknown {
+		return nil, ErrorUnknownSource
+	}
+
+	algo := NewAlgorithm(algorithm)
+	if algo == AlgorithmUnknown {
+		return nil, ErrorNotSupported
+	}
+
+	parser, err := newParser(algo, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWT{
+		algorithm:      algo,
+		claims:         claims,
+		claimsRequired: reqClaims,
+		jwks:           jwks,
+		name:           name,
+		parser:         parser,
+		source:         src,
+		sourceKey:      srcKey,
+	}, nil
 }
 
 // Validate reading the token from configured source and validates against the key.
 func (j *JWT) Validate(req *http.Request) error {
-	var tokenValue string
-	var err error
-
 	if j == nil {
 		return ErrorNotConfigured
 	}
 
-	switch j.source {
-	case Cookie:
-		if cookie, err := req.Cookie(j.sourceKey); err != nil && err != http.ErrNoCookie {
-			return err
-		} else if cookie != nil {
-			tokenValue = cookie.Value
-		}
-	case Header:
-		if j.sourceKey == "Authorization" {
-			if tokenValue = req.Header.Get(j.sourceKey); tokenValue == "" {
-				return ErrorEmptyToken
-			}
-
-			if tokenValue, err = getBearer(tokenValue); err != nil {
-				return err
-			}
-		} else {
-			tokenValue = req.Header.Get(j.sourceKey)
-		}
-	}
-
-	// TODO j.PostParam, j.QueryParam
-	if tokenValue == "" {
-		return ErrorEmptyToken
+	tokenValue, err := getToken(req, j.source, j.sourceKey)
+	if err != nil {
+		return err
 	}
 
 	token, err := j.parser.ParseWithClaims(tokenValue, jwt.MapClaims{}, j.getValidationKey)
@@ -151,27 +140,20 @@ func (j *JWT) Validate(req *http.Request) error {
 		return err
 	}
 
-	ctx := req.Context()
-	acMap, ok := ctx.Value(ContextAccessControlKey).(map[string]interface{})
-	if !ok {
-		acMap = make(map[string]interface{})
-	}
-	acMap[j.name] = tokenClaims
-	ctx = context.WithValue(ctx, ContextAccessControlKey, acMap)
-	*req = *req.WithContext(ctx)
-
+	setContextData(req, j.name, tokenClaims)
 	return nil
 }
 
-func (j *JWT) getValidationKey(_ *jwt.Token) (interface{}, error) {
-	switch j.algorithm {
-	case AlgorithmRSA256, AlgorithmRSA384, AlgorithmRSA512:
-		return j.pubKey, nil
-	case AlgorithmHMAC256, AlgorithmHMAC384, AlgorithmHMAC512:
+func (j *JWT) getValidationKey(token *jwt.Token) (interface{}, error) {
+	if j.jwks != nil {
+		kid, _ := token.Header["kid"].(string)
+		return j.jwks.Key(kid, j.algorithm.String())
+	}
+
+	if j.algorithm.IsHMAC() {
 		return j.hmacSecret, nil
-	default:
-		return nil, ErrorNotSupported
 	}
+	return j.pubKey, nil
 }
 
 func (j *JWT) validateClaims(token *jwt.Token) (Claims, error) {
@@ -208,14 +190,6 @@ func (j *JWT) validateClaims(token *jwt.Token) (Claims, error) {
 	return Claims(tokenClaims), nil
 }
 
-func getBearer(val string) (string, error) {
-	const bearer = "bearer "
-	if strings.HasPrefix(strings.ToLower(val), bearer) {
-		return strings.Trim(val[len(bearer):], " "), nil
-	}
-	return "", ErrorBearerRequired
-}
-
 func newParser(algo Algorithm, claims Claims) (*jwt.Parser, error) {
 	options := []jwt.ParserOption{
 		jwt.WithValidMethods([]string{algo.String()}),
@@ -245,42 +219,73 @@ func newParser(algo Algorithm, claims Claims) (*jwt.Parser, error) {
 	return jwt.NewParser(options...), nil
 }
 
-// parsePublicPEMKey tries to parse all supported publicKey variations which
-// must be given in PEM encoded format.
-func parsePublicPEMKey(key []byte) (pub *rsa.PublicKey, err error) {
-	pemBlock, _ := pem.Decode(key)
-	if pemBlock == nil {
-		decKey, err := base64.StdEncoding.DecodeString(string(key))
-		if err != nil {
-			return nil, ErrorNotSupported
-		}
-		pemBlock, _ = pem.Decode(decKey)
-		if pemBlock == nil {
-			return nil, jwt.ErrKeyMustBePEMEncoded
-		}
+// parsePublicKey tries to parse all supported public key variations: PEM encoded
+// PKCS #1, PKIX or certificates and DER encoded certificates, each optionally base64 encoded.
+// The key type must match the given algorithm.
+func parsePublicKey(key []byte, algo Algorithm) (interface{}, error) {
+	der, err := decodePublicKey(key)
+	if err != nil {
+		return nil, err
 	}
-	pubKey, pubErr := x509.ParsePKCS1PublicKey(pemBlock.Bytes)
-	if pubErr != nil {
-		pkixKey, err := x509.ParsePKIXPublicKey(pemBlock.Bytes)
-		if err != nil {
-			cert, cerr := x509.ParseCertificate(pemBlock.Bytes)
-			if cerr != nil {
-				return nil, jwt.ErrNotRSAPublicKey
-			}
-			if k, ok := cert.PublicKey.(*rsa.PublicKey); ok {
-				return k, nil
-			}
-			return nil, jwt.ErrNotRSAPublicKey
-		}
-		if k, ok := pkixKey.(*rsa.PublicKey); !ok {
-			return nil, jwt.ErrNotRSAPublicKey
-		} else {
-			pubKey = k
-		}
+
+	var pubKey interface{}
+	if rsaKey, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		pubKey = rsaKey
+	} else if pkixKey, err := x509.ParsePKIXPublicKey(der); err == nil {
+		pubKey = pkixKey
+	} else if cert, err := x509.ParseCertificate(der); err == nil {
+		pubKey = cert.PublicKey
+	} else {
+		return nil, ErrorNotSupported
+	}
+
+	if !isKeyTypeOf(pubKey, algo) {
+		return nil, fmt.Errorf("key type %T does not match the %s algorithm", pubKey, algo)
 	}
 	return pubKey, nil
 }
 
+// decodePublicKey returns the DER bytes of a PEM, base64 encoded PEM, DER or base64 encoded DER key.
+func decodePublicKey(key []byte) ([]byte, error) {
+	if pemBlock, _ := pem.Decode(key); pemBlock != nil {
+		return pemBlock.Bytes, nil
+	}
+
+	decKey, err := base64.StdEncoding.DecodeString(string(key))
+	if err != nil {
+		// plain DER
+		return key, nil
+	}
+
+	if pemBlock, _ := pem.Decode(decKey); pemBlock != nil {
+		return pemBlock.Bytes, nil
+	}
+	return decKey, nil
+}
+
+func isKeyTypeOf(key interface{}, algo Algorithm) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return algo.IsRSA()
+	case *ecdsa.PublicKey:
+		switch algo {
+		case AlgorithmECDSA256:
+			return k.Curve == elliptic.P256()
+		case AlgorithmECDSA384:
+			return k.Curve == elliptic.P384()
+		case AlgorithmECDSA512:
+			return k.Curve == elliptic.P521()
+		}
+		return false
+	case ed25519.PublicKey:
+		return algo == AlgorithmEdDSA
+	case []byte:
+		return algo.IsHMAC()
+	default:
+		return false
+	}
+}
+
 func isStringType(val interface{}) error {
 	switch val.(type) {
 	case string:
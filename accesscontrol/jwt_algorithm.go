@@ -9,25 +9,38 @@ const (
 	AlgorithmHMAC256
 	AlgorithmHMAC384
 	AlgorithmHMAC512
+	AlgorithmRSAPSS256
+	AlgorithmRSAPSS384
+	AlgorithmRSAPSS512
+	AlgorithmECDSA256
+	AlgorithmECDSA384
+	AlgorithmECDSA512
+	AlgorithmEdDSA
 )
 
+var algorithmNames = map[Algorithm]string{
+	AlgorithmRSA256:    "RS256",
+	AlgorithmRSA384:    "RS384",
+	AlgorithmRSA512:    "RS512",
+	AlgorithmHMAC256:   "HS256",
+	AlgorithmHMAC384:   "HS384",
+	AlgorithmHMAC512:   "HS512",
+	AlgorithmRSAPSS256: "PS256",
+	AlgorithmRSAPSS384: "PS384",
+	AlgorithmRSAPSS512: "PS512",
+	AlgorithmECDSA256:  "ES256",
+	AlgorithmECDSA384:  "ES384",
+	AlgorithmECDSA512:  "ES512",
+	AlgorithmEdDSA:     "EdDSA",
+}
+
 func NewAlgorithm(a string) Algorithm {
-	switch a {
-	case "RS256":
-		return AlgorithmRSA256
-	case "RS384":
-		return AlgorithmRSA384
-	case "RS512":
-		return AlgorithmRSA512
-	case "HS256":
-		return AlgorithmHMAC256
-	case "HS384":
-		return AlgorithmHMAC384
-	case "HS512":
-		return AlgorithmHMAC512
-	default:
-		return AlgorithmUnknown
+	for algo, name := range algorithmNames {
+		if name == a {
+			return algo
+		}
 	}
+	return AlgorithmUnknown
 }
 
 func (a Algorithm) IsHMAC() bool {
@@ -39,21 +52,20 @@ func (a Algorithm) IsHMAC() bool {
 	}
 }
 
-func (a Algorithm) String() string {
+// IsRSA reports whether the algorithm requires a RSA key, either with PKCS #1 v1.5 or PSS signatures.
+func (a Algorithm) IsRSA() bool {
 	switch a {
-	case AlgorithmRSA256:
-		return "RS256"
-	case AlgorithmRSA384:
-		return "RS384"
-	case AlgorithmRSA512:
-		return "RS512"
-	case AlgorithmHMAC256:
-		return "HS256"
-	case AlgorithmHMAC384:
-		return "HS384"
-	case AlgorithmHMAC512:
-		return "HS512"
+	case AlgorithmRSA256, AlgorithmRSA384, AlgorithmRSA512,
+		AlgorithmRSAPSS256, AlgorithmRSAPSS384, AlgorithmRSAPSS512:
+		return true
 	default:
-		return "Unknown"
+		return false
+	}
+}
+
+func (a Algorithm) String() string {
+	if name, ok := algorithmNames[a]; ok {
+		return name
 	}
+	return "Unknown"
 }
@@ -0,0 +1,57 @@
+package accesscontrol
+
+import (
+	"crypto/ed25519"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+// SigningMethodEdDSA implements the Ed25519 signature algorithm, see https://tools.ietf.org/html/rfc8037.
+// Expects ed25519.PrivateKey for signing and ed25519.PublicKey for verification.
+type SigningMethodEdDSA struct{}
+
+var SigningMethodEd25519 = &SigningMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEd25519.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEd25519
+	})
+}
+
+func (m *SigningMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+func (m *SigningMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	pubKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.NewInvalidKeyTypeError("ed25519.PublicKey", key)
+	}
+
+	if len(pubKey) != ed25519.PublicKeySize {
+		return &jwt.InvalidKeyError{Message: "invalid ed25519 public key size"}
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(pubKey, []byte(signingString), sig) {
+		return new(jwt.InvalidSignatureError)
+	}
+	return nil
+}
+
+func (m *SigningMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.NewInvalidKeyTypeError("ed25519.PrivateKey", key)
+	}
+
+	if len(privKey) != ed25519.PrivateKeySize {
+		return "", &jwt.InvalidKeyError{Message: "invalid ed25519 private key size"}
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(privKey, []byte(signingString))), nil
+}
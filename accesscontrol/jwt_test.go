@@ -1,14 +1,24 @@
 package accesscontrol_test
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dgrijalva/jwt-go/v4"
 
@@ -148,6 +158,152 @@ func TestJWT_Validate(t *testing.T) {
 	}
 }
 
+func TestJWT_ValidateParams(t *testing.T) {
+	key := []byte("mySecretK3y")
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "me"}).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newFormRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/?a=b", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	tests := []struct {
+		name      string
+		source    ac.Source
+		req       *http.Request
+		wantErr   bool
+		wantQuery string
+		wantBody  string
+	}{
+		{"query", ac.QueryParam, httptest.NewRequest(http.MethodGet, "/?a=b&access_token="+token, nil), false, "a=b", ""},
+		{"query /w invalid token", ac.QueryParam, httptest.NewRequest(http.MethodGet, "/?access_token=abc", nil), true, "", ""},
+		{"query /wo token", ac.QueryParam, httptest.NewRequest(http.MethodGet, "/?a=b", nil), true, "a=b", ""},
+		{"post", ac.PostParam, newFormRequest("c=d&access_token=" + token), false, "a=b", "c=d"},
+		{"post /wo token", ac.PostParam, newFormRequest("c=d"), true, "a=b", "c=d"},
+		{"post /w json", ac.PostParam, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"access_token":"`+token+`"}`)), true, "", `{"access_token":"` + token + `"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			j, err := ac.NewJWT("HS256", "test_ac", nil, nil, tt.source, "access_token", key)
+			if err != nil {
+				subT.Fatal(err)
+			}
+
+			if err = j.Validate(tt.req); (err != nil) != tt.wantErr {
+				subT.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.req.URL.RawQuery != tt.wantQuery {
+				subT.Errorf("expected query %q, got: %q", tt.wantQuery, tt.req.URL.RawQuery)
+			}
+
+			// the body is readable for the upstream request and buffered for further evaluations
+			for i := 0; i < 2; i++ {
+				b, err := ioutil.ReadAll(tt.req.Body)
+				if err != nil {
+					subT.Fatal(err)
+				}
+				if string(b) != tt.wantBody {
+					subT.Errorf("expected body %q, got: %q", tt.wantBody, string(b))
+				}
+				if tt.req.GetBody == nil {
+					break
+				}
+				tt.req.Body, _ = tt.req.GetBody()
+			}
+
+			if tt.source == ac.PostParam && tt.req.ContentLength != int64(len(tt.wantBody)) {
+				subT.Errorf("expected content-length %d, got: %d", len(tt.wantBody), tt.req.ContentLength)
+			}
+		})
+	}
+}
+
+func TestJWT_ValidateAlgorithms(t *testing.T) {
+	_, rsaKey := newRSAKeyPair()
+	ecKey256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecKey384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	ecKey521, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	edPubKey, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		method  jwt.SigningMethod
+		privKey crypto.Signer
+		pubKey  crypto.PublicKey
+	}{
+		{jwt.SigningMethodPS256, rsaKey, &rsaKey.PublicKey},
+		{jwt.SigningMethodPS384, rsaKey, &rsaKey.PublicKey},
+		{jwt.SigningMethodPS512, rsaKey, &rsaKey.PublicKey},
+		{jwt.SigningMethodES256, ecKey256, &ecKey256.PublicKey},
+		{jwt.SigningMethodES384, ecKey384, &ecKey384.PublicKey},
+		{jwt.SigningMethodES512, ecKey521, &ecKey521.PublicKey},
+		{ac.SigningMethodEd25519, edKey, edPubKey},
+	}
+
+	for _, tt := range tests {
+		token, err := jwt.NewWithClaims(tt.method, jwt.MapClaims{"sub": "me"}).SignedString(tt.privKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pkixBytes, err := x509.MarshalPKIXPublicKey(tt.pubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkixPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkixBytes})
+		certDER := newCertificate(t, tt.privKey)
+		certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+
+		for name, key := range map[string][]byte{
+			"pkix":       pkixPEM,
+			"base64 PEM": []byte(base64.StdEncoding.EncodeToString(pkixPEM)),
+			"cert":       certPEM,
+			"cert DER":   certDER,
+		} {
+			t.Run(tt.method.Alg()+"_"+name, func(subT *testing.T) {
+				j, err := ac.NewJWT(tt.method.Alg(), "test_ac", nil, nil, ac.Header, "Authorization", key)
+				if err != nil {
+					subT.Fatal(err)
+				}
+
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				if err = j.Validate(req); err != nil {
+					subT.Errorf("Validate() error = %v", err)
+				}
+			})
+		}
+
+		// the key type has to match the algorithm
+		otherAlgo := "RS256"
+		if _, ok := tt.pubKey.(*rsa.PublicKey); ok {
+			otherAlgo = "ES256"
+		}
+		if _, err = ac.NewJWT(otherAlgo, "test_ac", nil, nil, ac.Header, "Authorization", pkixPEM); err == nil {
+			t.Errorf("%s: expected a key type error for %s", tt.method.Alg(), otherAlgo)
+		}
+	}
+}
+
+func newCertificate(t *testing.T, key crypto.Signer) []byte {
+	template := &x509.Certificate{
+		NotAfter:     time.Now().Add(time.Hour),
+		NotBefore:    time.Now(),
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "couper"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
 func newRSAKeyPair() (pubKeyBytes []byte, privKey *rsa.PrivateKey) {
 	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
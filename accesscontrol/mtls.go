@@ -0,0 +1,214 @@
+package accesscontrol
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrorMTLSMissingCA          = errors.New("ca_file must contain at least one certificate")
+	ErrorMTLSExpiredCRL         = errors.New("certificate revocation list has expired")
+	ErrorMTLSMissingCertificate = errors.New("missing client certificate")
+	ErrorMTLSRevokedCertificate = errors.New("client certificate is revoked")
+	ErrorMTLSSANMismatch        = errors.New("client certificate subject alternative names do not match")
+	ErrorMTLSSubjectMismatch    = errors.New("client certificate subject does not match")
+
+	_ AccessControl = &MTLS{}
+)
+
+// MTLS represents an AC-MTLS object which validates the client certificate
+// of a tls connection against the configured certificate authorities.
+type MTLS struct {
+	caCerts        []*x509.Certificate
+	crlNextUpdate  time.Time
+	name           string
+	revoked        map[string]bool
+	roots          *x509.CertPool
+	sanPatterns    []*regexp.Regexp
+	subjectPattern *regexp.Regexp
+}
+
+// NewMTLS parses the PEM encoded ca bundle and the optional revocation list and
+// creates a MTLS object which can be referenced in related handlers.
+func NewMTLS(name string, caBundle []byte, subjectPattern string, sanPatterns []string, crl []byte) (*MTLS, error) {
+	m := &MTLS{
+		name:    name,
+		revoked: make(map[string]bool),
+		roots:   x509.NewCertPool(),
+	}
+
+	for rest := caBundle; len(rest) > 0; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		m.caCerts = append(m.caCerts, cert)
+		m.roots.AddCert(cert)
+	}
+
+	if len(m.caCerts) == 0 {
+		return nil, ErrorMTLSMissingCA
+	}
+
+	if subjectPattern != "" {
+		re, err := regexp.Compile(subjectPattern)
+		if err != nil {
+			return nil, fmt.Errorf("subject_pattern: %w", err)
+		}
+		m.subjectPattern = re
+	}
+
+	for _, pattern := range sanPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("san_patterns: %w", err)
+		}
+		m.sanPatterns = append(m.sanPatterns, re)
+	}
+
+	if len(crl) > 0 {
+		if err := m.loadCRL(crl); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// loadCRL parses the given revocation list which must be signed by one of the configured certificate authorities.
+// The revoked serial numbers are stored per issuer since serial numbers are only unique for a single ca.
+func (m *MTLS) loadCRL(crl []byte) error {
+	list, err := x509.ParseCRL(crl)
+	if err != nil {
+		return fmt.Errorf("crl_file: %w", err)
+	}
+
+	var issuer *x509.Certificate
+	for _, ca := range m.caCerts {
+		if ca.CheckCRLSignature(list) == nil {
+			issuer = ca
+			break
+		}
+	}
+	if issuer == nil {
+		return errors.New("crl_file: revocation list is not signed by a configured certificate authority")
+	}
+
+	if list.HasExpired(time.Now()) {
+		return errors.New("crl_file: revocation list has expired")
+	}
+
+	for _, entry := range list.TBSCertList.RevokedCertificates {
+		m.revoked[revocationKey(issuer.RawSubject, entry.SerialNumber.String())] = true
+	}
+	m.crlNextUpdate = list.TBSCertList.NextUpdate
+	return nil
+}
+
+// Validate implements the AccessControl interface.
+func (m *MTLS) Validate(req *http.Request) error {
+	if m == nil {
+		return ErrorNotConfigured
+	}
+
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return ErrorMTLSMissingCertificate
+	}
+
+	cert := req.TLS.PeerCertificates[0]
+
+	intermediates := x509.NewCertPool()
+	for _, c := range req.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		Roots:         m.roots,
+	}); err != nil {
+		return err
+	}
+
+	// an expired list may miss recent revocations
+	if !m.crlNextUpdate.IsZero() && !time.Now().Before(m.crlNextUpdate) {
+		return ErrorMTLSExpiredCRL
+	}
+
+	if m.revoked[revocationKey(cert.RawIssuer, cert.SerialNumber.String())] {
+		return ErrorMTLSRevokedCertificate
+	}
+
+	if m.subjectPattern != nil && !m.subjectPattern.MatchString(cert.Subject.String()) {
+		return ErrorMTLSSubjectMismatch
+	}
+
+	sans := subjectAltNames(cert)
+	if len(m.sanPatterns) > 0 && !m.matchSAN(sans) {
+		return ErrorMTLSSANMismatch
+	}
+
+	ctx := req.Context()
+	acMap, ok := ctx.Value(ContextAccessControlKey).(map[string]interface{})
+	if !ok {
+		acMap = make(map[string]interface{})
+	}
+	certInfo := Claims{
+		"common_name": cert.Subject.CommonName,
+		"fingerprint": fmt.Sprintf("%x", sha256.Sum256(cert.Raw)),
+		"issuer":      cert.Issuer.String(),
+		"serial":      cert.SerialNumber.Text(16),
+		"subject":     cert.Subject.String(),
+	}
+	if len(sans) > 0 {
+		certInfo["san"] = sans
+	}
+	acMap[m.name] = certInfo
+	ctx = context.WithValue(ctx, ContextAccessControlKey, acMap)
+	*req = *req.WithContext(ctx)
+
+	return nil
+}
+
+func (m *MTLS) matchSAN(sans []string) bool {
+	for _, re := range m.sanPatterns {
+		for _, san := range sans {
+			if re.MatchString(san) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func revocationKey(rawIssuer []byte, serial string) string {
+	return string(rawIssuer) + "/" + serial
+}
+
+func subjectAltNames(cert *x509.Certificate) []string {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	return sans
+}
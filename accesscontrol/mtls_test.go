@@ -0,0 +1,189 @@
+package accesscontrol_test
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ac "github.com/avenga/couper/accesscontrol"
+	"github.com/avenga/couper/internal/test"
+)
+
+func TestMTLS_Validate(t *testing.T) {
+	helper := test.New(t)
+
+	ca := helper.NewCA("couper test ca")
+	otherCA := helper.NewCA("other ca")
+
+	client := helper.NewCertificate(ca, "client-a", "client-a.couper.io")
+	revokedClient := helper.NewCertificate(ca, "client-b", "client-b.couper.io")
+	foreignClient := helper.NewCertificate(otherCA, "client-a", "client-a.couper.io")
+
+	crl, err := ca.Cert.CreateCRL(rand.Reader, ca.Key, []pkix.RevokedCertificate{
+		{SerialNumber: revokedClient.Cert.SerialNumber, RevocationTime: time.Now()},
+	}, time.Now(), time.Now().Add(time.Hour))
+	helper.Must(err)
+
+	newReq := func(certs ...*test.Certificate) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "https://couper.io/", nil)
+		if len(certs) == 0 {
+			return req
+		}
+		req.TLS = &tls.ConnectionState{}
+		for _, c := range certs {
+			req.TLS.PeerCertificates = append(req.TLS.PeerCertificates, c.Cert)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name           string
+		subjectPattern string
+		sanPatterns    []string
+		req            *http.Request
+		wantErr        error
+	}{
+		{"no tls", "", nil, httptest.NewRequest(http.MethodGet, "http://couper.io/", nil), ac.ErrorMTLSMissingCertificate},
+		{"no certificate", "", nil, newReq(), ac.ErrorMTLSMissingCertificate},
+		{"valid certificate", "", nil, newReq(client), nil},
+		{"unknown ca", "", nil, newReq(foreignClient), x509.UnknownAuthorityError{}},
+		{"revoked certificate", "", nil, newReq(revokedClient), ac.ErrorMTLSRevokedCertificate},
+		{"subject match", "^CN=client-a$", nil, newReq(client), nil},
+		{"subject mismatch", "^CN=client-b$", nil, newReq(client), ac.ErrorMTLSSubjectMismatch},
+		{"san match", "", []string{`^client-b\.`, `^client-a\.couper\.io$`}, newReq(client), nil},
+		{"san mismatch", "", []string{`^client-b\.`}, newReq(client), ac.ErrorMTLSSANMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			m, err := ac.NewMTLS("test_mtls", ca.CertPEM, tt.subjectPattern, tt.sanPatterns, crl)
+			if err != nil {
+				subT.Fatal(err)
+			}
+
+			err = m.Validate(tt.req)
+			if tt.wantErr == nil && err != nil {
+				subT.Fatalf("Validate() unexpected error: %v", err)
+			}
+
+			if tt.wantErr != nil {
+				if err == nil {
+					subT.Fatalf("Validate() expected error: %v", tt.wantErr)
+				}
+				if _, ok := tt.wantErr.(x509.UnknownAuthorityError); ok {
+					if _, ok = err.(x509.UnknownAuthorityError); !ok {
+						subT.Errorf("Validate() expected an unknown authority error, got: %v", err)
+					}
+				} else if err != tt.wantErr {
+					subT.Errorf("Validate() error = %v, want: %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			acMap := tt.req.Context().Value(ac.ContextAccessControlKey).(map[string]interface{})
+			certInfo, ok := acMap["test_mtls"].(ac.Claims)
+			if !ok {
+				subT.Fatal("Expected certificate information within request context")
+			}
+
+			if certInfo["common_name"] != "client-a" {
+				subT.Errorf("Expected common_name %q, got: %v", "client-a", certInfo["common_name"])
+			}
+
+			if certInfo["serial"] != client.Cert.SerialNumber.Text(16) {
+				subT.Errorf("Expected serial %q, got: %v", client.Cert.SerialNumber.Text(16), certInfo["serial"])
+			}
+
+			if sans, ok := certInfo["san"].([]string); !ok || len(sans) != 1 || sans[0] != "client-a.couper.io" {
+				subT.Errorf("Expected san %q, got: %v", "client-a.couper.io", certInfo["san"])
+			}
+		})
+	}
+}
+
+func TestNewMTLS(t *testing.T) {
+	helper := test.New(t)
+
+	ca := helper.NewCA("couper test ca")
+	otherCA := helper.NewCA("other ca")
+
+	foreignCRL, err := otherCA.Cert.CreateCRL(rand.Reader, otherCA.Key, nil, time.Now(), time.Now().Add(time.Hour))
+	helper.Must(err)
+
+	if _, err = ac.NewMTLS("test", nil, "", nil, nil); err != ac.ErrorMTLSMissingCA {
+		t.Errorf("Expected missing ca error, got: %v", err)
+	}
+
+	if _, err = ac.NewMTLS("test", ca.CertPEM, "(", nil, nil); err == nil {
+		t.Error("Expected an invalid subject_pattern error")
+	}
+
+	if _, err = ac.NewMTLS("test", ca.CertPEM, "", nil, foreignCRL); err == nil {
+		t.Error("Expected an invalid crl signature error")
+	}
+}
+
+func TestMTLS_ValidateRevocation(t *testing.T) {
+	helper := test.New(t)
+
+	ca := helper.NewCA("couper test ca")
+	otherCA := helper.NewCA("other ca")
+
+	revokedClient := helper.NewCertificate(ca, "client-a")
+
+	// same serial number by a different issuer
+	tpl := &x509.Certificate{
+		SerialNumber: revokedClient.Cert.SerialNumber,
+		Subject:      pkix.Name{CommonName: "client-b"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, otherCA.Cert, &revokedClient.Key.PublicKey, otherCA.Key)
+	helper.Must(err)
+	otherClient, err := x509.ParseCertificate(der)
+	helper.Must(err)
+
+	caBundle := append(append([]byte{}, ca.CertPEM...), otherCA.CertPEM...)
+	revoked := []pkix.RevokedCertificate{
+		{SerialNumber: revokedClient.Cert.SerialNumber, RevocationTime: time.Now()},
+	}
+
+	newReq := func(cert *x509.Certificate) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "https://couper.io/", nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		return req
+	}
+
+	crl, err := ca.Cert.CreateCRL(rand.Reader, ca.Key, revoked, time.Now(), time.Now().Add(time.Hour))
+	helper.Must(err)
+
+	m, err := ac.NewMTLS("test_mtls", caBundle, "", nil, crl)
+	helper.Must(err)
+
+	if err = m.Validate(newReq(revokedClient.Cert)); err != ac.ErrorMTLSRevokedCertificate {
+		t.Errorf("Expected revoked certificate error, got: %v", err)
+	}
+
+	if err = m.Validate(newReq(otherClient)); err != nil {
+		t.Errorf("Expected a valid certificate of the other ca, got: %v", err)
+	}
+
+	crl, err = ca.Cert.CreateCRL(rand.Reader, ca.Key, revoked, time.Now(), time.Now().Add(time.Second*2))
+	helper.Must(err)
+
+	m, err = ac.NewMTLS("test_mtls", caBundle, "", nil, crl)
+	helper.Must(err)
+
+	time.Sleep(time.Second * 2)
+
+	if err = m.Validate(newReq(otherClient)); err != ac.ErrorMTLSExpiredCRL {
+		t.Errorf("Expected expired crl error, got: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package accesscontrol
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIntrospectionTTL is the max age of a cached introspection response.
+	DefaultIntrospectionTTL = time.Minute
+	// maxIntrospectionCacheSize limits the cached responses, the least recently used ones get evicted.
+	maxIntrospectionCacheSize = 10000
+	// maxIntrospectionBodySize limits the read introspection response body.
+	maxIntrospectionBodySize = 1 << 20
+)
+
+var (
+	ErrorIntrospectionFailed = errors.New("token introspection failed")
+	ErrorTokenInactive       = errors.New("token is not active")
+
+	_ AccessControl = &OAuth2Introspection{}
+)
+
+type introspectionResult struct {
+	active  bool
+	data    Claims
+	expires time.Time
+}
+
+type introspectionEntry struct {
+	key    [sha256.Size]byte
+	result *introspectionResult
+}
+
+// OAuth2Introspection validates opaque tokens with an introspection endpoint, see https://tools.ietf.org/html/rfc7662.
+// The endpoint is requested via the given backend round tripper and the responses are cached for the ttl,
+// active tokens at most until their expiration.
+type OAuth2Introspection struct {
+	backend      http.RoundTripper
+	clientID     string
+	clientSecret string
+	name         string
+	source       Source
+	sourceKey    string
+	ttl          time.Duration
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]*list.Element
+	lru   *list.List
+}
+
+func NewOAuth2Introspection(name string, backend http.RoundTripper, clientID, clientSecret string, src Source, srcKey string, ttl time.Duration) (*OAuth2Introspection, error) {
+	if backend == nil {
+		return nil, errors.New("missing introspection backend")
+	}
+
+	if src == Unknown {
+		return nil, ErrorUnknownSource
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultIntrospectionTTL
+	}
+
+	return &OAuth2Introspection{
+		backend:      backend,
+		cache:        make(map[[sha256.Size]byte]*list.Element),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		lru:          list.New(),
+		name:         name,
+		source:       src,
+		sourceKey:    srcKey,
+		ttl:          ttl,
+	}, nil
+}
+
+// Validate reads the token from the configured source and checks its introspection state.
+func (o *OAuth2Introspection) Validate(req *http.Request) error {
+	token, err := getToken(req, o.source, o.sourceKey)
+	if err != nil {
+		return err
+	}
+
+	key := sha256.Sum256([]byte(token))
+	result := o.cached(key)
+	if result == nil {
+		if result, err = o.introspect(req.Context(), token); err != nil {
+			return err
+		}
+		o.store(key, result)
+	}
+
+	if !result.active {
+		return ErrorTokenInactive
+	}
+
+	setContextData(req, o.name, result.data)
+	return nil
+}
+
+func (o *OAuth2Introspection) cached(key [sha256.Size]byte) *introspectionResult {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	elem, exist := o.cache[key]
+	if !exist {
+		return nil
+	}
+	result := elem.Value.(*introspectionEntry).result
+	if !time.Now().Before(result.expires) {
+		o.lru.Remove(elem)
+		delete(o.cache, key)
+		return nil
+	}
+	o.lru.MoveToFront(elem)
+	return result
+}
+
+func (o *OAuth2Introspection) store(key [sha256.Size]byte, result *introspectionResult) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if elem, exist := o.cache[key]; exist {
+		elem.Value.(*introspectionEntry).result = result
+		o.lru.MoveToFront(elem)
+		return
+	}
+
+	for o.lru.Len() >= maxIntrospectionCacheSize {
+		oldest := o.lru.Back()
+		o.lru.Remove(oldest)
+		delete(o.cache, oldest.Value.(*introspectionEntry).key)
+	}
+	o.cache[key] = o.lru.PushFront(&introspectionEntry{key: key, result: result})
+}
+
+// introspect requests the introspection endpoint with the given token.
+// The backend request gets canceled with the context of the client request.
+func (o *OAuth2Introspection) introspect(ctx context.Context, token string) (*introspectionResult, error) {
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("token_type_hint", "access_token")
+
+	// the origin and path are configured by the backend
+	outreq, err := http.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	outreq = outreq.WithContext(ctx)
+	outreq.Header.Set("Accept", "application/json")
+	outreq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if o.clientID != "" {
+		outreq.SetBasicAuth(url.QueryEscape(o.clientID), url.QueryEscape(o.clientSecret))
+	}
+
+	res, err := o.backend.RoundTrip(outreq)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorIntrospectionFailed, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: unexpected status code: %d", ErrorIntrospectionFailed, res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxIntrospectionBodySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorIntrospectionFailed, err)
+	}
+	if len(body) > maxIntrospectionBodySize {
+		return nil, fmt.Errorf("%w: response body too large", ErrorIntrospectionFailed)
+	}
+
+	var data map[string]interface{}
+	if err = json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorIntrospectionFailed, err)
+	}
+
+	result := &introspectionResult{
+		data:    Claims(data),
+		expires: time.Now().Add(o.ttl),
+	}
+	result.active, _ = data["active"].(bool)
+
+	if exp, ok := data["exp"].(float64); ok && result.active {
+		if expires := time.Unix(int64(exp), 0); expires.Before(result.expires) {
+			result.expires = expires
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,45 @@
+package accesscontrol
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOAuth2Introspection_CacheSize(t *testing.T) {
+	o, err := NewOAuth2Introspection("intro", http.DefaultTransport, "", "", Header, "Authorization", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := func(i int) [sha256.Size]byte {
+		return sha256.Sum256([]byte(fmt.Sprintf("token-%d", i)))
+	}
+	result := &introspectionResult{active: true, expires: time.Now().Add(time.Minute)}
+
+	for i := 0; i < maxIntrospectionCacheSize; i++ {
+		o.store(key(i), result)
+	}
+
+	// the first entry is used recently, the second one is the least recently used
+	if o.cached(key(0)) == nil {
+		t.Fatal("expected a cached result")
+	}
+
+	o.store(key(maxIntrospectionCacheSize), result)
+
+	if n := len(o.cache); n != maxIntrospectionCacheSize {
+		t.Errorf("expected %d cache entries, got: %d", maxIntrospectionCacheSize, n)
+	}
+	if o.cached(key(maxIntrospectionCacheSize)) == nil {
+		t.Error("expected the new entry to be cached")
+	}
+	if o.cached(key(0)) == nil {
+		t.Error("expected the recently used entry to be kept")
+	}
+	if o.cached(key(1)) != nil {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+}
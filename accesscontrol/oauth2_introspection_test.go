@@ -0,0 +1,117 @@
+package accesscontrol_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	ac "github.com/avenga/couper/accesscontrol"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestOAuth2Introspection_Validate(t *testing.T) {
+	requests := make(map[string]int)
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// client credentials are form-urlencoded, see https://tools.ietf.org/html/rfc6749#section-2.3.1
+		if user, pass, ok := req.BasicAuth(); !ok || user != "my+client" || pass != "s3cr3t" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if err := req.ParseForm(); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		token := req.PostForm.Get("token")
+		requests[token]++
+
+		var response map[string]interface{}
+		switch token {
+		case "active":
+			response = map[string]interface{}{"active": true, "sub": "me", "scope": "read", "exp": time.Now().Add(time.Hour).Unix()}
+		case "expiring":
+			response = map[string]interface{}{"active": true, "exp": time.Now().Add(-time.Second).Unix()}
+		case "error":
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		case "large":
+			response = map[string]interface{}{"active": true, "data": strings.Repeat("a", 1<<20)}
+		default:
+			response = map[string]interface{}{"active": false}
+		}
+		_ = json.NewEncoder(rw).Encode(response)
+	}))
+	defer origin.Close()
+
+	originURL, err := url.Parse(origin.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req.URL.Scheme, req.URL.Host = originURL.Scheme, originURL.Host
+		return http.DefaultTransport.RoundTrip(req)
+	})
+
+	introspection, err := ac.NewOAuth2Introspection("intro", backend, "my client", "s3cr3t", ac.Header, "Authorization", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantErr  error
+		requests int
+	}{
+		{"active", "active", nil, 1},
+		{"active cached", "active", nil, 1},
+		{"inactive", "inactive", ac.ErrorTokenInactive, 1},
+		{"inactive cached", "inactive", ac.ErrorTokenInactive, 1},
+		{"expired cache entry", "expiring", nil, 1},
+		{"expired cache entry again", "expiring", nil, 2},
+		{"introspection error", "error", ac.ErrorIntrospectionFailed, 1},
+		{"introspection error not cached", "error", ac.ErrorIntrospectionFailed, 2},
+		{"response body too large", "large", ac.ErrorIntrospectionFailed, 1},
+		{"missing token", "", ac.ErrorEmptyToken, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			err := introspection.Validate(req)
+			if tt.wantErr == nil && err != nil {
+				subT.Fatalf("unexpected error: %v", err)
+			} else if tt.wantErr != nil && (err == nil || !errors.Is(err, tt.wantErr)) {
+				subT.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+
+			if requests[tt.token] != tt.requests {
+				subT.Errorf("expected %d introspection requests, got: %d", tt.requests, requests[tt.token])
+			}
+
+			if tt.token == "active" {
+				acMap := req.Context().Value(ac.ContextAccessControlKey).(map[string]interface{})
+				data := acMap["intro"].(ac.Claims)
+				if data["sub"] != "me" || data["scope"] != "read" {
+					subT.Errorf("expected the introspection response in the request context, got: %v", data)
+				}
+			}
+		})
+	}
+}
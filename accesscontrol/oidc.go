@@ -0,0 +1,481 @@
+package accesscontrol
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+const (
+	// DefaultOIDCSessionTTL is the max age of a session cookie.
+	DefaultOIDCSessionTTL = time.Hour
+	// minSessionSecretLength ensures a sufficient key length for the session encryption.
+	minSessionSecretLength = 32
+
+	oidcStateTTL = time.Minute * 10
+	oidcTimeout  = time.Second * 10
+)
+
+var (
+	ErrorMissingSession = errors.New("missing or expired session")
+	ErrorOIDCFailed     = errors.New("oidc authentication failed")
+
+	_ AccessControl = &OIDC{}
+)
+
+// OIDCOptions configures an OpenID Connect relying party. The provider endpoints are
+// read from the ConfigurationURL, explicitly given endpoints take precedence.
+type OIDCOptions struct {
+	AuthorizationEndpoint string
+	ClientID              string
+	ClientSecret          string
+	ConfigurationURL      string
+	CookieName            string
+	Issuer                string
+	JWKSURL               string
+	LogoutRedirectURI     string
+	RedirectURI           string
+	Scopes                []string
+	SessionSecret         string
+	SessionTTL            time.Duration
+	TokenEndpoint         string
+}
+
+// OIDCRedirectError is returned for unauthenticated browser requests which have to
+// be redirected to the authorization endpoint of the provider.
+type OIDCRedirectError struct {
+	Cookie *http.Cookie
+	URL    string
+}
+
+func (e *OIDCRedirectError) Error() string {
+	return "redirect to the authorization endpoint"
+}
+
+type oidcProvider struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	Issuer                string `json:"issuer"`
+	JWKSURI               string `json:"jwks_uri"`
+	TokenEndpoint         string `json:"token_endpoint"`
+
+	jwks *JWKS
+}
+
+// oidcSession keeps the cookie small and does not disclose further claims of the ID token.
+type oidcSession struct {
+	Expires int64  `json:"exp"`
+	Subject string `json:"sub"`
+}
+
+type oidcState struct {
+	Expires  int64  `json:"exp"`
+	Nonce    string `json:"nonce"`
+	Redirect string `json:"redirect"`
+	State    string `json:"state"`
+	Verifier string `json:"verifier"`
+}
+
+// OIDC authenticates browser sessions with the authorization code flow and PKCE,
+// see https://openid.net/specs/openid-connect-core-1_0.html. The subject of the validated
+// ID token is kept in an encrypted session cookie.
+type OIDC struct {
+	client *http.Client
+	codec  *sessionCodec
+	name   string
+	opts   OIDCOptions
+
+	mu       sync.Mutex
+	provider *oidcProvider
+}
+
+func NewOIDC(name string, opts OIDCOptions) (*OIDC, error) {
+	if opts.ClientID == "" {
+		return nil, errors.New("missing client_id")
+	}
+
+	if opts.RedirectURI == "" {
+		return nil, errors.New("missing redirect_uri")
+	}
+
+	if len(opts.SessionSecret) < minSessionSecretLength {
+		return nil, fmt.Errorf("session_secret must have at least %d characters", minSessionSecretLength)
+	}
+
+	if opts.ConfigurationURL == "" && (opts.AuthorizationEndpoint == "" || opts.TokenEndpoint == "" ||
+		opts.Issuer == "" || opts.JWKSURL == "") {
+		return nil, errors.New("either configuration_url or issuer, authorization_endpoint, token_endpoint and jwks_url must be specified")
+	}
+
+	if opts.CookieName == "" {
+		opts.CookieName = "_couper_" + name
+	}
+
+	if opts.SessionTTL <= 0 {
+		opts.SessionTTL = DefaultOIDCSessionTTL
+	}
+
+	hasOpenID := false
+	for _, scope := range opts.Scopes {
+		hasOpenID = hasOpenID || scope == "openid"
+	}
+	if !hasOpenID {
+		opts.Scopes = append([]string{"openid"}, opts.Scopes...)
+	}
+
+	codec, err := newSessionCodec(opts.SessionSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDC{
+		client: &http.Client{Timeout: oidcTimeout},
+		codec:  codec,
+		name:   name,
+		opts:   opts,
+	}, nil
+}
+
+// Validate accepts requests with a valid session cookie. Browser requests without a session
+// are redirected to the authorization endpoint.
+func (o *OIDC) Validate(req *http.Request) error {
+	if cookie, err := req.Cookie(o.opts.CookieName); err == nil {
+		var session oidcSession
+		if err = o.codec.decode(o.opts.CookieName, cookie.Value, &session); err == nil &&
+			time.Now().Unix() < session.Expires {
+			setContextData(req, o.name, Claims{"sub": session.Subject})
+			return nil
+		}
+	}
+
+	if !acceptsHTML(req) {
+		return ErrorMissingSession
+	}
+	return o.newRedirect(req)
+}
+
+// RedirectPath returns the path of the callback route.
+func (o *OIDC) RedirectPath() string {
+	if u, err := url.Parse(o.opts.RedirectURI); err == nil && u.Path != "" {
+		return u.Path
+	}
+	return o.opts.RedirectURI
+}
+
+// ServeCallback exchanges the authorization code, validates the ID token and starts the session.
+func (o *OIDC) ServeCallback(rw http.ResponseWriter, req *http.Request) error {
+	stateCookie, err := req.Cookie(o.stateCookieName())
+	if err != nil {
+		return fmt.Errorf("%w: missing state", ErrorOIDCFailed)
+	}
+
+	var state oidcState
+	if err = o.codec.decode(o.stateCookieName(), stateCookie.Value, &state); err != nil || time.Now().Unix() >= state.Expires {
+		return fmt.Errorf("%w: invalid state", ErrorOIDCFailed)
+	}
+
+	query := req.URL.Query()
+	if e := query.Get("error"); e != "" {
+		return fmt.Errorf("%w: %s", ErrorOIDCFailed, e)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		return fmt.Errorf("%w: state mismatch", ErrorOIDCFailed)
+	}
+
+	provider, err := o.getProvider()
+	if err != nil {
+		return err
+	}
+
+	idToken, err := o.exchange(req.Context(), provider, query.Get("code"), o.redirectURI(req), state.Verifier)
+	if err != nil {
+		return err
+	}
+
+	claims, err := o.validateIDToken(provider, idToken, state.Nonce)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrorOIDCFailed, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	value, err := o.codec.encode(o.opts.CookieName, &oidcSession{
+		Expires: time.Now().Add(o.opts.SessionTTL).Unix(),
+		Subject: subject,
+	})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(rw, o.newCookie(req, o.opts.CookieName, value, o.opts.SessionTTL))
+	http.SetCookie(rw, o.newCookie(req, o.stateCookieName(), "", -1))
+
+	http.Redirect(rw, req, localRedirect(state.Redirect), http.StatusSeeOther)
+	return nil
+}
+
+// ServeLogout removes the session cookie.
+func (o *OIDC) ServeLogout(rw http.ResponseWriter, req *http.Request) {
+	http.SetCookie(rw, o.newCookie(req, o.opts.CookieName, "", -1))
+
+	redirect := o.opts.LogoutRedirectURI
+	if redirect == "" {
+		redirect = "/"
+	}
+	http.Redirect(rw, req, redirect, http.StatusSeeOther)
+}
+
+func (o *OIDC) newRedirect(req *http.Request) error {
+	provider, err := o.getProvider()
+	if err != nil {
+		return err
+	}
+
+	state := &oidcState{
+		Expires:  time.Now().Add(oidcStateTTL).Unix(),
+		Nonce:    randomString(),
+		Redirect: req.URL.RequestURI(),
+		State:    randomString(),
+		Verifier: randomString(),
+	}
+
+	value, err := o.codec.encode(o.stateCookieName(), state)
+	if err != nil {
+		return err
+	}
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"client_id":             {o.opts.ClientID},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+		"nonce":                 {state.Nonce},
+		"redirect_uri":          {o.redirectURI(req)},
+		"response_type":         {"code"},
+		"scope":                 {strings.Join(o.opts.Scopes, " ")},
+		"state":                 {state.State},
+	}
+
+	authURL := provider.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + query.Encode()
+	} else {
+		authURL += "?" + query.Encode()
+	}
+
+	return &OIDCRedirectError{
+		Cookie: o.newCookie(req, o.stateCookieName(), value, oidcStateTTL),
+		URL:    authURL,
+	}
+}
+
+// exchange requests the token endpoint and returns the ID token.
+func (o *OIDC) exchange(ctx context.Context, provider *oidcProvider, code, redirectURI, verifier string) (string, error) {
+	form := url.Values{
+		"code":          {code},
+		"code_verifier": {verifier},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {redirectURI},
+	}
+	if o.opts.ClientSecret == "" {
+		form.Set("client_id", o.opts.ClientID)
+	}
+
+	outreq, err := http.NewRequest(http.MethodPost, provider.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	outreq = outreq.WithContext(ctx)
+	outreq.Header.Set("Accept", "application/json")
+	outreq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if o.opts.ClientSecret != "" {
+		outreq.SetBasicAuth(url.QueryEscape(o.opts.ClientID), url.QueryEscape(o.opts.ClientSecret))
+	}
+
+	res, err := o.client.Do(outreq)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrorOIDCFailed, err)
+	}
+	defer res.Body.Close()
+
+	var tokenResponse struct {
+		Error   string `json:"error"`
+		IDToken string `json:"id_token"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&tokenResponse); err != nil {
+		return "", fmt.Errorf("%w: token response: %v", ErrorOIDCFailed, err)
+	}
+
+	if res.StatusCode != http.StatusOK || tokenResponse.IDToken == "" {
+		return "", fmt.Errorf("%w: token endpoint: status %d %s", ErrorOIDCFailed, res.StatusCode, tokenResponse.Error)
+	}
+	return tokenResponse.IDToken, nil
+}
+
+func (o *OIDC) validateIDToken(provider *oidcProvider, idToken, nonce string) (Claims, error) {
+	var methods []string
+	for algo, name := range algorithmNames {
+		if !algo.IsHMAC() {
+			methods = append(methods, name)
+		}
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(methods),
+		jwt.WithLeeway(time.Second),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(o.opts.ClientID),
+	)
+
+	token, err := parser.ParseWithClaims(idToken, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return provider.jwks.Key(kid, token.Method.Alg())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	for _, claim := range []string{"aud", "exp"} {
+		if _, exist := claims[claim]; !exist {
+			return nil, fmt.Errorf("missing %s claim", claim)
+		}
+	}
+
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("missing sub claim")
+	}
+
+	if n, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(n), []byte(nonce)) != 1 {
+		return nil, errors.New("nonce mismatch")
+	}
+	return Claims(claims), nil
+}
+
+// getProvider returns the provider configuration, discovery results are kept after the first success.
+func (o *OIDC) getProvider() (*oidcProvider, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.provider != nil {
+		return o.provider, nil
+	}
+
+	provider := &oidcProvider{}
+	if o.opts.ConfigurationURL != "" {
+		res, err := o.client.Get(o.opts.ConfigurationURL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: configuration: %v", ErrorOIDCFailed, err)
+		}
+		defer res.Body.Close()
+
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: configuration: %v", ErrorOIDCFailed, err)
+		}
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%w: configuration: unexpected status code: %d", ErrorOIDCFailed, res.StatusCode)
+		}
+
+		if err = json.Unmarshal(b, provider); err != nil {
+			return nil, fmt.Errorf("%w: configuration: %v", ErrorOIDCFailed, err)
+		}
+	}
+
+	for _, override := range []struct {
+		value  string
+		target *string
+	}{
+		{o.opts.AuthorizationEndpoint, &provider.AuthorizationEndpoint},
+		{o.opts.Issuer, &provider.Issuer},
+		{o.opts.JWKSURL, &provider.JWKSURI},
+		{o.opts.TokenEndpoint, &provider.TokenEndpoint},
+	} {
+		if override.value != "" {
+			*override.target = override.value
+		}
+	}
+
+	jwks, err := NewJWKS(provider.JWKSURI, "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrorOIDCFailed, err)
+	}
+	provider.jwks = jwks
+
+	o.provider = provider
+	return provider, nil
+}
+
+func (o *OIDC) redirectURI(req *http.Request) string {
+	if strings.HasPrefix(o.opts.RedirectURI, "http://") || strings.HasPrefix(o.opts.RedirectURI, "https://") {
+		return o.opts.RedirectURI
+	}
+
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host + o.opts.RedirectURI
+}
+
+func (o *OIDC) stateCookieName() string {
+	return o.opts.CookieName + "_state"
+}
+
+func (o *OIDC) newCookie(req *http.Request, name, value string, maxAge time.Duration) *http.Cookie {
+	cookie := &http.Cookie{
+		HttpOnly: true,
+		Name:     name,
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+		Secure:   strings.HasPrefix(o.redirectURI(req), "https://"),
+		Value:    value,
+	}
+
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	} else {
+		cookie.MaxAge = int(maxAge.Seconds())
+	}
+	return cookie
+}
+
+// localRedirect returns the given redirect target if it is a path on the same origin, "/" otherwise.
+// Browsers treat backslashes like slashes, so "/\evil.com" would lead to another host.
+func localRedirect(redirect string) string {
+	u, err := url.Parse(redirect)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil ||
+		!strings.HasPrefix(u.Path, "/") || strings.HasPrefix(u.Path, "//") || strings.Contains(u.Path, "\\") {
+		return "/"
+	}
+	return redirect
+}
+
+// acceptsHTML reports whether the request is a browser navigation which could follow a redirect.
+func acceptsHTML(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return strings.Contains(req.Header.Get("Accept"), "text/html")
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
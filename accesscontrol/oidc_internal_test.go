@@ -0,0 +1,33 @@
+package accesscontrol
+
+import (
+	"testing"
+)
+
+func TestLocalRedirect(t *testing.T) {
+	tests := []struct {
+		redirect string
+		want     string
+	}{
+		{"/", "/"},
+		{"/app?tab=1#top", "/app?tab=1#top"},
+		{"", "/"},
+		{"app", "/"},
+		{"//evil.com", "/"},
+		{"/\\evil.com", "/"},
+		{"/\\/evil.com", "/"},
+		{"\\\\evil.com", "/"},
+		{"/app%5C..%5Cevil", "/"},
+		{"https://evil.com/", "/"},
+		{"https:/evil.com", "/"},
+		{"javascript:alert(1)", "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.redirect, func(subT *testing.T) {
+			if got := localRedirect(tt.redirect); got != tt.want {
+				subT.Errorf("localRedirect(%q) = %q, want %q", tt.redirect, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package accesscontrol
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+var ErrorInvalidSession = errors.New("invalid session")
+
+// sessionCodec encrypts and authenticates cookie values with AES-256-GCM.
+// The cookie name is bound as additional data, so values cannot be moved between cookies.
+type sessionCodec struct {
+	aead cipher.AEAD
+}
+
+func newSessionCodec(secret string) (*sessionCodec, error) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionCodec{aead: aead}, nil
+}
+
+func (c *sessionCodec) encode(name string, v interface{}) (string, error) {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, plain, []byte(name))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (c *sessionCodec) decode(name, value string, v interface{}) error {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return ErrorInvalidSession
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return ErrorInvalidSession
+	}
+
+	if err = json.Unmarshal(plain, v); err != nil {
+		return ErrorInvalidSession
+	}
+	return nil
+}
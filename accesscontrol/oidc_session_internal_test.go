@@ -0,0 +1,43 @@
+package accesscontrol
+
+import (
+	"testing"
+)
+
+func TestSessionCodec(t *testing.T) {
+	codec, err := newSessionCodec("a-session-secret-with-at-least-32-characters")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := codec.encode("session", &oidcSession{Expires: 42, Subject: "john"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var session oidcSession
+	if err = codec.decode("session", value, &session); err != nil {
+		t.Fatal(err)
+	}
+	if session.Subject != "john" || session.Expires != 42 {
+		t.Errorf("unexpected session: %#v", session)
+	}
+
+	if err = codec.decode("other", value, &session); err != ErrorInvalidSession {
+		t.Errorf("expected an invalid session for another cookie name, got: %v", err)
+	}
+
+	tampered := []byte(value)
+	tampered[len(tampered)/2] ^= 1
+	if err = codec.decode("session", string(tampered), &session); err != ErrorInvalidSession {
+		t.Errorf("expected an invalid session for a tampered value, got: %v", err)
+	}
+
+	otherCodec, err := newSessionCodec("another-session-secret-with-32-characters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = otherCodec.decode("session", value, &session); err != ErrorInvalidSession {
+		t.Errorf("expected an invalid session for another secret, got: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package accesscontrol
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	Unknown Source = iota - 1
+	Cookie
+	Header
+	PostParam
+	QueryParam
+)
+
+// maxPostParamBodySize equals the limit of url-encoded form bodies of http.Request.ParseForm.
+const maxPostParamBodySize = 10 << 20
+
+var (
+	ErrorBearerRequired = errors.New("authorization header value must start with 'Bearer '")
+	ErrorBodyTooLarge   = errors.New("request body too large")
+	ErrorUnknownSource  = errors.New("unknown source definition")
+)
+
+type Source int
+
+// getToken reads the token value from the given source. Query and post parameters are removed from the request.
+func getToken(req *http.Request, src Source, key string) (string, error) {
+	var tokenValue string
+	var err error
+
+	switch src {
+	case Cookie:
+		if cookie, err := req.Cookie(key); err != nil && err != http.ErrNoCookie {
+			return "", err
+		} else if cookie != nil {
+			tokenValue = cookie.Value
+		}
+	case Header:
+		if key == "Authorization" {
+			if tokenValue = req.Header.Get(key); tokenValue == "" {
+				return "", ErrorEmptyToken
+			}
+
+			if tokenValue, err = getBearer(tokenValue); err != nil {
+				return "", err
+			}
+		} else {
+			tokenValue = req.Header.Get(key)
+		}
+	case PostParam:
+		if tokenValue, err = getPostParam(req, key); err != nil {
+			return "", err
+		}
+	case QueryParam:
+		tokenValue = getQueryParam(req, key)
+	}
+
+	if tokenValue == "" {
+		return "", ErrorEmptyToken
+	}
+	return tokenValue, nil
+}
+
+// setContextData makes the given data available as req.ctx.<name> for further evaluations.
+func setContextData(req *http.Request, name string, data Claims) {
+	ctx := req.Context()
+	acMap, ok := ctx.Value(ContextAccessControlKey).(map[string]interface{})
+	if !ok {
+		acMap = make(map[string]interface{})
+	}
+	acMap[name] = data
+	ctx = context.WithValue(ctx, ContextAccessControlKey, acMap)
+	*req = *req.WithContext(ctx)
+}
+
+func getBearer(val string) (string, error) {
+	const bearer = "bearer "
+	if strings.HasPrefix(strings.ToLower(val), bearer) {
+		return strings.Trim(val[len(bearer):], " "), nil
+	}
+	return "", ErrorBearerRequired
+}
+
+// getQueryParam returns the value of the given query parameter and removes it from the request url.
+func getQueryParam(req *http.Request, key string) string {
+	query := req.URL.Query()
+	value := query.Get(key)
+	if _, exist := query[key]; exist {
+		query.Del(key)
+		req.URL.RawQuery = query.Encode()
+	}
+	return value
+}
+
+// getPostParam returns the value of the given url-encoded form parameter and removes it from the request body.
+// The body gets buffered and is made available via GetBody like a body buffered for expressions.
+func getPostParam(req *http.Request, key string) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return "", nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "application/x-www-form-urlencoded" {
+		return "", nil
+	}
+
+	body, err := bufferBody(req)
+	if err != nil {
+		return "", err
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return "", err
+	}
+
+	value := values.Get(key)
+	if _, exist := values[key]; exist {
+		values.Del(key)
+		body = []byte(values.Encode())
+	}
+	setBody(req, body)
+
+	return value, nil
+}
+
+func bufferBody(req *http.Request) ([]byte, error) {
+	body := req.Body
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		body = rc
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(body, maxPostParamBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxPostParamBodySize {
+		return nil, ErrorBodyTooLarge
+	}
+	return b, nil
+}
+
+func setBody(req *http.Request, body []byte) {
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+}
@@ -10,7 +10,7 @@ import (
 // ContextWithSignal creates a context canceled when SIGINT or SIGTERM are notified
 func ContextWithSignal(ctx context.Context) context.Context {
 	ctx, cancel := context.WithCancel(ctx)
-	signals := make(chan os.Signal)
+	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		select {
@@ -20,3 +20,14 @@ func ContextWithSignal(ctx context.Context) context.Context {
 	}()
 	return ctx
 }
+
+// NotifyReload returns a channel which receives SIGHUP notifications until the given context is done.
+func NotifyReload(ctx context.Context) <-chan os.Signal {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		<-ctx.Done()
+		signal.Stop(signals)
+	}()
+	return signals
+}
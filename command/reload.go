@@ -0,0 +1,167 @@
+package command
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/config/runtime"
+	"github.com/avenga/couper/server"
+)
+
+const watchInterval = time.Second * 2
+
+// reloader replaces the configuration of the running servers on SIGHUP or configuration file changes.
+type reloader struct {
+	cancelHealth context.CancelFunc
+	cmdCtx       context.Context
+	filename     string
+	httpConf     *runtime.HTTPConfig
+	log          *logrus.Entry
+	mu           sync.Mutex
+	servers      []*server.HTTPServer
+	srvConf      *runtime.ServerConfiguration
+}
+
+func newReloader(cmdCtx context.Context, filename string, httpConf *runtime.HTTPConfig, srvConf *runtime.ServerConfiguration, servers []*server.HTTPServer, log *logrus.Entry) *reloader {
+	rl := &reloader{
+		cmdCtx:   cmdCtx,
+		filename: filename,
+		httpConf: httpConf,
+		log:      log,
+		servers:  servers,
+		srvConf:  srvConf,
+	}
+	rl.startHealthChecks()
+	return rl
+}
+
+func (rl *reloader) startHealthChecks() {
+	ctx, cancel := context.WithCancel(rl.cmdCtx)
+	rl.cancelHealth = cancel
+	rl.srvConf.StartHealthChecks(ctx, rl.log)
+}
+
+// run reloads the configuration for each trigger until the command context is done.
+func (rl *reloader) run(trigger <-chan struct{}) {
+	for {
+		select {
+		case <-rl.cmdCtx.Done():
+			return
+		case <-trigger:
+			if err := rl.reload(); err != nil {
+				rl.log.Errorf("configuration reload failed, keeping the current configuration: %v", err)
+			}
+		}
+	}
+}
+
+func (rl *reloader) reload() error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	conf, err := config.LoadFile(rl.filename)
+	if err != nil {
+		return err
+	}
+
+	srvConf, err := runtime.NewServerConfiguration(conf, rl.httpConf, rl.log)
+	if err != nil {
+		return err
+	}
+
+	servers, err := server.Reload(rl.cmdCtx, rl.log.Logger, rl.httpConf, rl.servers, srvConf)
+	if err != nil {
+		_ = srvConf.Tracer.Shutdown(context.Background())
+		return err
+	}
+
+	rl.log.WithFields(runtime.NewDiff(rl.srvConf, srvConf).Fields()).Info("configuration reloaded")
+
+	oldConf := rl.srvConf
+	rl.servers, rl.srvConf = servers, srvConf
+
+	rl.cancelHealth()
+	rl.startHealthChecks()
+
+	// running requests of the replaced configuration return their connections to the old transports
+	oldConf.Transports.CloseIdleConnections()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), rl.httpConf.Timings.ShutdownTimeout)
+		defer cancel()
+		if err := oldConf.Tracer.Shutdown(ctx); err != nil {
+			rl.log.Errorf("tracing: %v", err)
+		}
+		<-ctx.Done()
+		oldConf.Transports.CloseIdleConnections()
+	}()
+	return nil
+}
+
+// current returns the active server configuration.
+func (rl *reloader) current() *runtime.ServerConfiguration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.srvConf
+}
+
+// newReloadTrigger combines SIGHUP notifications and the optional configuration file watcher.
+func newReloadTrigger(ctx context.Context, filename string, watch bool) <-chan struct{} {
+	trigger := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case trigger <- struct{}{}:
+		default: // a reload is already pending
+		}
+	}
+
+	signals := NotifyReload(ctx)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				notify()
+			}
+		}
+	}()
+
+	if watch {
+		go watchFile(ctx, filename, watchInterval, notify)
+	}
+	return trigger
+}
+
+// watchFile polls the modification time and size of the given file.
+func watchFile(ctx context.Context, filename string, interval time.Duration, onChange func()) {
+	var modTime time.Time
+	var size int64
+	if info, err := os.Stat(filename); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(filename)
+			if err != nil {
+				continue
+			}
+			if !info.ModTime().Equal(modTime) || info.Size() != size {
+				modTime, size = info.ModTime(), info.Size()
+				onChange()
+			}
+		}
+	}
+}
@@ -3,6 +3,7 @@ package command
 import (
 	"context"
 	"flag"
+	"strings"
 
 	"github.com/avenga/couper/config/env"
 
@@ -30,27 +31,45 @@ func (r Run) Execute(args Args, config *config.Gateway, logEntry *logrus.Entry)
 	// TODO: Extract and execute flagSet & env handling in a more generic way for future commands.
 	set := flag.NewFlagSet("settings", flag.ContinueOnError)
 	set.StringVar(&httpConf.HealthPath, "health-path", httpConf.HealthPath, "-health-path /healthz")
+	set.BoolVar(&httpConf.HealthDetails, "health-details", httpConf.HealthDetails, "-health-details")
 	set.IntVar(&httpConf.ListenPort, "p", httpConf.ListenPort, "-p 8080")
+	set.StringVar(&httpConf.MetricsPath, "metrics-path", httpConf.MetricsPath, "-metrics-path /metrics")
+	set.IntVar(&httpConf.MetricsPort, "metrics-port", httpConf.MetricsPort, "-metrics-port 9090")
 	set.BoolVar(&httpConf.UseXFH, "xfh", httpConf.UseXFH, "-xfh")
+	set.BoolVar(&httpConf.Watch, "watch", httpConf.Watch, "-watch")
 	set.StringVar(&httpConf.RequestIDFormat, "request-id-format", httpConf.RequestIDFormat, "-request-id-format uuid4")
+	trustedProxies := set.String("trusted-proxies", strings.Join(httpConf.TrustedProxies, ","), "-trusted-proxies 10.0.0.0/8,192.168.0.1")
 	if err := set.Parse(args.Filter(set)); err != nil {
 		return err
 	}
+	if *trustedProxies != "" {
+		httpConf.TrustedProxies = strings.Split(*trustedProxies, ",")
+	}
 	envConf := &runtime.HTTPConfig{}
 	env.Decode(envConf)
 	httpConf = httpConf.Merge(envConf)
 
 	// logEntry has still the 'daemon' type which can be used for config related load errors.
-	srvMux, err := runtime.NewServerConfiguration(config, httpConf, logEntry)
+	srvConf, err := runtime.NewServerConfiguration(config, httpConf, logEntry)
 	if err != nil {
 		return err
 	}
 
-	serverList, listenCmdShutdown := server.NewServerList(r.context, logEntry.Logger, httpConf, srvMux)
+	serverList, listenCmdShutdown := server.NewServerList(r.context, logEntry.Logger, httpConf, srvConf)
 	for _, srv := range serverList {
 		srv.Listen()
 	}
+
+	rl := newReloader(r.context, config.Filename, httpConf, srvConf, serverList, logEntry)
+	go rl.run(newReloadTrigger(r.context, config.Filename, httpConf.Watch))
+
 	listenCmdShutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpConf.Timings.ShutdownTimeout)
+	defer cancel()
+	if err = rl.current().Tracer.Shutdown(ctx); err != nil {
+		logEntry.Errorf("tracing: %v", err)
+	}
 	return nil
 }
 
@@ -0,0 +1,7 @@
+package config
+
+// AccessControlGroup represents the "access_control_group" config block
+type AccessControlGroup struct {
+	AccessControl []string `hcl:"access_control"`
+	Name          string   `hcl:"name,label"`
+}
@@ -5,12 +5,14 @@ import (
 )
 
 type Api struct {
-	AccessControl        []string    `hcl:"access_control,optional"`
-	CORS                 *CORS       `hcl:"cors,block"`
-	Backend              string      `hcl:"backend,optional"`
-	BasePath             string      `hcl:"base_path,optional"`
-	DisableAccessControl []string    `hcl:"disable_access_control,optional"`
-	Endpoint             []*Endpoint `hcl:"endpoint,block"`
-	ErrorFile            string      `hcl:"error_file,optional"`
-	InlineDefinition     hcl.Body    `hcl:",remain" json:"-"`
+	AccessControl        []string       `hcl:"access_control,optional"`
+	Authorize            hcl.Expression `hcl:"authorize,optional" json:"-"`
+	CORS                 *CORS          `hcl:"cors,block"`
+	Backend              string         `hcl:"backend,optional"`
+	BasePath             string         `hcl:"base_path,optional"`
+	DisableAccessControl []string       `hcl:"disable_access_control,optional"`
+	Endpoint             []*Endpoint    `hcl:"endpoint,block"`
+	ErrorFile            string         `hcl:"error_file,optional"`
+	InlineDefinition     hcl.Body       `hcl:",remain" json:"-"`
+	RateLimit            *RateLimit     `hcl:"rate_limit,block"`
 }
@@ -0,0 +1,9 @@
+package config
+
+// APIKey represents the "api_key" config block
+type APIKey struct {
+	File       string `hcl:"keys_file"`
+	Header     string `hcl:"header,optional"`
+	Name       string `hcl:"name,label"`
+	QueryParam string `hcl:"query_param,optional"`
+}
@@ -6,12 +6,30 @@ import (
 )
 
 type Backend struct {
-	ConnectTimeout   string   `hcl:"connect_timeout,optional"`
-	Name             string   `hcl:"name,label"`
-	Options          hcl.Body `hcl:",remain"`
-	RequestBodyLimit string   `hcl:"request_body_limit,optional"`
-	TTFBTimeout      string   `hcl:"ttfb_timeout,optional"`
-	Timeout          string   `hcl:"timeout,optional"`
+	CAFile                       string          `hcl:"ca_file,optional"`
+	Cache                        *Cache          `hcl:"cache,block"`
+	CircuitBreaker               *CircuitBreaker `hcl:"circuit_breaker,block"`
+	ClientCertFile               string          `hcl:"client_cert_file,optional"`
+	ClientKeyFile                string          `hcl:"client_key_file,optional"`
+	ConnectTimeout               string          `hcl:"connect_timeout,optional"`
+	DisableCertificateValidation *bool           `hcl:"disable_certificate_validation,optional"`
+	Health                       *Health         `hcl:"health,block"`
+	IdleConnectionTimeout        string          `hcl:"idle_connection_timeout,optional"`
+	LoadBalancer                 *LoadBalancer   `hcl:"load_balancer,block"`
+	MaxConcurrentRequests        int             `hcl:"max_concurrent_requests,optional"`
+	MaxConnectionsPerHost        int             `hcl:"max_connections_per_host,optional"`
+	MaxIdleConnections           int             `hcl:"max_idle_connections,optional"`
+	MaxQueueSize                 int             `hcl:"max_queue_size,optional"`
+	MinTLSVersion                string          `hcl:"min_tls_version,optional"`
+	Name                         string          `hcl:"name,label"`
+	Options                      hcl.Body        `hcl:",remain"`
+	QueueTimeout                 string          `hcl:"queue_timeout,optional"`
+	RequestBodyLimit             string          `hcl:"request_body_limit,optional"`
+	RetryBackoff                 string          `hcl:"retry_backoff,optional"`
+	RetryOn                      []string        `hcl:"retry_on,optional"`
+	Retries                      int             `hcl:"retries,optional"`
+	TTFBTimeout                  string          `hcl:"ttfb_timeout,optional"`
+	Timeout                      string          `hcl:"timeout,optional"`
 }
 
 func (b Backend) Schema(inline bool) *hcl.BodySchema {
@@ -21,11 +39,18 @@ func (b Backend) Schema(inline bool) *hcl.BodySchema {
 	}
 
 	type Inline struct {
+		Cache           *Cache            `hcl:"cache,block"`
+		CircuitBreaker  *CircuitBreaker   `hcl:"circuit_breaker,block"`
 		Origin          string            `hcl:"origin,optional"`
+		Health          *Health           `hcl:"health,block"`
 		Hostname        string            `hcl:"hostname,optional"`
+		LoadBalancer    *LoadBalancer     `hcl:"load_balancer,block"`
 		Path            string            `hcl:"path,optional"`
 		RequestHeaders  map[string]string `hcl:"request_headers,optional"`
 		ResponseHeaders map[string]string `hcl:"response_headers,optional"`
+		RetryBackoff    string            `hcl:"retry_backoff,optional"`
+		RetryOn         []string          `hcl:"retry_on,optional"`
+		Retries         int               `hcl:"retries,optional"`
 	}
 
 	schema, _ = gohcl.ImpliedBodySchema(&Inline{})
@@ -55,14 +80,86 @@ func (b *Backend) Merge(other *Backend) (*Backend, []hcl.Body) {
 		result.Options = other.Options
 	}
 
+	if other.CAFile != "" {
+		result.CAFile = other.CAFile
+	}
+
+	if other.Cache != nil {
+		result.Cache = other.Cache
+	}
+
+	if other.CircuitBreaker != nil {
+		result.CircuitBreaker = other.CircuitBreaker
+	}
+
+	if other.ClientCertFile != "" {
+		result.ClientCertFile = other.ClientCertFile
+	}
+
+	if other.ClientKeyFile != "" {
+		result.ClientKeyFile = other.ClientKeyFile
+	}
+
 	if other.ConnectTimeout != "" {
 		result.ConnectTimeout = other.ConnectTimeout
 	}
 
+	if other.DisableCertificateValidation != nil {
+		result.DisableCertificateValidation = other.DisableCertificateValidation
+	}
+
+	if other.Health != nil {
+		result.Health = other.Health
+	}
+
+	if other.IdleConnectionTimeout != "" {
+		result.IdleConnectionTimeout = other.IdleConnectionTimeout
+	}
+
+	if other.LoadBalancer != nil {
+		result.LoadBalancer = other.LoadBalancer
+	}
+
+	if other.MaxConcurrentRequests != 0 {
+		result.MaxConcurrentRequests = other.MaxConcurrentRequests
+	}
+
+	if other.MaxConnectionsPerHost != 0 {
+		result.MaxConnectionsPerHost = other.MaxConnectionsPerHost
+	}
+
+	if other.MaxIdleConnections != 0 {
+		result.MaxIdleConnections = other.MaxIdleConnections
+	}
+
+	if other.MaxQueueSize != 0 {
+		result.MaxQueueSize = other.MaxQueueSize
+	}
+
+	if other.MinTLSVersion != "" {
+		result.MinTLSVersion = other.MinTLSVersion
+	}
+
+	if other.QueueTimeout != "" {
+		result.QueueTimeout = other.QueueTimeout
+	}
+
 	if other.RequestBodyLimit != "" {
 		result.RequestBodyLimit = other.RequestBodyLimit
 	}
 
+	if other.RetryBackoff != "" {
+		result.RetryBackoff = other.RetryBackoff
+	}
+
+	if len(other.RetryOn) > 0 {
+		result.RetryOn = other.RetryOn
+	}
+
+	if other.Retries != 0 {
+		result.Retries = other.Retries
+	}
+
 	if other.TTFBTimeout != "" {
 		result.TTFBTimeout = other.TTFBTimeout
 	}
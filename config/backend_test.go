@@ -11,6 +11,7 @@ func TestBackend_Merge(t *testing.T) {
 	type args struct {
 		other *Backend
 	}
+	enabled, disabled := true, false
 	tests := []struct {
 		name   string
 		fields Backend
@@ -43,16 +44,78 @@ func TestBackend_Merge(t *testing.T) {
 		}}, &Backend{
 			Timeout: "e", ConnectTimeout: "d", TTFBTimeout: "t", Options: hcl.EmptyBody(),
 		}},
+		{"tls override", Backend{
+			CAFile: "ca.pem", MinTLSVersion: "TLS1.2", DisableCertificateValidation: &enabled,
+		}, args{&Backend{
+			ClientCertFile: "client.crt", ClientKeyFile: "client.key", MinTLSVersion: "TLS1.3",
+		}}, &Backend{
+			CAFile: "ca.pem", ClientCertFile: "client.crt", ClientKeyFile: "client.key",
+			DisableCertificateValidation: &enabled, MinTLSVersion: "TLS1.3",
+		}},
+		{"tls validation override", Backend{
+			DisableCertificateValidation: &enabled,
+		}, args{&Backend{
+			DisableCertificateValidation: &disabled,
+		}}, &Backend{
+			DisableCertificateValidation: &disabled,
+		}},
+		{"load_balancer override", Backend{
+			LoadBalancer: &LoadBalancer{Strategy: "random"},
+		}, args{&Backend{
+			LoadBalancer: &LoadBalancer{Strategy: "hash"},
+		}}, &Backend{
+			LoadBalancer: &LoadBalancer{Strategy: "hash"},
+		}},
+		{"retry override", Backend{
+			Retries: 2, RetryOn: []string{"connect"}, RetryBackoff: "1s",
+		}, args{&Backend{
+			Retries: 3, RetryOn: []string{"timeout", "503"},
+		}}, &Backend{
+			Retries: 3, RetryOn: []string{"timeout", "503"}, RetryBackoff: "1s",
+		}},
+		{"concurrency override", Backend{
+			MaxConcurrentRequests: 10, MaxQueueSize: 20, QueueTimeout: "1s", MaxIdleConnections: 5,
+		}, args{&Backend{
+			MaxConcurrentRequests: 50, QueueTimeout: "2s", MaxConnectionsPerHost: 50, IdleConnectionTimeout: "30s",
+		}}, &Backend{
+			MaxConcurrentRequests: 50, MaxQueueSize: 20, QueueTimeout: "2s", MaxIdleConnections: 5,
+			MaxConnectionsPerHost: 50, IdleConnectionTimeout: "30s",
+		}},
+		{"cache override", Backend{
+			Cache: &Cache{DefaultTTL: "10s"}, Timeout: "s",
+		}, args{&Backend{
+			Cache: &Cache{MaxEntries: 100},
+		}}, &Backend{
+			Cache: &Cache{MaxEntries: 100}, Timeout: "s",
+		}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			b := &Backend{
-				ConnectTimeout:   tt.fields.ConnectTimeout,
-				Name:             tt.fields.Name,
-				Options:          tt.fields.Options,
-				RequestBodyLimit: tt.fields.RequestBodyLimit,
-				Timeout:          tt.fields.Timeout,
-				TTFBTimeout:      tt.fields.TTFBTimeout,
+				CAFile:                       tt.fields.CAFile,
+				Cache:                        tt.fields.Cache,
+				CircuitBreaker:               tt.fields.CircuitBreaker,
+				ClientCertFile:               tt.fields.ClientCertFile,
+				ClientKeyFile:                tt.fields.ClientKeyFile,
+				ConnectTimeout:               tt.fields.ConnectTimeout,
+				DisableCertificateValidation: tt.fields.DisableCertificateValidation,
+				Health:                       tt.fields.Health,
+				IdleConnectionTimeout:        tt.fields.IdleConnectionTimeout,
+				LoadBalancer:                 tt.fields.LoadBalancer,
+				MaxConcurrentRequests:        tt.fields.MaxConcurrentRequests,
+				MaxConnectionsPerHost:        tt.fields.MaxConnectionsPerHost,
+				MaxIdleConnections:           tt.fields.MaxIdleConnections,
+				MaxQueueSize:                 tt.fields.MaxQueueSize,
+				MinTLSVersion:                tt.fields.MinTLSVersion,
+				Name:                         tt.fields.Name,
+				Options:                      tt.fields.Options,
+				QueueTimeout:                 tt.fields.QueueTimeout,
+				RequestBodyLimit:             tt.fields.RequestBodyLimit,
+				RetryBackoff:                 tt.fields.RetryBackoff,
+				RetryOn:                      tt.fields.RetryOn,
+				Retries:                      tt.fields.Retries,
+				Timeout:                      tt.fields.Timeout,
+				TTFBTimeout:                  tt.fields.TTFBTimeout,
 			}
 			if got, _ := b.Merge(tt.args.other); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("Merge() = %v, want %v", got, tt.want)
@@ -0,0 +1,11 @@
+package config
+
+import "github.com/hashicorp/hcl/v2"
+
+// Cache represents the "cache" block of an endpoint or backend.
+type Cache struct {
+	DefaultTTL  string         `hcl:"default_ttl,optional"`
+	Key         hcl.Expression `hcl:"key,optional" json:"-"`
+	MaxBodySize string         `hcl:"max_body_size,optional"`
+	MaxEntries  int            `hcl:"max_entries,optional"`
+}
@@ -0,0 +1,11 @@
+package config
+
+// CircuitBreaker represents the "circuit_breaker" block of a backend.
+type CircuitBreaker struct {
+	FailureRate      float64 `hcl:"failure_rate,optional"`
+	FailureThreshold int     `hcl:"failure_threshold,optional"`
+	HalfOpenRequests int     `hcl:"half_open_requests,optional"`
+	MinRequests      int     `hcl:"min_requests,optional"`
+	OpenDuration     string  `hcl:"open_duration,optional"`
+	Window           string  `hcl:"window,optional"`
+}
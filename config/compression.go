@@ -0,0 +1,7 @@
+package config
+
+// Compression represents the "compression" block of a server.
+type Compression struct {
+	MinSize string   `hcl:"min_size,optional"`
+	Types   []string `hcl:"types,optional"`
+}
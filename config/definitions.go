@@ -1,7 +1,13 @@
 package config
 
 type Definitions struct {
-	Backend   []*Backend   `hcl:"backend,block"`
-	BasicAuth []*BasicAuth `hcl:"basic_auth,block"`
-	JWT       []*JWT       `hcl:"jwt,block"`
+	AccessControlGroup  []*AccessControlGroup  `hcl:"access_control_group,block"`
+	APIKey              []*APIKey              `hcl:"api_key,block"`
+	Backend             []*Backend             `hcl:"backend,block"`
+	BasicAuth           []*BasicAuth           `hcl:"basic_auth,block"`
+	IPFilter            []*IPFilter            `hcl:"ip_filter,block"`
+	JWT                 []*JWT                 `hcl:"jwt,block"`
+	MTLS                []*MTLS                `hcl:"mtls,block"`
+	OAuth2Introspection []*OAuth2Introspection `hcl:"oauth2_introspection,block"`
+	OIDC                []*OIDC                `hcl:"oidc,block"`
 }
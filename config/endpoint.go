@@ -6,11 +6,14 @@ import (
 )
 
 type Endpoint struct {
-	AccessControl        []string `hcl:"access_control,optional"`
-	Backend              string   `hcl:"backend,optional"`
-	DisableAccessControl []string `hcl:"disable_access_control,optional"`
-	InlineDefinition     hcl.Body `hcl:",remain" json:"-"`
-	Pattern              string   `hcl:"path,label"`
+	AccessControl        []string       `hcl:"access_control,optional"`
+	Authorize            hcl.Expression `hcl:"authorize,optional" json:"-"`
+	Backend              string         `hcl:"backend,optional"`
+	Cache                *Cache         `hcl:"cache,block"`
+	DisableAccessControl []string       `hcl:"disable_access_control,optional"`
+	InlineDefinition     hcl.Body       `hcl:",remain" json:"-"`
+	Pattern              string         `hcl:"path,label"`
+	RateLimit            *RateLimit     `hcl:"rate_limit,block"`
 }
 
 func (e Endpoint) Schema(inline bool) *hcl.BodySchema {
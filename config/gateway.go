@@ -6,6 +6,7 @@ type Gateway struct {
 	Bytes       []byte           `hcl:"-"`
 	Context     *hcl.EvalContext `hcl:"-"`
 	Definitions *Definitions     `hcl:"definitions,block"`
-	Server      []*Server        `hcl:"server,block"`
-	Settings    *Settings        `hcl:"settings,block"`
+	Filename    string
+	Server      []*Server `hcl:"server,block"`
+	Settings    *Settings `hcl:"settings,block"`
 }
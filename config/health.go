@@ -0,0 +1,12 @@
+package config
+
+// Health represents the "health" block of a backend which configures active health checks.
+type Health struct {
+	ExpectedStatus   []int  `hcl:"expected_status,optional"`
+	ExpectedText     string `hcl:"expected_text,optional"`
+	FailureThreshold int    `hcl:"failure_threshold,optional"`
+	Interval         string `hcl:"interval,optional"`
+	Path             string `hcl:"path,optional"`
+	SuccessThreshold int    `hcl:"success_threshold,optional"`
+	Timeout          string `hcl:"timeout,optional"`
+}
@@ -0,0 +1,8 @@
+package config
+
+// IPFilter represents the "ip_filter" config block
+type IPFilter struct {
+	Allow []string `hcl:"allow,optional"`
+	Deny  []string `hcl:"deny,optional"`
+	Name  string   `hcl:"name,label"`
+}
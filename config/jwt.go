@@ -11,6 +11,9 @@ type JWT struct {
 	ClaimsRequired     []string `hcl:"required_claims,optional"`
 	Cookie             string   `hcl:"cookie,optional"`
 	Header             string   `hcl:"header,optional"`
+	JWKSFile           string   `hcl:"jwks_file,optional"`
+	JWKSTTL            string   `hcl:"jwks_ttl,optional"`
+	JWKSURL            string   `hcl:"jwks_url,optional"`
 	Key                string   `hcl:"key,optional"`
 	KeyFile            string   `hcl:"key_file,optional"`
 	Name               string   `hcl:"name,label"`
@@ -28,6 +28,7 @@ func LoadBytes(src []byte, filePath string) (*Gateway, error) {
 	config := &Gateway{
 		Bytes:    src[:],
 		Context:  eval.NewENVContext(src),
+		Filename: filePath,
 		Settings: &Settings{DefaultPort: DefaultListenPort},
 	}
 	filename := filepath.Base(filePath)
@@ -0,0 +1,16 @@
+package config
+
+import "github.com/hashicorp/hcl/v2"
+
+// LoadBalancer represents the "load_balancer" block of a backend.
+type LoadBalancer struct {
+	HashKey  hcl.Expression        `hcl:"hash_key,optional"`
+	Origins  []*LoadBalancerOrigin `hcl:"origin,block"`
+	Strategy string                `hcl:"strategy,optional"`
+}
+
+// LoadBalancerOrigin represents one "origin" block of a load_balancer.
+type LoadBalancerOrigin struct {
+	URL    string `hcl:"url,label"`
+	Weight int    `hcl:"weight,optional"`
+}
@@ -0,0 +1,10 @@
+package config
+
+// MTLS represents the "mtls" config block
+type MTLS struct {
+	CAFile         string   `hcl:"ca_file"`
+	CRLFile        string   `hcl:"crl_file,optional"`
+	Name           string   `hcl:"name,label"`
+	SANPatterns    []string `hcl:"san_patterns,optional"`
+	SubjectPattern string   `hcl:"subject_pattern,optional"`
+}
@@ -0,0 +1,14 @@
+package config
+
+// OAuth2Introspection represents the "oauth2_introspection" config block
+type OAuth2Introspection struct {
+	Backend      string `hcl:"backend"`
+	ClientID     string `hcl:"client_id,optional"`
+	ClientSecret string `hcl:"client_secret,optional"`
+	Cookie       string `hcl:"cookie,optional"`
+	Header       string `hcl:"header,optional"`
+	Name         string `hcl:"name,label"`
+	PostParam    string `hcl:"post_param,optional"`
+	QueryParam   string `hcl:"query_param,optional"`
+	TTL          string `hcl:"ttl,optional"`
+}
@@ -0,0 +1,20 @@
+package config
+
+// OIDC represents the "oidc" config block
+type OIDC struct {
+	AuthorizationEndpoint string   `hcl:"authorization_endpoint,optional"`
+	ClientID              string   `hcl:"client_id"`
+	ClientSecret          string   `hcl:"client_secret,optional"`
+	ConfigurationURL      string   `hcl:"configuration_url,optional"`
+	CookieName            string   `hcl:"cookie_name,optional"`
+	Issuer                string   `hcl:"issuer,optional"`
+	JWKSURL               string   `hcl:"jwks_url,optional"`
+	LogoutPath            string   `hcl:"logout_path,optional"`
+	LogoutRedirectURI     string   `hcl:"logout_redirect_uri,optional"`
+	Name                  string   `hcl:"name,label"`
+	RedirectURI           string   `hcl:"redirect_uri"`
+	Scopes                []string `hcl:"scopes,optional"`
+	SessionSecret         string   `hcl:"session_secret"`
+	SessionTTL            string   `hcl:"session_ttl,optional"`
+	TokenEndpoint         string   `hcl:"token_endpoint,optional"`
+}
@@ -0,0 +1,11 @@
+package config
+
+import "github.com/hashicorp/hcl/v2"
+
+// RateLimit represents the "rate_limit" block of a server, api or endpoint.
+type RateLimit struct {
+	Algorithm string         `hcl:"algorithm,optional"`
+	Key       hcl.Expression `hcl:"key,optional" json:"-"`
+	Period    string         `hcl:"period"`
+	PerPeriod int            `hcl:"per_period"`
+}
@@ -10,4 +10,5 @@ const (
 	RoundtripInfo
 	ServerName
 	Wildcard
+	ClientIP
 )
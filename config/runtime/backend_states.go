@@ -0,0 +1,88 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/handler"
+)
+
+// backendStates shares the stateful parts like health checks, load balancer, circuit breaker, concurrency limit
+// or cache of equal backend configurations, e.g. a definitions backend which is referenced by multiple endpoints.
+// All backends of a server configuration share its transports.
+type backendStates struct {
+	caches     map[string]*handler.ResponseCache
+	options    map[string]*handler.ProxyOptions
+	transports *handler.Transports
+}
+
+func newBackendStates() backendStates {
+	return backendStates{
+		caches:     make(map[string]*handler.ResponseCache),
+		options:    make(map[string]*handler.ProxyOptions),
+		transports: handler.NewTransports(),
+	}
+}
+
+func (bs backendStates) share(beConf *config.Backend, options *handler.ProxyOptions) {
+	options.Transports = bs.transports
+
+	// an endpoint cache block replaces the backend one and is not shared with other endpoints
+	if options.Cache != nil {
+		key := fmt.Sprintf("%s|%p", beConf.Name, beConf.Cache)
+		if shared, ok := bs.caches[key]; ok {
+			options.Cache = shared
+		} else {
+			bs.caches[key] = options.Cache
+		}
+	}
+
+	// the concurrency limit of unnamed backends is not shared since their configurations are not distinguishable
+	if options.Health == nil && options.LoadBalancer == nil && options.CircuitBreaker == nil &&
+		(options.ConcurrencyLimit == nil || beConf.Name == "") {
+		return
+	}
+
+	var origins []string
+	if options.LoadBalancer != nil {
+		for _, o := range options.LoadBalancer.Origins() {
+			origins = append(origins, o.URL.String())
+		}
+	}
+	key := fmt.Sprintf("%s|%p|%p|%p|%s", beConf.Name,
+		beConf.CircuitBreaker, beConf.Health, beConf.LoadBalancer, strings.Join(origins, ","))
+
+	if shared, ok := bs.options[key]; ok {
+		options.CircuitBreaker = shared.CircuitBreaker
+		options.ConcurrencyLimit = shared.ConcurrencyLimit
+		options.Health = shared.Health
+		options.LoadBalancer = shared.LoadBalancer
+		return
+	}
+	bs.options[key] = options
+}
+
+func (bs backendStates) healthChecks() []*handler.BackendHealth {
+	var list []*handler.BackendHealth
+	for _, options := range bs.options {
+		if options.Health != nil {
+			list = append(list, options.Health)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name() < list[j].Name()
+	})
+	return list
+}
+
+// StartHealthChecks starts all configured backend health checks until the given context is done.
+func (s *ServerConfiguration) StartHealthChecks(ctx context.Context, log *logrus.Entry) {
+	for _, health := range s.BackendHealth {
+		health.Start(ctx, log)
+	}
+}
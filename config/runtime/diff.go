@@ -0,0 +1,83 @@
+package runtime
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Diff summarizes the port and route changes between two server configurations.
+type Diff struct {
+	AddedPorts    []Port
+	AddedRoutes   []string
+	RemovedPorts  []Port
+	RemovedRoutes []string
+}
+
+// NewDiff compares the routes of both configurations per port.
+func NewDiff(old, new *ServerConfiguration) *Diff {
+	diff := &Diff{}
+
+	for port, muxOpts := range new.PortOptions {
+		oldOpts, exist := old.PortOptions[port]
+		if !exist {
+			diff.AddedPorts = append(diff.AddedPorts, port)
+			oldOpts = NewMuxOptions(nil)
+		}
+		diff.AddedRoutes = append(diff.AddedRoutes, diffRoutes(port, muxOpts, oldOpts)...)
+		diff.RemovedRoutes = append(diff.RemovedRoutes, diffRoutes(port, oldOpts, muxOpts)...)
+	}
+
+	for port, muxOpts := range old.PortOptions {
+		if _, exist := new.PortOptions[port]; !exist {
+			diff.RemovedPorts = append(diff.RemovedPorts, port)
+			diff.RemovedRoutes = append(diff.RemovedRoutes, diffRoutes(port, muxOpts, NewMuxOptions(nil))...)
+		}
+	}
+
+	sort.Slice(diff.AddedPorts, func(i, j int) bool { return diff.AddedPorts[i] < diff.AddedPorts[j] })
+	sort.Slice(diff.RemovedPorts, func(i, j int) bool { return diff.RemovedPorts[i] < diff.RemovedPorts[j] })
+	sort.Strings(diff.AddedRoutes)
+	sort.Strings(diff.RemovedRoutes)
+
+	return diff
+}
+
+// diffRoutes returns the routes of a which are missing in b, prefixed with their port.
+func diffRoutes(port Port, a, b *MuxOptions) []string {
+	var result []string
+	for _, routes := range []struct {
+		a, b map[string]http.Handler
+	}{
+		{a.EndpointRoutes, b.EndpointRoutes},
+		{a.FileRoutes, b.FileRoutes},
+		{a.SPARoutes, b.SPARoutes},
+	} {
+		for path := range routes.a {
+			if _, exist := routes.b[path]; !exist {
+				result = append(result, port.String()+":"+path)
+			}
+		}
+	}
+	return result
+}
+
+// Fields returns the diff as log fields.
+func (d *Diff) Fields() logrus.Fields {
+	ports := func(list []Port) []string {
+		result := make([]string, len(list))
+		for i, p := range list {
+			result[i] = strconv.Itoa(int(p))
+		}
+		return result
+	}
+
+	return logrus.Fields{
+		"added_ports":    ports(d.AddedPorts),
+		"added_routes":   d.AddedRoutes,
+		"removed_ports":  ports(d.RemovedPorts),
+		"removed_routes": d.RemovedRoutes,
+	}
+}
@@ -10,10 +10,15 @@ import (
 
 // HTTPConfig represents the configuration of the ingress HTTP server.
 type HTTPConfig struct {
-	HealthPath      string `env:"health_path"`
-	ListenPort      int    `env:"default_port"`
-	UseXFH          bool   `env:"xfh"`
-	RequestIDFormat string `env:"request_id_format"`
+	HealthDetails   bool     `env:"health_details"`
+	HealthPath      string   `env:"health_path"`
+	ListenPort      int      `env:"default_port"`
+	MetricsPath     string   `env:"metrics_path"`
+	MetricsPort     int      `env:"metrics_port"`
+	UseXFH          bool     `env:"xfh"`
+	Watch           bool     `env:"watch"`
+	RequestIDFormat string   `env:"request_id_format"`
+	TrustedProxies  []string `env:"trusted_proxies"`
 	Timings         HTTPTimings
 }
 
@@ -62,10 +67,15 @@ func NewHTTPConfig(c *config.Gateway) *HTTPConfig {
 
 func newHTTPConfigFrom(s *config.Settings) *HTTPConfig {
 	return &HTTPConfig{
+		HealthDetails:   s.HealthDetails,
 		HealthPath:      s.HealthPath,
 		ListenPort:      s.DefaultPort,
+		MetricsPath:     s.MetricsPath,
+		MetricsPort:     s.MetricsPort,
 		UseXFH:          s.XForwardedHost,
+		Watch:           s.Watch,
 		RequestIDFormat: s.RequestIDFormat,
+		TrustedProxies:  s.TrustedProxies,
 		Timings:         DefaultHTTP.Timings,
 	}
 }
@@ -75,6 +85,10 @@ func (c *HTTPConfig) Merge(o *HTTPConfig) *HTTPConfig {
 		return c
 	}
 
+	if o.HealthDetails {
+		c.HealthDetails = true
+	}
+
 	if o.HealthPath != "" {
 		c.HealthPath = o.HealthPath
 	}
@@ -83,14 +97,30 @@ func (c *HTTPConfig) Merge(o *HTTPConfig) *HTTPConfig {
 		c.ListenPort = o.ListenPort
 	}
 
+	if o.MetricsPath != "" {
+		c.MetricsPath = o.MetricsPath
+	}
+
+	if o.MetricsPort != 0 {
+		c.MetricsPort = o.MetricsPort
+	}
+
 	if o.UseXFH != c.UseXFH {
 		c.UseXFH = o.UseXFH
 	}
 
+	if o.Watch {
+		c.Watch = true
+	}
+
 	if o.RequestIDFormat != "" {
 		c.RequestIDFormat = o.RequestIDFormat
 	}
 
+	if len(o.TrustedProxies) > 0 {
+		c.TrustedProxies = o.TrustedProxies
+	}
+
 	return c
 }
 
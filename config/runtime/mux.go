@@ -1,14 +1,23 @@
 package runtime
 
 import (
+	"crypto/tls"
 	"net/http"
+
+	"github.com/avenga/couper/handler"
+	"github.com/avenga/couper/tracing"
 )
 
 type MuxOptions struct {
+	// BackendHealth is used for the detailed health view.
+	BackendHealth  []*handler.BackendHealth
 	Hosts          hosts
 	EndpointRoutes map[string]http.Handler
 	FileRoutes     map[string]http.Handler
 	SPARoutes      map[string]http.Handler
+	// TLS is configured if the servers on this port are terminating tls connections.
+	TLS    *tls.Config
+	Tracer *tracing.Tracer
 }
 
 func NewMuxOptions(hostsMap hosts) *MuxOptions {
@@ -8,8 +8,10 @@ import (
 	"net/http/httptest"
 	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/getkin/kin-openapi/pathpattern"
 	"github.com/hashicorp/hcl/v2"
@@ -23,6 +25,7 @@ import (
 	"github.com/avenga/couper/eval"
 	"github.com/avenga/couper/handler"
 	"github.com/avenga/couper/internal/seetie"
+	"github.com/avenga/couper/tracing"
 	"github.com/avenga/couper/utils"
 )
 
@@ -50,7 +53,13 @@ func (p Port) String() string {
 }
 
 type ServerConfiguration struct {
-	PortOptions map[Port]*MuxOptions
+	// BackendHealth lists all backend health checks which must be started with StartHealthChecks.
+	BackendHealth []*handler.BackendHealth
+	PortOptions   map[Port]*MuxOptions
+	// Transports holds the upstream connections which must be closed once the configuration is replaced.
+	Transports *handler.Transports
+	// Tracer is nil if tracing is not configured and must be shut down to export the remaining spans.
+	Tracer *tracing.Tracer
 }
 
 type hosts map[string]bool
@@ -88,12 +97,14 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 		return nil, err
 	}
 
-	backends, err := newBackendsFromDefinitions(conf, confCtx, log)
+	states := newBackendStates()
+
+	backends, err := newBackendsFromDefinitions(conf, confCtx, states, log)
 	if err != nil {
 		return nil, err
 	}
 
-	accessControls, err := configureAccessControls(conf, confCtx)
+	accessControls, err := configureAccessControls(conf, confCtx, backends, log)
 	if err != nil {
 		return nil, err
 	}
@@ -105,6 +116,18 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 		serverConfiguration.PortOptions[p] = NewMuxOptions(hostsMap)
 	}
 
+	if _, exist := serverConfiguration.PortOptions[Port(httpConf.MetricsPort)]; exist && httpConf.MetricsPort != 0 {
+		return nil, fmt.Errorf("metrics_port %d is already used by a server", httpConf.MetricsPort)
+	}
+
+	if _, err = utils.ParseNetworks(httpConf.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %v", err)
+	}
+
+	if err = configureTLS(conf, serverConfiguration, defaultPort); err != nil {
+		return nil, err
+	}
+
 	api := make(map[*config.Endpoint]http.Handler)
 
 	for _, srvConf := range conf.Server {
@@ -113,6 +136,16 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 			return nil, err
 		}
 
+		srvRateLimiter, err := handler.NewRateLimiter(srvConf.RateLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		compression, err := handler.NewCompression(srvConf.Compression)
+		if err != nil {
+			return nil, err
+		}
+
 		var spaHandler http.Handler
 		if srvConf.Spa != nil {
 			spaHandler, err = handler.NewSpa(srvConf.Spa.BootstrapFile, serverOptions)
@@ -120,9 +153,12 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 				return nil, err
 			}
 
+			spaHandler = configureRateLimits(spaHandler, serverOptions.ServerErrTpl, confCtx, log, true, srvRateLimiter)
 			spaHandler = configureProtectedHandler(accessControls, serverOptions.ServerErrTpl,
 				config.NewAccessControl(srvConf.AccessControl, srvConf.DisableAccessControl),
 				config.NewAccessControl(srvConf.Spa.AccessControl, srvConf.Spa.DisableAccessControl), spaHandler)
+			spaHandler = configureRateLimits(spaHandler, serverOptions.ServerErrTpl, confCtx, log, false, srvRateLimiter)
+			spaHandler = configureCompression(spaHandler, compression)
 
 			for _, spaPath := range srvConf.Spa.Paths {
 				err = setRoutesFromHosts(serverConfiguration, defaultPort, srvConf.Hosts, path.Join(serverOptions.SPABasePath, spaPath), spaHandler, KindSPA)
@@ -140,7 +176,10 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 
 			protectedFileHandler := configureProtectedHandler(accessControls, serverOptions.FileErrTpl,
 				config.NewAccessControl(srvConf.AccessControl, srvConf.DisableAccessControl),
-				config.NewAccessControl(srvConf.Files.AccessControl, srvConf.Files.DisableAccessControl), fileHandler)
+				config.NewAccessControl(srvConf.Files.AccessControl, srvConf.Files.DisableAccessControl),
+				configureRateLimits(fileHandler, serverOptions.FileErrTpl, confCtx, log, true, srvRateLimiter))
+			protectedFileHandler = configureRateLimits(protectedFileHandler, serverOptions.FileErrTpl, confCtx, log, false, srvRateLimiter)
+			protectedFileHandler = configureCompression(protectedFileHandler, compression)
 
 			err = setRoutesFromHosts(serverConfiguration, defaultPort, srvConf.Hosts, serverOptions.FileBasePath, protectedFileHandler, KindFiles)
 			if err != nil {
@@ -149,6 +188,11 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 		}
 
 		if srvConf.API != nil {
+			apiRateLimiter, err := handler.NewRateLimiter(srvConf.API.RateLimit)
+			if err != nil {
+				return nil, err
+			}
+
 			// map backends to endpoint
 			endpoints := make(map[string]bool)
 			for _, endpoint := range srvConf.API.Endpoint {
@@ -164,13 +208,26 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 					return nil, err
 				}
 
+				endpointRateLimiter, err := handler.NewRateLimiter(endpoint.RateLimit)
+				if err != nil {
+					return nil, err
+				}
+
 				// setACHandlerFn individual wrap for access_control configuration per endpoint
 				setACHandlerFn := func(protectedHandler http.Handler) {
-					api[endpoint] = configureProtectedHandler(accessControls, serverOptions.APIErrTpl,
+					protectedHandler = configureRateLimits(protectedHandler, serverOptions.APIErrTpl, confCtx, log, true,
+						srvRateLimiter, apiRateLimiter, endpointRateLimiter)
+					if exprs := authorizeExpressions(srvConf.API.Authorize, endpoint.Authorize); len(exprs) > 0 {
+						protectedHandler = handler.NewAuthorization(protectedHandler, serverOptions.APIErrTpl, confCtx, log, exprs...)
+					}
+					protectedHandler = configureProtectedHandler(accessControls, serverOptions.APIErrTpl,
 						config.NewAccessControl(srvConf.AccessControl, srvConf.DisableAccessControl).
 							Merge(config.NewAccessControl(srvConf.API.AccessControl, srvConf.API.DisableAccessControl)),
 						config.NewAccessControl(endpoint.AccessControl, endpoint.DisableAccessControl),
 						protectedHandler)
+					protectedHandler = configureRateLimits(protectedHandler, serverOptions.APIErrTpl, confCtx, log, false,
+						srvRateLimiter, apiRateLimiter, endpointRateLimiter)
+					api[endpoint] = handler.NewEndpoint(pattern, configureCompression(protectedHandler, compression))
 				}
 
 				// lookup for backend reference, prefer endpoint definition over api one
@@ -182,7 +239,11 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 					// set server context for defined backends
 					be := backends[endpoint.Backend]
 					_, remain := be.conf.Merge(&config.Backend{Options: endpoint.InlineDefinition})
-					refBackend := newProxy(confCtx, be.conf, srvConf.API.CORS, remain, log, serverOptions)
+					beConf, _ := be.conf.Merge(&config.Backend{Cache: endpoint.Cache})
+					refBackend, err := newProxy(confCtx, beConf, srvConf.API.CORS, remain, states, log, serverOptions)
+					if err != nil {
+						return nil, err
+					}
 
 					setACHandlerFn(refBackend)
 					err = setRoutesFromHosts(serverConfiguration, defaultPort, srvConf.Hosts, pattern, api[endpoint], KindAPI)
@@ -193,34 +254,41 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 				}
 
 				// otherwise try to parse an inline block and fallback for api reference or inline block
-				inlineBackend, inlineConf, err := newInlineBackend(confCtx, backends, endpoint.InlineDefinition, srvConf.API.CORS, log, serverOptions)
+				inlineBackend, inlineConf, err := newInlineBackend(confCtx, backends, endpoint.InlineDefinition, endpoint.Cache, srvConf.API.CORS, states, log, serverOptions)
 				if err == errorMissingBackend {
 					if srvConf.API.Backend != "" {
 						if _, ok := backends[srvConf.API.Backend]; !ok {
 							return nil, fmt.Errorf("backend %q is not defined", srvConf.API.Backend)
 						}
-						setACHandlerFn(backends[srvConf.API.Backend].handler)
+						apiBackend := backends[srvConf.API.Backend].handler
+						if endpoint.Cache != nil {
+							beConf, remain := backends[srvConf.API.Backend].conf.Merge(&config.Backend{Cache: endpoint.Cache})
+							apiBackend, err = newProxy(confCtx, beConf, srvConf.API.CORS, remain, states, log, serverOptions)
+							if err != nil {
+								return nil, err
+							}
+						}
+						setACHandlerFn(apiBackend)
 						err = setRoutesFromHosts(serverConfiguration, defaultPort, srvConf.Hosts, pattern, api[endpoint], KindAPI)
 						if err != nil {
 							return nil, err
 						}
 						continue
 					}
-					inlineBackend, inlineConf, err = newInlineBackend(confCtx, backends, srvConf.API.InlineDefinition, srvConf.API.CORS, log, serverOptions)
+					inlineBackend, inlineConf, err = newInlineBackend(confCtx, backends, srvConf.API.InlineDefinition, endpoint.Cache, srvConf.API.CORS, states, log, serverOptions)
 					if err != nil {
 						return nil, err
 					}
 
-					if inlineConf.Name == "" && getAttribute(confCtx, "origin", inlineConf.Options, conf.Bytes) == "" {
+					if inlineConf.Name == "" && inlineConf.LoadBalancer == nil &&
+						getAttribute(confCtx, "origin", inlineConf.Options, conf.Bytes) == "" {
 						return nil, fmt.Errorf("api inline backend requires an origin attribute: %q", pattern)
 					}
 				} else if err != nil { // TODO hcl.diagnostics error
 					return nil, fmt.Errorf("range: %s: %v", endpoint.InlineDefinition.MissingItemRange().String(), err)
 				}
 
-				if e := validateOrigin(
-					getAttribute(confCtx, "origin", inlineConf.Options, conf.Bytes),
-					inlineConf.Options.MissingItemRange()); e != nil {
+				if e := validateBackendOrigin(confCtx, inlineConf, conf.Bytes); e != nil {
 					return nil, e
 				}
 
@@ -231,29 +299,44 @@ func NewServerConfiguration(conf *config.Gateway, httpConf *HTTPConfig, log *log
 				}
 			}
 		}
+
+		if err = configureOIDCRoutes(serverConfiguration, defaultPort, conf, srvConf, accessControls, serverOptions, log); err != nil {
+			return nil, err
+		}
+	}
+	tracer, err := tracing.New(conf.Settings.Tracing, log)
+	if err != nil {
+		return nil, err
+	}
+	serverConfiguration.Tracer = tracer
+
+	serverConfiguration.BackendHealth = states.healthChecks()
+	serverConfiguration.Transports = states.transports
+	for _, muxOpts := range serverConfiguration.PortOptions {
+		muxOpts.BackendHealth = serverConfiguration.BackendHealth
+		muxOpts.Tracer = tracer
 	}
+
 	return serverConfiguration, nil
 }
 
-func newProxy(ctx *hcl.EvalContext, beConf *config.Backend, corsOpts *config.CORS, remainCtx []hcl.Body, log *logrus.Entry, srvOpts *server.Options) http.Handler {
+func newProxy(ctx *hcl.EvalContext, beConf *config.Backend, corsOpts *config.CORS, remainCtx []hcl.Body, states backendStates, log *logrus.Entry, srvOpts *server.Options) (http.Handler, error) {
 	corsOptions, err := handler.NewCORSOptions(corsOpts)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	proxyOptions, err := handler.NewProxyOptions(beConf, corsOptions, remainCtx)
+	proxyOptions, err := handler.NewProxyOptions(beConf, corsOptions, remainCtx, log)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	proxy, err := handler.NewProxy(proxyOptions, log, srvOpts, ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return proxy
+	states.share(beConf, proxyOptions)
+
+	return handler.NewProxy(proxyOptions, log, srvOpts, ctx)
 }
 
-func newBackendsFromDefinitions(conf *config.Gateway, confCtx *hcl.EvalContext, log *logrus.Entry) (map[string]backendDefinition, error) {
+func newBackendsFromDefinitions(conf *config.Gateway, confCtx *hcl.EvalContext, states backendStates, log *logrus.Entry) (map[string]backendDefinition, error) {
 	backends := make(map[string]backendDefinition)
 
 	if conf.Definitions == nil {
@@ -265,17 +348,20 @@ func newBackendsFromDefinitions(conf *config.Gateway, confCtx *hcl.EvalContext,
 			return nil, fmt.Errorf("backend name must be unique: %q", beConf.Name)
 		}
 
-		origin := getAttribute(confCtx, "origin", beConf.Options, conf.Bytes)
-		if e := validateOrigin(origin, beConf.Options.MissingItemRange()); e != nil {
+		if e := validateBackendOrigin(confCtx, beConf, conf.Bytes); e != nil {
 			return nil, e
 		}
 
 		beConf, _ = defaultBackendConf.Merge(beConf)
 
 		srvOpts, _ := server.NewServerOptions(&config.Server{})
+		proxy, err := newProxy(confCtx, beConf, nil, []hcl.Body{beConf.Options}, states, log, srvOpts)
+		if err != nil {
+			return nil, err
+		}
 		backends[beConf.Name] = backendDefinition{
 			conf:    beConf,
-			handler: newProxy(confCtx, beConf, nil, []hcl.Body{beConf.Options}, log, srvOpts),
+			handler: proxy,
 		}
 	}
 	return backends, nil
@@ -324,7 +410,7 @@ func splitWildcardHostPort(host string, configuredPort int) (string, Port, error
 	return ho, Port(po), nil
 }
 
-func configureAccessControls(conf *config.Gateway, confCtx *hcl.EvalContext) (ac.Map, error) {
+func configureAccessControls(conf *config.Gateway, confCtx *hcl.EvalContext, backends map[string]backendDefinition, log *logrus.Entry) (ac.Map, error) {
 	accessControls := make(ac.Map)
 
 	if conf.Definitions != nil {
@@ -338,32 +424,107 @@ func configureAccessControls(conf *config.Gateway, confCtx *hcl.EvalContext) (ac
 			if err != nil {
 				return nil, err
 			}
+			basicAuth.SetLogger(log)
+
+			if ba.MaxFailures > 0 {
+				var lockout time.Duration
+				if ba.LockoutDuration != "" {
+					if lockout, err = time.ParseDuration(ba.LockoutDuration); err != nil {
+						return nil, fmt.Errorf("basic_auth %q: lockout_duration: %v", name, err)
+					}
+				}
+				basicAuth.SetLockout(ac.NewLockout(name, ba.MaxFailures, lockout, log))
+			}
 
 			accessControls[name] = basicAuth
 		}
 
+		for _, apiKey := range conf.Definitions.APIKey {
+			name, err := validateACName(accessControls, apiKey.Name, "api_key")
+			if err != nil {
+				return nil, err
+			}
+
+			header := apiKey.Header
+			if header == "" && apiKey.QueryParam == "" {
+				header = "X-API-Key"
+			}
+
+			source, sourceKey, err := newTokenSource("", header, "", apiKey.QueryParam)
+			if err != nil {
+				return nil, fmt.Errorf("api_key %q: %v", name, err)
+			}
+
+			a, err := ac.NewAPIKey(name, apiKey.File, source, sourceKey, log)
+			if err != nil {
+				return nil, fmt.Errorf("loading api_key %q definition failed: %s", name, err)
+			}
+
+			accessControls[name] = a
+		}
+
+		for _, ipFilter := range conf.Definitions.IPFilter {
+			name, err := validateACName(accessControls, ipFilter.Name, "ip_filter")
+			if err != nil {
+				return nil, err
+			}
+
+			f, err := ac.NewIPFilter(name, ipFilter.Allow, ipFilter.Deny)
+			if err != nil {
+				return nil, fmt.Errorf("loading ip_filter %q definition failed: %s", name, err)
+			}
+
+			accessControls[name] = f
+		}
+
 		for _, jwt := range conf.Definitions.JWT {
 			name, err := validateACName(accessControls, jwt.Name, "jwt")
 			if err != nil {
 				return nil, err
 			}
 
-			var jwtSource ac.Source
-			var jwtKey string
-			if jwt.Cookie != "" {
-				jwtSource = ac.Cookie
-				jwtKey = jwt.Cookie
-			} else if jwt.Header != "" {
-				jwtSource = ac.Header
-				jwtKey = jwt.Header
+			jwtSource, jwtKey, err := newTokenSource(jwt.Cookie, jwt.Header, jwt.PostParam, jwt.QueryParam)
+			if err != nil {
+				return nil, fmt.Errorf("jwt %q: %v", name, err)
 			}
-			var key []byte
-			if jwt.KeyFile != "" {
-				wd, err := os.Getwd()
+			var claims ac.Claims
+			if jwt.Claims != nil {
+				c, diags := seetie.ExpToMap(confCtx, jwt.Claims)
+				if diags.HasErrors() {
+					return nil, diags
+				}
+				claims = c
+			}
+
+			if jwt.JWKSURL != "" || jwt.JWKSFile != "" {
+				if jwt.Key != "" || jwt.KeyFile != "" {
+					return nil, fmt.Errorf("jwt %q: key and key_file cannot be combined with jwks_url or jwks_file", name)
+				}
+
+				var ttl time.Duration
+				if jwt.JWKSTTL != "" {
+					if ttl, err = time.ParseDuration(jwt.JWKSTTL); err != nil {
+						return nil, fmt.Errorf("jwt %q: jwks_ttl: %v", name, err)
+					}
+				}
+
+				jwks, err := ac.NewJWKS(jwt.JWKSURL, jwt.JWKSFile, ttl)
 				if err != nil {
-					return nil, err
+					return nil, fmt.Errorf("loading jwt %q definition failed: %s", name, err)
+				}
+
+				j, err := ac.NewJWTFromJWKS(jwt.SignatureAlgorithm, name, claims, jwt.ClaimsRequired, jwtSource, jwtKey, jwks)
+				if err != nil {
+					return nil, fmt.Errorf("loading jwt %q definition failed: %s", name, err)
 				}
-				content, err := ioutil.ReadFile(path.Join(wd, jwt.KeyFile))
+
+				accessControls[name] = j
+				continue
+			}
+
+			var key []byte
+			if jwt.KeyFile != "" {
+				content, err := readFile(jwt.KeyFile)
 				if err != nil {
 					return nil, err
 				}
@@ -372,14 +533,6 @@ func configureAccessControls(conf *config.Gateway, confCtx *hcl.EvalContext) (ac
 				key = []byte(jwt.Key)
 			}
 
-			var claims ac.Claims
-			if jwt.Claims != nil {
-				c, diags := seetie.ExpToMap(confCtx, jwt.Claims)
-				if diags.HasErrors() {
-					return nil, diags
-				}
-				claims = c
-			}
 			j, err := ac.NewJWT(jwt.SignatureAlgorithm, name, claims, jwt.ClaimsRequired, jwtSource, jwtKey, key)
 			if err != nil {
 				return nil, fmt.Errorf("loading jwt %q definition failed: %s", name, err)
@@ -387,11 +540,249 @@ func configureAccessControls(conf *config.Gateway, confCtx *hcl.EvalContext) (ac
 
 			accessControls[name] = j
 		}
+
+		for _, introspection := range conf.Definitions.OAuth2Introspection {
+			name, err := validateACName(accessControls, introspection.Name, "oauth2_introspection")
+			if err != nil {
+				return nil, err
+			}
+
+			be, ok := backends[introspection.Backend]
+			if !ok {
+				return nil, fmt.Errorf("oauth2_introspection %q: backend %q is not defined", name, introspection.Backend)
+			}
+
+			source, sourceKey, err := newTokenSource(introspection.Cookie, introspection.Header, introspection.PostParam, introspection.QueryParam)
+			if err != nil {
+				return nil, fmt.Errorf("oauth2_introspection %q: %v", name, err)
+			}
+
+			var ttl time.Duration
+			if introspection.TTL != "" {
+				if ttl, err = time.ParseDuration(introspection.TTL); err != nil {
+					return nil, fmt.Errorf("oauth2_introspection %q: ttl: %v", name, err)
+				}
+			}
+
+			backend, ok := be.handler.(http.RoundTripper)
+			if !ok {
+				return nil, fmt.Errorf("oauth2_introspection %q: backend %q does not support round trips", name, introspection.Backend)
+			}
+
+			i, err := ac.NewOAuth2Introspection(name, backend, introspection.ClientID, introspection.ClientSecret, source, sourceKey, ttl)
+			if err != nil {
+				return nil, fmt.Errorf("loading oauth2_introspection %q definition failed: %s", name, err)
+			}
+
+			accessControls[name] = i
+		}
+
+		for _, oidc := range conf.Definitions.OIDC {
+			name, err := validateACName(accessControls, oidc.Name, "oidc")
+			if err != nil {
+				return nil, err
+			}
+
+			var ttl time.Duration
+			if oidc.SessionTTL != "" {
+				if ttl, err = time.ParseDuration(oidc.SessionTTL); err != nil {
+					return nil, fmt.Errorf("oidc %q: session_ttl: %v", name, err)
+				}
+			}
+
+			o, err := ac.NewOIDC(name, ac.OIDCOptions{
+				AuthorizationEndpoint: oidc.AuthorizationEndpoint,
+				ClientID:              oidc.ClientID,
+				ClientSecret:          oidc.ClientSecret,
+				ConfigurationURL:      oidc.ConfigurationURL,
+				CookieName:            oidc.CookieName,
+				Issuer:                oidc.Issuer,
+				JWKSURL:               oidc.JWKSURL,
+				LogoutRedirectURI:     oidc.LogoutRedirectURI,
+				RedirectURI:           oidc.RedirectURI,
+				Scopes:                oidc.Scopes,
+				SessionSecret:         oidc.SessionSecret,
+				SessionTTL:            ttl,
+				TokenEndpoint:         oidc.TokenEndpoint,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("loading oidc %q definition failed: %s", name, err)
+			}
+
+			accessControls[name] = o
+		}
+
+		for _, mtls := range conf.Definitions.MTLS {
+			name, err := validateACName(accessControls, mtls.Name, "mtls")
+			if err != nil {
+				return nil, err
+			}
+
+			caBundle, err := readFile(mtls.CAFile)
+			if err != nil {
+				return nil, err
+			}
+
+			var crl []byte
+			if mtls.CRLFile != "" {
+				crl, err = readFile(mtls.CRLFile)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			m, err := ac.NewMTLS(name, caBundle, mtls.SubjectPattern, mtls.SANPatterns, crl)
+			if err != nil {
+				return nil, fmt.Errorf("loading mtls %q definition failed: %s", name, err)
+			}
+
+			accessControls[name] = m
+		}
+
+		// groups reference the access controls defined above
+		for _, group := range conf.Definitions.AccessControlGroup {
+			name, err := validateACName(accessControls, group.Name, "access_control_group")
+			if err != nil {
+				return nil, err
+			}
+
+			var members ac.List
+			for _, member := range group.AccessControl {
+				control, exist := accessControls[member]
+				if !exist {
+					return nil, fmt.Errorf("access_control_group %q: access control %q is not defined", name, member)
+				}
+				if _, isGroup := control.(*ac.Group); isGroup {
+					return nil, fmt.Errorf("access_control_group %q: groups cannot be nested: %q", name, member)
+				}
+				members = append(members, control)
+			}
+
+			g, err := ac.NewGroup(name, members...)
+			if err != nil {
+				return nil, fmt.Errorf("loading access_control_group %q definition failed: %s", name, err)
+			}
+
+			accessControls[name] = g
+		}
 	}
 
 	return accessControls, nil
 }
 
+// configureOIDCRoutes registers the callback and logout routes of all oidc access controls
+// which are referenced by the given server.
+func configureOIDCRoutes(srvConf *ServerConfiguration, confPort int, conf *config.Gateway, serverConf *config.Server,
+	m ac.Map, serverOptions *server.Options, log *logrus.Entry) error {
+	if conf.Definitions == nil || len(conf.Definitions.OIDC) == 0 {
+		return nil
+	}
+
+	referenced := make(map[string]bool)
+	for _, name := range serverConf.AccessControl {
+		referenced[name] = true
+	}
+	if serverConf.Spa != nil {
+		for _, name := range serverConf.Spa.AccessControl {
+			referenced[name] = true
+		}
+	}
+	if serverConf.Files != nil {
+		for _, name := range serverConf.Files.AccessControl {
+			referenced[name] = true
+		}
+	}
+	if serverConf.API != nil {
+		for _, name := range serverConf.API.AccessControl {
+			referenced[name] = true
+		}
+		for _, endpoint := range serverConf.API.Endpoint {
+			for _, name := range endpoint.AccessControl {
+				referenced[name] = true
+			}
+		}
+	}
+
+	for _, group := range conf.Definitions.AccessControlGroup {
+		if referenced[group.Name] {
+			for _, name := range group.AccessControl {
+				referenced[name] = true
+			}
+		}
+	}
+
+	for _, oidcConf := range conf.Definitions.OIDC {
+		if !referenced[oidcConf.Name] {
+			continue
+		}
+
+		oidc := m[oidcConf.Name].(*ac.OIDC)
+		err := setRoutesFromHosts(srvConf, confPort, serverConf.Hosts, oidc.RedirectPath(),
+			handler.NewOIDCCallback(oidc, serverOptions.ServerErrTpl, log), KindAPI)
+		if err != nil {
+			return err
+		}
+
+		if oidcConf.LogoutPath != "" {
+			err = setRoutesFromHosts(srvConf, confPort, serverConf.Hosts, oidcConf.LogoutPath, handler.NewOIDCLogout(oidc), KindAPI)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// authorizeExpressions returns the configured expressions, missing attributes are decoded as null expressions.
+func authorizeExpressions(exprs ...hcl.Expression) []hcl.Expression {
+	var result []hcl.Expression
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+		if val, diags := expr.Value(nil); !diags.HasErrors() && val.IsNull() {
+			continue
+		}
+		result = append(result, expr)
+	}
+	return result
+}
+
+// readFile reads the given file relative to the working directory.
+func readFile(file string) ([]byte, error) {
+	if filepath.IsAbs(file) {
+		return ioutil.ReadFile(file)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path.Join(wd, file))
+}
+
+// newTokenSource returns the configured token source, only one source is allowed.
+func newTokenSource(cookie, header, postParam, queryParam string) (ac.Source, string, error) {
+	source, key := ac.Unknown, ""
+	for _, s := range []struct {
+		source ac.Source
+		key    string
+	}{
+		{ac.Cookie, cookie},
+		{ac.Header, header},
+		{ac.PostParam, postParam},
+		{ac.QueryParam, queryParam},
+	} {
+		if s.key == "" {
+			continue
+		}
+		if source != ac.Unknown {
+			return ac.Unknown, "", fmt.Errorf("only one of cookie, header, post_param or query_param is allowed")
+		}
+		source, key = s.source, s.key
+	}
+	return source, key, nil
+}
+
 func configureProtectedHandler(m ac.Map, errTpl *errors.Template, parentAC, handlerAC config.AccessControl, h http.Handler) http.Handler {
 	var acList ac.List
 	for _, acName := range parentAC.
@@ -405,7 +796,27 @@ func configureProtectedHandler(m ac.Map, errTpl *errors.Template, parentAC, hand
 	return h
 }
 
-func newInlineBackend(evalCtx *hcl.EvalContext, backends map[string]backendDefinition, inlineDef hcl.Body, cors *config.CORS, log *logrus.Entry, srvOpts *server.Options) (http.Handler, *config.Backend, error) {
+// configureRateLimits wraps the given handler with the configured rate limiters, the first one is checked first.
+// Only limiters whose key references access control data are applied with afterAccessControl, all others without.
+func configureRateLimits(h http.Handler, errTpl *errors.Template, evalCtx *hcl.EvalContext, log *logrus.Entry, afterAccessControl bool, limiters ...*handler.RateLimiter) http.Handler {
+	for i := len(limiters) - 1; i >= 0; i-- {
+		if limiters[i] != nil && limiters[i].UsesAccessControl() == afterAccessControl {
+			h = handler.NewRateLimit(h, limiters[i], errTpl, evalCtx, log)
+		}
+	}
+	return h
+}
+
+// configureCompression wraps the given handler with the response compression if configured.
+func configureCompression(h http.Handler, compression *handler.Compression) http.Handler {
+	if compression != nil {
+		return handler.NewCompress(h, compression)
+	}
+	return h
+}
+
+// newInlineBackend creates the proxy of an inline backend block, a given endpoint cache replaces the backend one.
+func newInlineBackend(evalCtx *hcl.EvalContext, backends map[string]backendDefinition, inlineDef hcl.Body, cache *config.Cache, cors *config.CORS, states backendStates, log *logrus.Entry, srvOpts *server.Options) (http.Handler, *config.Backend, error) {
 	content, _, diags := inlineDef.PartialContent(config.Endpoint{}.Schema(true))
 	if diags.HasErrors() {
 		return nil, nil, diags
@@ -445,7 +856,14 @@ func newInlineBackend(evalCtx *hcl.EvalContext, backends map[string]backendDefin
 		}
 	}
 
-	proxy := newProxy(evalCtx, beConf, cors, []hcl.Body{beConf.Options}, log, srvOpts)
+	if cache != nil {
+		beConf.Cache = cache
+	}
+
+	proxy, err := newProxy(evalCtx, beConf, cors, []hcl.Body{beConf.Options}, states, log, srvOpts)
+	if err != nil {
+		return nil, nil, err
+	}
 	return proxy, beConf, nil
 }
 
@@ -1,10 +1,16 @@
 package runtime
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
+	ac "github.com/avenga/couper/accesscontrol"
 	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/internal/test"
 )
 
 func TestServer_isUnique(t *testing.T) {
@@ -165,3 +171,193 @@ func TestServer_splitWildcardHostPort(t *testing.T) {
 		t.Errorf("Expected NIL, given %s", err)
 	}
 }
+
+func TestServer_configureTLS(t *testing.T) {
+	helper := test.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "couper_tls")
+	helper.Must(err)
+	defer os.RemoveAll(tmpDir)
+
+	certFile, keyFile := helper.WriteFiles(helper.NewCertificate(helper.NewCA("ca"), "couper.io", "couper.io"), tmpDir, "couper")
+	tlsBlock := &config.ServerTLS{CertFile: certFile, KeyFile: keyFile}
+
+	tests := []struct {
+		name    string
+		servers []*config.Server
+		tlsPort []Port
+		wantErr bool
+	}{
+		{"plain", []*config.Server{{Hosts: []string{"*"}}}, nil, false},
+		{"tls", []*config.Server{{Hosts: []string{"*"}, TLS: tlsBlock}}, []Port{8080}, false},
+		{"tls and plain on different ports", []*config.Server{
+			{Hosts: []string{"couper.io:8443"}, TLS: tlsBlock},
+			{Hosts: []string{"*"}},
+		}, []Port{8443}, false},
+		{"tls and plain on same port", []*config.Server{
+			{Hosts: []string{"couper.io"}, TLS: tlsBlock},
+			{Hosts: []string{"example.com"}},
+		}, nil, true},
+		{"same host with another tls block", []*config.Server{
+			{Hosts: []string{"couper.io"}, TLS: tlsBlock},
+			{Hosts: []string{"Couper.io."}, TLS: tlsBlock},
+		}, nil, true},
+		{"wildcard host with another tls block", []*config.Server{
+			{Hosts: []string{"*"}, TLS: tlsBlock},
+			{Hosts: []string{"*:8080"}, TLS: tlsBlock},
+		}, nil, true},
+		{"same host on different ports", []*config.Server{
+			{Hosts: []string{"couper.io"}, TLS: tlsBlock},
+			{Hosts: []string{"couper.io:8443"}, TLS: tlsBlock},
+		}, []Port{8080, 8443}, false},
+		{"invalid min version", []*config.Server{
+			{TLS: &config.ServerTLS{CertFile: certFile, KeyFile: keyFile, MinVersion: "SSL3"}},
+		}, nil, true},
+		{"invalid cipher suite", []*config.Server{
+			{TLS: &config.ServerTLS{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_NULL"}}},
+		}, nil, true},
+		{"missing cert file", []*config.Server{
+			{TLS: &config.ServerTLS{CertFile: "not-there.crt", KeyFile: keyFile}},
+		}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			srvConf := &ServerConfiguration{PortOptions: map[Port]*MuxOptions{
+				8080: NewMuxOptions(nil),
+				8443: NewMuxOptions(nil),
+			}}
+			err := configureTLS(&config.Gateway{Server: tt.servers}, srvConf, 8080)
+			if (err != nil) != tt.wantErr {
+				subT.Fatalf("configureTLS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			for _, p := range tt.tlsPort {
+				if srvConf.PortOptions[p].TLS == nil {
+					subT.Errorf("expected a tls configuration for port %d", p)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_configureTLS_ServerName(t *testing.T) {
+	helper := test.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "couper_tls")
+	helper.Must(err)
+	defer os.RemoveAll(tmpDir)
+
+	ca := helper.NewCA("ca")
+	exampleCert, exampleKey := helper.WriteFiles(helper.NewCertificate(ca, "example.com", "example.com"), tmpDir, "example")
+	couperCert, couperKey := helper.WriteFiles(helper.NewCertificate(ca, "couper.io", "couper.io"), tmpDir, "couper")
+
+	srvConf := &ServerConfiguration{PortOptions: map[Port]*MuxOptions{8080: NewMuxOptions(nil)}}
+	err = configureTLS(&config.Gateway{Server: []*config.Server{
+		{Hosts: []string{"example.com"}, TLS: &config.ServerTLS{CertFile: exampleCert, KeyFile: exampleKey}},
+		{Hosts: []string{"Couper.IO."}, TLS: &config.ServerTLS{CertFile: couperCert, KeyFile: couperKey}},
+	}}, srvConf, 8080)
+	helper.Must(err)
+
+	for serverName, expCN := range map[string]string{
+		"couper.io":   "couper.io",
+		"COUPER.io.":  "couper.io",
+		"example.com": "example.com",
+		"unknown.com": "example.com",
+	} {
+		conf, err := srvConf.PortOptions[8080].TLS.GetConfigForClient(&tls.ClientHelloInfo{ServerName: serverName})
+		helper.Must(err)
+
+		cert, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+		helper.Must(err)
+
+		if cert.Subject.CommonName != expCN {
+			t.Errorf("%s: expected certificate %q, got: %q", serverName, expCN, cert.Subject.CommonName)
+		}
+	}
+}
+
+func TestServer_hasMTLSReference(t *testing.T) {
+	definitions := &config.Definitions{
+		MTLS: []*config.MTLS{{Name: "client"}},
+		AccessControlGroup: []*config.AccessControlGroup{
+			{Name: "clients", AccessControl: []string{"basic", "client"}},
+			{Name: "others", AccessControl: []string{"basic"}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		srv  *config.Server
+		want bool
+	}{
+		{"none", &config.Server{}, false},
+		{"server", &config.Server{AccessControl: []string{"client"}, Spa: &config.Spa{}}, true},
+		{"endpoint", &config.Server{API: &config.Api{Endpoint: []*config.Endpoint{{AccessControl: []string{"client"}}}}}, true},
+		{"group", &config.Server{Files: &config.Files{AccessControl: []string{"clients"}}}, true},
+		{"group without mtls", &config.Server{Files: &config.Files{AccessControl: []string{"others"}}}, false},
+		{"disabled", &config.Server{AccessControl: []string{"client"},
+			Files: &config.Files{DisableAccessControl: []string{"client"}}}, false},
+		{"disabled group", &config.Server{API: &config.Api{AccessControl: []string{"clients"},
+			Endpoint: []*config.Endpoint{{DisableAccessControl: []string{"clients"}}}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			if got := hasMTLSReference(&config.Gateway{Definitions: definitions}, tt.srv); got != tt.want {
+				subT.Errorf("hasMTLSReference() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_newTokenSource(t *testing.T) {
+	for i, tc := range []struct {
+		conf    *config.JWT
+		source  ac.Source
+		key     string
+		wantErr bool
+	}{
+		{&config.JWT{}, ac.Unknown, "", false},
+		{&config.JWT{Cookie: "token"}, ac.Cookie, "token", false},
+		{&config.JWT{Header: "Authorization"}, ac.Header, "Authorization", false},
+		{&config.JWT{PostParam: "access_token"}, ac.PostParam, "access_token", false},
+		{&config.JWT{QueryParam: "access_token"}, ac.QueryParam, "access_token", false},
+		{&config.JWT{Header: "Authorization", QueryParam: "access_token"}, ac.Unknown, "", true},
+		{&config.JWT{Cookie: "token", PostParam: "access_token"}, ac.Unknown, "", true},
+	} {
+		source, key, err := newTokenSource(tc.conf.Cookie, tc.conf.Header, tc.conf.PostParam, tc.conf.QueryParam)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%d: expected error: %t, got: %v", i+1, tc.wantErr, err)
+		}
+		if source != tc.source || key != tc.key {
+			t.Errorf("%d: expected source %d with key %q, got: %d, %q", i+1, tc.source, tc.key, source, key)
+		}
+	}
+}
+
+func TestServer_configureAccessControlGroups(t *testing.T) {
+	newConf := func(groups ...*config.AccessControlGroup) *config.Gateway {
+		return &config.Gateway{Definitions: &config.Definitions{
+			BasicAuth:          []*config.BasicAuth{{Name: "ba", User: "user", Pass: "pass"}},
+			AccessControlGroup: groups,
+		}}
+	}
+
+	acMap, err := configureAccessControls(newConf(&config.AccessControlGroup{Name: "any", AccessControl: []string{"ba"}}), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := acMap["any"].(*ac.Group); !ok {
+		t.Errorf("expected a group, got: %T", acMap["any"])
+	}
+
+	for _, groups := range [][]*config.AccessControlGroup{
+		{{Name: "any", AccessControl: []string{"undefined"}}},
+		{{Name: "ba", AccessControl: []string{"ba"}}},
+		{{Name: "any", AccessControl: []string{"ba"}}, {Name: "nested", AccessControl: []string{"any"}}},
+		{{Name: "empty"}},
+	} {
+		if _, err = configureAccessControls(newConf(groups...), nil, nil, nil); err == nil {
+			t.Errorf("expected an error for %q", groups[len(groups)-1].Name)
+		}
+	}
+}
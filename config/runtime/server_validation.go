@@ -104,6 +104,25 @@ func validateOrigin(origin string, ctxRange hcl.Range) error {
 	return nil
 }
 
+// validateBackendOrigin validates the origin attribute of the given backend.
+// A configured load_balancer replaces the origin attribute, its origins are validated on creation.
+func validateBackendOrigin(ctx *hcl.EvalContext, beConf *config.Backend, configBytes []byte) error {
+	origin := getAttribute(ctx, "origin", beConf.Options, configBytes)
+	if beConf.LoadBalancer == nil {
+		return validateOrigin(origin, beConf.Options.MissingItemRange())
+	}
+
+	if origin != "" {
+		ctxRange := beConf.Options.MissingItemRange()
+		return hcl.Diagnostics{&hcl.Diagnostic{
+			Subject: &ctxRange,
+			Summary: "invalid backend.origin value",
+			Detail:  "origin attribute and load_balancer block are mutually exclusive",
+		}}
+	}
+	return nil
+}
+
 func validateACName(accessControls ac.Map, name, acType string) (string, error) {
 	name = strings.TrimSpace(name)
 
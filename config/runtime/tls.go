@@ -0,0 +1,195 @@
+package runtime
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/utils"
+)
+
+// serverCertificates holds the tls configurations of all servers sharing one port.
+// The matching configuration gets selected by the client hello server name (SNI).
+type serverCertificates struct {
+	configs  map[string]*tls.Config
+	fallback *tls.Config
+}
+
+// getConfigForClient selects the tls configuration by the requested server name.
+// Requests without a known server name are answered with the wildcard host configuration
+// or the first configured one.
+func (sc *serverCertificates) getConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	if conf, ok := sc.configs[strings.TrimSuffix(strings.ToLower(hello.ServerName), ".")]; ok {
+		return conf, nil
+	}
+	return sc.fallback, nil
+}
+
+func (sc *serverCertificates) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	conf, _ := sc.getConfigForClient(hello)
+	return &conf.Certificates[0], nil
+}
+
+// configureTLS creates a tls.Config for each port which has servers with a configured tls block.
+// All servers which are sharing a port must be configured with a tls block.
+func configureTLS(conf *config.Gateway, srvConf *ServerConfiguration, defaultPort int) error {
+	portCerts := make(map[Port]*serverCertificates)
+	plainPorts := make(map[Port]string)
+
+	for _, srv := range conf.Server {
+		hostList := srv.Hosts
+		if len(hostList) == 0 {
+			hostList = []string{"*"}
+		}
+
+		var tlsConf *tls.Config
+		if srv.TLS != nil {
+			c, err := newServerTLSConfig(srv.TLS)
+			if err != nil {
+				return fmt.Errorf("server %q: %v", srv.Name, err)
+			}
+			tlsConf = c
+
+			// request a client certificate during the handshake for mtls access controls,
+			// the verification is part of the access control.
+			if hasMTLSReference(conf, srv) {
+				tlsConf.ClientAuth = tls.RequestClientCert
+			}
+		}
+
+		for _, h := range hostList {
+			host, port, err := splitWildcardHostPort(h, defaultPort)
+			if err != nil {
+				return err
+			}
+
+			if tlsConf == nil {
+				plainPorts[port] = srv.Name
+				continue
+			}
+
+			certs, ok := portCerts[port]
+			if !ok {
+				certs = &serverCertificates{configs: make(map[string]*tls.Config)}
+				portCerts[port] = certs
+			}
+
+			if host == "*" || certs.fallback == nil {
+				certs.fallback = tlsConf
+			}
+
+			// normalized like the server name of the client hello
+			name := strings.TrimSuffix(strings.ToLower(host), ".")
+			if c, exists := certs.configs[name]; exists && c != tlsConf {
+				return fmt.Errorf("server %q: host %q is configured with another tls block for port %d", srv.Name, h, port)
+			}
+			certs.configs[name] = tlsConf
+		}
+	}
+
+	for port, certs := range portCerts {
+		if name, ok := plainPorts[port]; ok {
+			return fmt.Errorf("server %q requires a tls block: port %d is configured for tls", name, port)
+		}
+
+		srvConf.PortOptions[port].TLS = &tls.Config{
+			GetCertificate:     certs.getCertificate,
+			GetConfigForClient: certs.getConfigForClient,
+		}
+	}
+
+	return nil
+}
+
+func newServerTLSConfig(conf *config.ServerTLS) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %v", err)
+	}
+
+	minVersion, err := utils.ParseTLSVersion(conf.MinVersion)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %v", err)
+	}
+
+	cipherSuites, err := utils.ParseCipherSuites(conf.CipherSuites)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %v", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		CipherSuites: cipherSuites,
+		MinVersion:   minVersion,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}, nil
+}
+
+// hasMTLSReference checks if any block of the given server refers to an active mtls access control,
+// directly or as member of an access_control_group.
+func hasMTLSReference(conf *config.Gateway, srv *config.Server) bool {
+	if conf.Definitions == nil || len(conf.Definitions.MTLS) == 0 {
+		return false
+	}
+
+	mtls := make(map[string]bool)
+	for _, m := range conf.Definitions.MTLS {
+		mtls[m.Name] = true
+	}
+	groups := make(map[string][]string)
+	for _, group := range conf.Definitions.AccessControlGroup {
+		groups[group.Name] = group.AccessControl
+	}
+
+	// the merged lists are evaluated immediately since Merge may share the backing arrays
+	references := func(acl config.AccessControl) bool {
+		for _, name := range acl.List() {
+			if referencesMTLS(name, mtls, groups, make(map[string]bool)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	newSrvAC := func() config.AccessControl {
+		return config.NewAccessControl(srv.AccessControl, srv.DisableAccessControl)
+	}
+
+	if srv.API != nil {
+		for _, endpoint := range srv.API.Endpoint {
+			if references(newSrvAC().
+				Merge(config.NewAccessControl(srv.API.AccessControl, srv.API.DisableAccessControl)).
+				Merge(config.NewAccessControl(endpoint.AccessControl, endpoint.DisableAccessControl))) {
+				return true
+			}
+		}
+	}
+	if srv.Files != nil &&
+		references(newSrvAC().Merge(config.NewAccessControl(srv.Files.AccessControl, srv.Files.DisableAccessControl))) {
+		return true
+	}
+	if srv.Spa != nil &&
+		references(newSrvAC().Merge(config.NewAccessControl(srv.Spa.AccessControl, srv.Spa.DisableAccessControl))) {
+		return true
+	}
+	return false
+}
+
+// referencesMTLS resolves the members of access control groups recursively.
+func referencesMTLS(name string, mtls map[string]bool, groups map[string][]string, visited map[string]bool) bool {
+	if mtls[name] {
+		return true
+	}
+	if visited[name] {
+		return false
+	}
+	visited[name] = true
+
+	for _, member := range groups[name] {
+		if referencesMTLS(member, mtls, groups, visited) {
+			return true
+		}
+	}
+	return false
+}
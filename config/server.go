@@ -1,13 +1,16 @@
 package config
 
 type Server struct {
-	AccessControl        []string `hcl:"access_control,optional"`
-	DisableAccessControl []string `hcl:"disable_access_control,optional"`
-	API                  *Api     `hcl:"api,block"`
-	BasePath             string   `hcl:"base_path,optional"`
-	ErrorFile            string   `hcl:"error_file,optional"`
-	Files                *Files   `hcl:"files,block"`
-	Hosts                []string `hcl:"hosts,optional"`
-	Name                 string   `hcl:"name,label"`
-	Spa                  *Spa     `hcl:"spa,block"`
+	AccessControl        []string     `hcl:"access_control,optional"`
+	DisableAccessControl []string     `hcl:"disable_access_control,optional"`
+	API                  *Api         `hcl:"api,block"`
+	BasePath             string       `hcl:"base_path,optional"`
+	Compression          *Compression `hcl:"compression,block"`
+	ErrorFile            string       `hcl:"error_file,optional"`
+	Files                *Files       `hcl:"files,block"`
+	Hosts                []string     `hcl:"hosts,optional"`
+	Name                 string       `hcl:"name,label"`
+	RateLimit            *RateLimit   `hcl:"rate_limit,block"`
+	Spa                  *Spa         `hcl:"spa,block"`
+	TLS                  *ServerTLS   `hcl:"tls,block"`
 }
@@ -3,9 +3,15 @@ package config
 const DefaultListenPort = 8080
 
 type Settings struct {
-	DefaultPort     int    `hcl:"default_port,optional"`
-	HealthPath      string `hcl:"health_path,optional"`
-	LogFormat       string `hcl:"log_format,optional"`
-	XForwardedHost  bool   `hcl:"xfh,optional"`
-	RequestIDFormat string `hcl:"request_id_format,optional"`
+	DefaultPort     int      `hcl:"default_port,optional"`
+	HealthDetails   bool     `hcl:"health_details,optional"`
+	HealthPath      string   `hcl:"health_path,optional"`
+	LogFormat       string   `hcl:"log_format,optional"`
+	MetricsPath     string   `hcl:"metrics_path,optional"`
+	MetricsPort     int      `hcl:"metrics_port,optional"`
+	XForwardedHost  bool     `hcl:"xfh,optional"`
+	RequestIDFormat string   `hcl:"request_id_format,optional"`
+	Tracing         *Tracing `hcl:"tracing,block"`
+	TrustedProxies  []string `hcl:"trusted_proxies,optional"`
+	Watch           bool     `hcl:"watch,optional"`
 }
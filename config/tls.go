@@ -0,0 +1,9 @@
+package config
+
+// ServerTLS represents the "tls" config block of a server.
+type ServerTLS struct {
+	CertFile     string   `hcl:"cert_file"`
+	CipherSuites []string `hcl:"cipher_suites,optional"`
+	KeyFile      string   `hcl:"key_file"`
+	MinVersion   string   `hcl:"min_version,optional"`
+}
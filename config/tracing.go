@@ -0,0 +1,10 @@
+package config
+
+type Tracing struct {
+	Endpoint    string            `hcl:"endpoint,optional"`
+	Exporter    string            `hcl:"exporter"`
+	File        string            `hcl:"file,optional"`
+	Headers     map[string]string `hcl:"headers,optional"`
+	SampleRatio *float64          `hcl:"sample_ratio,optional"`
+	ServiceName string            `hcl:"service_name,optional"`
+}
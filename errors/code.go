@@ -6,6 +6,7 @@ const (
 	Configuration
 	InvalidRequest
 	RouteNotFound
+	RateLimitExceeded
 )
 
 const (
@@ -23,21 +24,26 @@ const (
 	APIRouteNotFound
 	APIConnect
 	APIReqBodySizeExceeded
+	APIUnhealthyOrigin
+	APICircuitOpen
+	APIConcurrencyLimit
 )
 
 const (
 	AuthorizationRequired Code = 5000 + iota
 	AuthorizationFailed
 	BasicAuthFailed
+	BasicAuthLocked
 )
 
 var codes = map[Code]string{
 	// 1xxx
-	Server:         "Server error",
-	ServerShutdown: "Server is shutting down",
-	Configuration:  "Configuration failed",
-	InvalidRequest: "Invalid request",
-	RouteNotFound:  "Route not found",
+	Server:            "Server error",
+	ServerShutdown:    "Server is shutting down",
+	Configuration:     "Configuration failed",
+	InvalidRequest:    "Invalid request",
+	RouteNotFound:     "Route not found",
+	RateLimitExceeded: "Rate limit exceeded",
 	// 2xxx
 	SPAError:         "SPA failed",
 	SPARouteNotFound: "SPA route not found",
@@ -49,10 +55,14 @@ var codes = map[Code]string{
 	APIRouteNotFound:       "API route not found",
 	APIConnect:             "API upstream connection error",
 	APIReqBodySizeExceeded: "Request body size exceeded",
+	APIUnhealthyOrigin:     "API upstream is unhealthy",
+	APICircuitOpen:         "API circuit breaker is open",
+	APIConcurrencyLimit:    "API backend concurrency limit exceeded",
 	// 5xxx
 	AuthorizationRequired: "Authorization required",
 	AuthorizationFailed:   "Authorization failed",
 	BasicAuthFailed:       "Unauthorized",
+	BasicAuthLocked:       "Too many failed attempts",
 }
 
 type Code int
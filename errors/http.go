@@ -9,8 +9,10 @@ func httpStatus(code Code) int {
 	switch code {
 	case APIRouteNotFound, FilesRouteNotFound, RouteNotFound, SPARouteNotFound:
 		return http.StatusNotFound
-	case APIConnect:
+	case APIConnect, APIUnhealthyOrigin:
 		return http.StatusBadGateway
+	case APICircuitOpen, APIConcurrencyLimit:
+		return http.StatusServiceUnavailable
 	case APIReqBodySizeExceeded:
 		return http.StatusRequestEntityTooLarge
 	case InvalidRequest:
@@ -19,6 +21,8 @@ func httpStatus(code Code) int {
 		return http.StatusUnauthorized
 	case AuthorizationFailed:
 		return http.StatusForbidden
+	case BasicAuthLocked, RateLimitExceeded:
+		return http.StatusTooManyRequests
 	default:
 		return http.StatusInternalServerError
 	}
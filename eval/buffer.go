@@ -23,30 +23,39 @@ func MustBuffer(ctxBodies []hcl.Body) BufferOption {
 			return result
 		}
 		for _, attr := range attrs {
-			for _, traversal := range attr.Expr.Variables() {
-				if len(traversal) < 2 {
-					continue
-				}
+			result |= MustBufferExpressions(attr.Expr)
+		}
+	}
+	return result
+}
 
-				rootName := traversal.RootName()
-				if rootName != ClientRequest && rootName != BackendResponse {
-					continue
-				}
+// MustBufferExpressions determines if any of the given expressions makes use of 'post' or 'json_body'.
+func MustBufferExpressions(exprs ...hcl.Expression) BufferOption {
+	result := BufferNone
+	for _, expr := range exprs {
+		for _, traversal := range expr.Variables() {
+			if len(traversal) < 2 {
+				continue
+			}
 
-				nameField := reflect.ValueOf(traversal[1]).FieldByName("Name")
-				name := nameField.String()
-				switch name {
-				case JsonBody:
-					switch rootName {
-					case ClientRequest:
-						result |= BufferRequest
-					case BackendResponse:
-						result |= BufferResponse
-					}
-				case Post:
-					if rootName == ClientRequest {
-						result |= BufferRequest
-					}
+			rootName := traversal.RootName()
+			if rootName != ClientRequest && rootName != BackendResponse {
+				continue
+			}
+
+			nameField := reflect.ValueOf(traversal[1]).FieldByName("Name")
+			name := nameField.String()
+			switch name {
+			case JsonBody:
+				switch rootName {
+				case ClientRequest:
+					result |= BufferRequest
+				case BackendResponse:
+					result |= BufferResponse
+				}
+			case Post:
+				if rootName == ClientRequest {
+					result |= BufferRequest
 				}
 			}
 		}
@@ -61,6 +61,10 @@ func NewHTTPContext(baseCtx *hcl.EvalContext, bufOpt BufferOption, req, bereq *h
 		reqCtxMap[Endpoint] = cty.StringVal(endpoint)
 	}
 
+	if clientIP, ok := httpCtx.Value(request.ClientIP).(string); ok {
+		reqCtxMap[ClientIP] = cty.StringVal(clientIP)
+	}
+
 	var id string
 	if uid, ok := httpCtx.Value(request.UID).(string); ok {
 		id = uid
@@ -215,7 +219,8 @@ func newVariable(ctx context.Context, cookies []*http.Cookie, headers http.Heade
 	}
 	var ctxAcMapValue cty.Value
 	if len(ctxAcMap) > 0 {
-		ctxAcMapValue = cty.MapVal(ctxAcMap)
+		// access controls are providing different types, e.g. the claims of different tokens
+		ctxAcMapValue = cty.ObjectVal(ctxAcMap)
 	} else {
 		ctxAcMapValue = cty.MapValEmpty(cty.String)
 	}
@@ -245,6 +250,7 @@ func newFunctionsMap() map[string]function.Function {
 	return map[string]function.Function{
 		"base64_decode": lib.Base64DecodeFunc,
 		"base64_encode": lib.Base64EncodeFunc,
+		"contains":      lib.ContainsFunc,
 		"to_upper":      stdlib.UpperFunc,
 		"to_lower":      stdlib.LowerFunc,
 	}
@@ -15,6 +15,7 @@ import (
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/zclconf/go-cty/cty"
 
+	ac "github.com/avenga/couper/accesscontrol"
 	"github.com/avenga/couper/config"
 	"github.com/avenga/couper/config/request"
 	"github.com/avenga/couper/config/runtime/server"
@@ -147,3 +148,30 @@ func TestNewHTTPContext(t *testing.T) {
 		})
 	}
 }
+
+func TestNewHTTPContext_AccessControlData(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://couper.io/", nil)
+	// access controls provide data of different types
+	*req = *req.Clone(context.WithValue(req.Context(), ac.ContextAccessControlKey, map[string]interface{}{
+		"myjwt":   ac.Claims{"sub": "me", "level": float64(2)},
+		"mybasic": ac.Claims{"user": "john"},
+	}))
+
+	ctx := eval.NewHTTPContext(eval.NewENVContext(nil), eval.BufferNone, req, nil, nil)
+
+	var resultMap map[string]cty.Value
+	err := hclsimple.Decode("test.hcl", []byte(`
+		level = req.ctx.myjwt.level
+		user = req.ctx.mybasic.user
+	`), ctx, &resultMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if level := seetie.ValueToString(resultMap["level"]); level != "2" {
+		t.Errorf("Expected level 2, got: %q", level)
+	}
+	if user := seetie.ValueToString(resultMap["user"]); user != "john" {
+		t.Errorf("Expected user john, got: %q", user)
+	}
+}
@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+var ContainsFunc = newContainsFunction()
+
+// newContainsFunction checks if a list or tuple contains the given value.
+// Strings are handled as space-delimited lists, e.g. an OAuth2 scope claim.
+func newContainsFunction() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{{
+			Name:      "collection",
+			Type:      cty.DynamicPseudoType,
+			AllowNull: true,
+		}, {
+			Name: "value",
+			Type: cty.DynamicPseudoType,
+		}},
+		Type: function.StaticReturnType(cty.Bool),
+		Impl: func(args []cty.Value, _ cty.Type) (ret cty.Value, err error) {
+			collection, value := args[0], args[1]
+			if collection == cty.NilVal || collection.IsNull() {
+				return cty.False, nil
+			}
+
+			ty := collection.Type()
+			switch {
+			case ty == cty.String:
+				if value.Type() != cty.String {
+					return cty.False, nil
+				}
+				for _, item := range strings.Fields(collection.AsString()) {
+					if item == value.AsString() {
+						return cty.True, nil
+					}
+				}
+				return cty.False, nil
+			case ty.IsListType(), ty.IsSetType(), ty.IsTupleType():
+				for it := collection.ElementIterator(); it.Next(); {
+					_, item := it.Element()
+					if item.IsKnown() && !item.IsNull() && item.Type().Equals(value.Type()) && item.Equals(value).True() {
+						return cty.True, nil
+					}
+				}
+				return cty.False, nil
+			default:
+				return cty.False, fmt.Errorf("unsupported collection type: %s", ty.FriendlyName())
+			}
+		},
+	})
+}
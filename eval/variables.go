@@ -3,6 +3,7 @@ package eval
 const (
 	BackendRequest  = "bereq"
 	BackendResponse = "beresp"
+	ClientIP        = "client_ip"
 	ClientRequest   = "req"
 	Context         = "ctx"
 	Cookies         = "cookies"
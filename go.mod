@@ -3,6 +3,7 @@ module github.com/avenga/couper
 go 1.14
 
 require (
+	github.com/andybalholm/brotli v1.0.2
 	github.com/dgrijalva/jwt-go/v4 v4.0.0-preview1
 	github.com/docker/go-units v0.4.0
 	github.com/getkin/kin-openapi v0.23.0
@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"math"
 	"net/http"
+	"strconv"
 
 	ac "github.com/avenga/couper/accesscontrol"
 	"github.com/avenga/couper/errors"
+	"github.com/avenga/couper/tracing"
 )
 
 var (
@@ -28,10 +31,25 @@ func NewAccessControl(protected http.Handler, errTpl *errors.Template, list ...a
 }
 
 func (a *AccessControl) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	_, span := tracing.StartSpan(req.Context(), "access_control", tracing.KindInternal)
+	span.SetAttribute("couper.access_controls", len(a.ac))
+
 	for _, control := range a.ac {
 		if err := control.Validate(req); err != nil {
+			span.SetStatus(tracing.StatusError, err.Error())
+			span.End()
+
+			if redirect, ok := err.(*ac.OIDCRedirectError); ok {
+				http.SetCookie(rw, redirect.Cookie)
+				http.Redirect(rw, req, redirect.URL, http.StatusSeeOther)
+				return
+			}
+
 			var code errors.Code
-			if authError, ok := err.(*ac.BasicAuthError); ok {
+			if lockedError, ok := err.(*ac.BasicAuthLockedError); ok {
+				code = errors.BasicAuthLocked
+				rw.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedError.RetryAfter.Seconds()))))
+			} else if authError, ok := err.(*ac.BasicAuthError); ok {
 				code = errors.BasicAuthFailed
 				wwwAuthenticateValue := "Basic"
 				if authError.Realm != "" {
@@ -42,7 +60,7 @@ func (a *AccessControl) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 				switch err {
 				case ac.ErrorNotConfigured:
 					code = errors.Configuration
-				case ac.ErrorEmptyToken:
+				case ac.ErrorEmptyToken, ac.ErrorMissingSession, ac.ErrorMTLSMissingCertificate:
 					code = errors.AuthorizationRequired
 				default:
 					code = errors.AuthorizationFailed
@@ -52,6 +70,8 @@ func (a *AccessControl) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			return
 		}
 	}
+	span.End()
+
 	a.protected.ServeHTTP(rw, req)
 }
 
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+
+	ac "github.com/avenga/couper/accesscontrol"
+	"github.com/avenga/couper/errors"
+	"github.com/avenga/couper/eval"
+)
+
+// maxAuthorizationBodySize limits the request body buffered for authorize expressions.
+const maxAuthorizationBodySize = 10 << 20
+
+var (
+	_ http.Handler         = &Authorization{}
+	_ errors.ErrorTemplate = &Authorization{}
+	_ ac.ProtectedHandler  = &Authorization{}
+)
+
+// Authorization evaluates the authorize expressions after the access controls have been passed.
+// All expressions must evaluate to true, otherwise the request is rejected.
+// The request body is buffered if an expression references req.post or req.json_body.
+type Authorization struct {
+	bufferOption eval.BufferOption
+	errorTpl     *errors.Template
+	evalCtx      *hcl.EvalContext
+	exprs        []hcl.Expression
+	log          logrus.FieldLogger
+	protected    http.Handler
+}
+
+func NewAuthorization(protected http.Handler, errTpl *errors.Template, evalCtx *hcl.EvalContext, log logrus.FieldLogger, exprs ...hcl.Expression) *Authorization {
+	return &Authorization{
+		bufferOption: eval.MustBufferExpressions(exprs...),
+		errorTpl:     errTpl,
+		evalCtx:      evalCtx,
+		exprs:        exprs,
+		log:          log,
+		protected:    protected,
+	}
+}
+
+func (a *Authorization) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if (a.bufferOption & eval.BufferRequest) == eval.BufferRequest {
+		if err := bufferRequestBody(req); err != nil {
+			a.errorTpl.ServeError(err).ServeHTTP(rw, req)
+			return
+		}
+	}
+
+	evalCtx := eval.NewHTTPContext(a.evalCtx, a.bufferOption, req, nil, nil)
+
+	for _, expr := range a.exprs {
+		if !a.authorize(evalCtx, expr) {
+			a.errorTpl.ServeError(errors.AuthorizationFailed).ServeHTTP(rw, req)
+			return
+		}
+	}
+
+	a.protected.ServeHTTP(rw, req)
+}
+
+func (a *Authorization) authorize(evalCtx *hcl.EvalContext, expr hcl.Expression) bool {
+	val, diags := expr.Value(evalCtx)
+	if diags.HasErrors() {
+		a.log.WithField("authorize", expr.Range().String()).Error(diags.Error())
+		return false
+	}
+
+	if !val.IsKnown() || val.IsNull() || val.Type() != cty.Bool {
+		a.log.WithField("authorize", expr.Range().String()).
+			Errorf("expression must evaluate to a bool value, got: %s", val.Type().FriendlyName())
+		return false
+	}
+	return val.True()
+}
+
+// bufferRequestBody makes the request body available via GetBody like the proxy does for its context.
+func bufferRequestBody(req *http.Request) error {
+	if req.Method == http.MethodTrace || req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(req.Body, maxAuthorizationBodySize+1))
+	if err != nil {
+		return errors.InvalidRequest
+	}
+	if len(b) > maxAuthorizationBodySize {
+		return errors.APIReqBodySizeExceeded
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+func (a *Authorization) Child() http.Handler {
+	return a.protected
+}
+
+func (a *Authorization) Template() *errors.Template {
+	return a.errorTpl
+}
+
+func (a *Authorization) String() string {
+	if h, ok := a.protected.(interface{ String() string }); ok {
+		return h.String()
+	}
+	return "Authorization"
+}
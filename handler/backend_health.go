@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/utils"
+)
+
+const (
+	defaultHealthFailureThreshold = 2
+	defaultHealthInterval         = time.Second * 10
+	defaultHealthSuccessThreshold = 2
+	defaultHealthTimeout          = time.Second * 2
+
+	// maxHealthBodySize limits the response body which gets searched for the expected text.
+	maxHealthBodySize = 1 << 16
+)
+
+// BackendHealth probes the origins of a backend periodically and marks
+// them as unhealthy after the configured amount of failed checks.
+type BackendHealth struct {
+	backendName      string
+	client           *http.Client
+	expectedStatus   []int
+	expectedText     []byte
+	failureThreshold int
+	interval         time.Duration
+	origins          []*Origin
+	path             string
+	startOnce        sync.Once
+	successThreshold int
+}
+
+// OriginHealthState represents the health state of an origin.
+type OriginHealthState struct {
+	Error     string    `json:"error,omitempty"`
+	Failures  int       `json:"failures"`
+	Healthy   bool      `json:"healthy"`
+	LastCheck time.Time `json:"last_check,omitempty"`
+	Successes int       `json:"successes"`
+}
+
+// originHealth holds the health state of an origin which gets updated by the probes.
+type originHealth struct {
+	mu    sync.RWMutex
+	state OriginHealthState
+}
+
+func newOriginHealth() *originHealth {
+	// origins are considered healthy until a check tells otherwise
+	return &originHealth{state: OriginHealthState{Healthy: true}}
+}
+
+func (oh *originHealth) healthy() bool {
+	if oh == nil {
+		return true
+	}
+	oh.mu.RLock()
+	defer oh.mu.RUnlock()
+	return oh.state.Healthy
+}
+
+func (oh *originHealth) State() OriginHealthState {
+	oh.mu.RLock()
+	defer oh.mu.RUnlock()
+	return oh.state
+}
+
+// NewBackendHealth creates the health checks for all origins of the given load balancer.
+func NewBackendHealth(backendName string, conf *config.Health, lb *LoadBalancer, tlsConf *tls.Config) (*BackendHealth, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	bh := &BackendHealth{
+		backendName:      backendName,
+		expectedStatus:   conf.ExpectedStatus,
+		expectedText:     []byte(conf.ExpectedText),
+		failureThreshold: conf.FailureThreshold,
+		interval:         defaultHealthInterval,
+		origins:          lb.origins,
+		path:             utils.JoinPath("/", conf.Path),
+		successThreshold: conf.SuccessThreshold,
+	}
+
+	if bh.failureThreshold == 0 {
+		bh.failureThreshold = defaultHealthFailureThreshold
+	}
+	if bh.successThreshold == 0 {
+		bh.successThreshold = defaultHealthSuccessThreshold
+	}
+	if bh.failureThreshold < 0 || bh.successThreshold < 0 {
+		return nil, fmt.Errorf("health: thresholds must be positive")
+	}
+
+	if conf.Interval != "" {
+		d, err := time.ParseDuration(conf.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("health: interval: %v", err)
+		}
+		bh.interval = d
+	}
+
+	timeout := defaultHealthTimeout
+	if conf.Timeout != "" {
+		d, err := time.ParseDuration(conf.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("health: timeout: %v", err)
+		}
+		timeout = d
+	}
+
+	if bh.interval <= 0 || timeout <= 0 {
+		return nil, fmt.Errorf("health: interval and timeout must be positive")
+	}
+
+	var transportTLS *tls.Config
+	if tlsConf != nil {
+		transportTLS = tlsConf.Clone()
+	}
+
+	bh.client = &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:     (&net.Dialer{Timeout: timeout}).DialContext,
+			TLSClientConfig: transportTLS,
+		},
+	}
+
+	for _, o := range lb.origins {
+		o.health = newOriginHealth()
+	}
+
+	return bh, nil
+}
+
+// Name returns the name of the checked backend.
+func (bh *BackendHealth) Name() string {
+	return bh.backendName
+}
+
+// Start runs the periodic checks of all origins until the given context is done.
+// Calling Start more than once has no effect.
+func (bh *BackendHealth) Start(ctx context.Context, log *logrus.Entry) {
+	bh.startOnce.Do(func() {
+		for _, o := range bh.origins {
+			go bh.run(ctx, log, o)
+		}
+	})
+}
+
+// States returns the current health states of all origins by their url.
+func (bh *BackendHealth) States() map[string]OriginHealthState {
+	states := make(map[string]OriginHealthState)
+	for _, o := range bh.origins {
+		states[o.URL.String()] = o.health.State()
+	}
+	return states
+}
+
+func (bh *BackendHealth) run(ctx context.Context, log *logrus.Entry, origin *Origin) {
+	ticker := time.NewTicker(bh.interval)
+	defer ticker.Stop()
+
+	for {
+		bh.update(log, origin, bh.check(ctx, origin))
+
+		select {
+		case <-ctx.Done():
+			bh.client.CloseIdleConnections()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check probes the given origin once.
+func (bh *BackendHealth) check(ctx context.Context, origin *Origin) error {
+	u := *origin.URL
+	u.Path = bh.path
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "couper health-check")
+
+	res, err := bh.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if !bh.matchStatus(res.StatusCode) {
+		return fmt.Errorf("unexpected status code: %d", res.StatusCode)
+	}
+
+	if len(bh.expectedText) > 0 {
+		body, err := ioutil.ReadAll(io.LimitReader(res.Body, maxHealthBodySize))
+		if err != nil {
+			return err
+		}
+		if !bytes.Contains(body, bh.expectedText) {
+			return fmt.Errorf("expected text not found in response body")
+		}
+	}
+
+	return nil
+}
+
+func (bh *BackendHealth) matchStatus(status int) bool {
+	if len(bh.expectedStatus) == 0 {
+		return status >= 200 && status < 300
+	}
+	for _, s := range bh.expectedStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// update applies the check result and logs state transitions.
+func (bh *BackendHealth) update(log *logrus.Entry, origin *Origin, checkErr error) {
+	oh := origin.health
+	oh.mu.Lock()
+	defer oh.mu.Unlock()
+
+	oh.state.LastCheck = time.Now()
+
+	if checkErr != nil {
+		oh.state.Error = checkErr.Error()
+		oh.state.Failures++
+		oh.state.Successes = 0
+		if oh.state.Healthy && oh.state.Failures >= bh.failureThreshold {
+			oh.state.Healthy = false
+			log.WithFields(logrus.Fields{
+				"backend": bh.backendName,
+				"origin":  origin.URL.String(),
+			}).Warnf("origin is unhealthy: %v", checkErr)
+		}
+		return
+	}
+
+	oh.state.Error = ""
+	oh.state.Failures = 0
+	oh.state.Successes++
+	if !oh.state.Healthy && oh.state.Successes >= bh.successThreshold {
+		oh.state.Healthy = true
+		log.WithFields(logrus.Fields{
+			"backend": bh.backendName,
+			"origin":  origin.URL.String(),
+		}).Info("origin is healthy again")
+	}
+}
@@ -0,0 +1,124 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/eval"
+	"github.com/avenga/couper/handler"
+)
+
+func TestNewBackendHealth(t *testing.T) {
+	lb, err := handler.NewLoadBalancer(newLoadBalancerConf("", "", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		conf    *config.Health
+		wantErr bool
+	}{
+		{"defaults", &config.Health{}, false},
+		{"configured", &config.Health{Path: "/health", Interval: "1s", Timeout: "500ms", FailureThreshold: 3, SuccessThreshold: 1}, false},
+		{"invalid interval", &config.Health{Interval: "1 minute"}, true},
+		{"invalid timeout", &config.Health{Timeout: "-1s"}, true},
+		{"invalid threshold", &config.Health{FailureThreshold: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			if _, err := handler.NewBackendHealth("test", tt.conf, lb, nil); (err != nil) != tt.wantErr {
+				subT.Errorf("NewBackendHealth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBackendHealth_Start(t *testing.T) {
+	var failing int32
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/health" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if atomic.LoadInt32(&failing) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write([]byte("status: OK"))
+	}))
+	defer origin.Close()
+
+	lb, err := handler.NewLoadBalancer(&config.LoadBalancer{
+		Origins: []*config.LoadBalancerOrigin{{URL: origin.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	health, err := handler.NewBackendHealth("test", &config.Health{
+		ExpectedText:     "OK",
+		Interval:         "10ms",
+		Path:             "/health",
+		FailureThreshold: 2,
+		SuccessThreshold: 2,
+	}, lb, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger, hook := logrustest.NewNullLogger()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	health.Start(ctx, logger.WithContext(ctx))
+
+	waitFor := func(healthy bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second * 2)
+		for time.Now().Before(deadline) {
+			if health.States()[origin.URL].Healthy == healthy {
+				return
+			}
+			time.Sleep(time.Millisecond * 5)
+		}
+		t.Fatalf("Expected origin health state: %t", healthy)
+	}
+
+	evalCtx := eval.NewENVContext(nil)
+
+	waitFor(true)
+	if lb.Next(evalCtx) == nil {
+		t.Error("Expected a healthy origin")
+	}
+
+	atomic.StoreInt32(&failing, 1)
+	waitFor(false)
+
+	if lb.Next(evalCtx) != nil {
+		t.Error("Expected no origin selection for unhealthy origins")
+	}
+
+	if state := health.States()[origin.URL]; state.Error == "" || state.Failures < 2 {
+		t.Errorf("Expected failure details, got: %#v", state)
+	}
+
+	if entry := hook.LastEntry(); entry == nil || entry.Data["origin"] != origin.URL {
+		t.Error("Expected a log entry for the unhealthy origin")
+	}
+
+	atomic.StoreInt32(&failing, 0)
+	waitFor(true)
+
+	if lb.Next(evalCtx) == nil {
+		t.Error("Expected a healthy origin again")
+	}
+}
@@ -0,0 +1,574 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/eval"
+	"github.com/avenga/couper/logging"
+)
+
+const (
+	defaultCacheMaxBodySize = 1 << 20 // 1 MiB
+	defaultCacheMaxEntries  = 1000
+)
+
+// Cache status values of the logging.HeaderCacheStatus response header.
+const (
+	cacheHit         = "HIT"
+	cacheMiss        = "MISS"
+	cacheRevalidated = "REVALIDATED"
+	cacheStale       = "STALE"
+)
+
+// cacheableStatus lists the status codes which are cacheable by default, see https://tools.ietf.org/html/rfc7231#section-6.1.
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// ResponseCache stores the cacheable backend responses of GET and HEAD requests. Fresh responses are
+// served without a backend request, stale ones are revalidated with their ETag or Last-Modified validator.
+type ResponseCache struct {
+	defaultTTL  time.Duration
+	flights     cacheFlights
+	key         hcl.Expression
+	log         logrus.FieldLogger
+	maxBodySize int64
+	store       *cacheStore
+	timeout     time.Duration
+}
+
+// NewResponseCache validates the given cache configuration and creates a ResponseCache. The timeout
+// limits the background revalidation of stale responses.
+func NewResponseCache(conf *config.Cache, timeout time.Duration, log *logrus.Entry) (*ResponseCache, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	if conf.MaxEntries < 0 {
+		return nil, fmt.Errorf("cache: max_entries must be positive")
+	}
+
+	c := &ResponseCache{
+		key:         conf.Key,
+		maxBodySize: defaultCacheMaxBodySize,
+		timeout:     timeout,
+	}
+	if log != nil {
+		c.log = log
+	}
+
+	maxEntries := defaultCacheMaxEntries
+	if conf.MaxEntries > 0 {
+		maxEntries = conf.MaxEntries
+	}
+	c.store = newCacheStore(maxEntries)
+
+	if conf.DefaultTTL != "" {
+		d, err := time.ParseDuration(conf.DefaultTTL)
+		if err != nil {
+			return nil, fmt.Errorf("cache: default_ttl: %v", err)
+		}
+		c.defaultTTL = d
+	}
+
+	if conf.MaxBodySize != "" {
+		size, err := units.FromHumanSize(conf.MaxBodySize)
+		if err != nil {
+			return nil, fmt.Errorf("cache: max_body_size: %v", err)
+		}
+		c.maxBodySize = size
+	}
+
+	return c, nil
+}
+
+// RoundTrip answers the backend request with a cached response or forwards it with the given send function.
+func (c *ResponseCache) RoundTrip(evalCtx *hcl.EvalContext, req, outreq *http.Request, send roundTripFunc) (*http.Response, error) {
+	if outreq.Method != http.MethodGet && outreq.Method != http.MethodHead {
+		return send(outreq)
+	}
+
+	key := outreq.Method + " " + c.cacheKey(evalCtx, req, outreq)
+
+	if entry := c.store.get(key, outreq.Header); entry != nil {
+		age := entry.age(time.Now())
+		if age < entry.ttl {
+			return entry.response(outreq, cacheHit, age), nil
+		}
+		if age < entry.ttl+entry.staleWhileRevalidate {
+			c.revalidate(key, entry, outreq, send)
+			return entry.response(outreq, cacheStale, age), nil
+		}
+		return c.fetch(key, entry, outreq, send)
+	}
+
+	return c.fetch(key, nil, outreq, send)
+}
+
+// cacheKey evaluates the key expression, the backend request url is used for empty or invalid values.
+func (c *ResponseCache) cacheKey(evalCtx *hcl.EvalContext, req, outreq *http.Request) string {
+	if c.key != nil && evalCtx != nil {
+		val, diags := c.key.Value(eval.NewHTTPContext(evalCtx, eval.BufferNone, req, nil, nil))
+		if diags.HasErrors() {
+			c.logError(diags.Error())
+		} else if val.IsKnown() && !val.IsNull() {
+			str, err := convert.Convert(val, cty.String)
+			if err != nil {
+				c.logError(fmt.Sprintf("key must evaluate to a string value, got: %s", val.Type().FriendlyName()))
+			} else if key := str.AsString(); key != "" {
+				return key
+			}
+		}
+	}
+	return outreq.URL.String()
+}
+
+func (c *ResponseCache) logError(msg string) {
+	if c.log != nil {
+		c.log.WithField("cache", c.key.Range().String()).Error(msg)
+	}
+}
+
+// fetch forwards the request, concurrent requests of the same key share the backend response if it is stored.
+// A stale entry gets revalidated with its validators.
+func (c *ResponseCache) fetch(key string, stale *cacheEntry, outreq *http.Request, send roundTripFunc) (*http.Response, error) {
+	flightKey := key
+	if stale != nil {
+		flightKey += "\n" + strings.Join(stale.varyValues, "\n")
+	}
+
+	result, shared := c.flights.do(outreq.Context(), flightKey, func() cacheFlightResult {
+		// the shared request must not end with the context of the first client request
+		ctx, cancel := c.detachedContext(outreq.Context())
+		result := c.send(key, stale, outreq.Clone(ctx), send)
+		if result.res != nil {
+			// the uncached response is read by the first caller
+			body := result.res.Body
+			result.res.Body = eval.NewReadCloser(body, closerFunc(func() error {
+				defer cancel()
+				return body.Close()
+			}))
+		} else {
+			cancel()
+		}
+		return result
+	})
+
+	if shared {
+		if result.err != nil {
+			return nil, result.err
+		}
+		if result.entry == nil || !result.entry.matches(outreq.Header) {
+			// the shared response is not stored or varies for this request
+			return c.send(key, stale, outreq, send).response(outreq)
+		}
+		return result.entry.response(outreq, cacheHit, 0), nil
+	}
+	return result.response(outreq)
+}
+
+// send forwards the request without the conditional headers of the client and stores the response if possible.
+func (c *ResponseCache) send(key string, stale *cacheEntry, outreq *http.Request, send roundTripFunc) cacheFlightResult {
+	cacheReq := outreq.Clone(outreq.Context())
+	cacheReq.Header.Del("If-Modified-Since")
+	cacheReq.Header.Del("If-None-Match")
+	if stale != nil {
+		if etag := stale.header.Get("ETag"); etag != "" {
+			cacheReq.Header.Set("If-None-Match", etag)
+		} else if lastModified := stale.header.Get("Last-Modified"); lastModified != "" {
+			cacheReq.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	requestTime := time.Now()
+	res, err := send(cacheReq)
+	if err != nil {
+		return cacheFlightResult{err: err}
+	}
+
+	if res.StatusCode == http.StatusNotModified && stale != nil && cacheReq.Header.Get("If-None-Match")+
+		cacheReq.Header.Get("If-Modified-Since") != "" {
+		res.Body.Close()
+		entry := stale.revalidated(res.Header, requestTime, c.defaultTTL)
+		c.store.put(key, entry)
+		return cacheFlightResult{entry: entry, status: cacheRevalidated}
+	}
+
+	entry, res := c.newEntry(cacheReq, res, requestTime)
+	if entry == nil {
+		res.Header.Set(logging.HeaderCacheStatus, cacheMiss)
+		return cacheFlightResult{res: res, status: cacheMiss}
+	}
+	c.store.put(key, entry)
+	return cacheFlightResult{entry: entry, status: cacheMiss}
+}
+
+// detachedContext returns a context with the values of the given one which is limited by the timeout only.
+func (c *ResponseCache) detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout > 0 {
+		return context.WithTimeout(valuesContext{ctx}, c.timeout)
+	}
+	return context.WithCancel(valuesContext{ctx})
+}
+
+// valuesContext keeps the values of its parent without its deadline and cancellation.
+type valuesContext struct {
+	parent context.Context
+}
+
+func (valuesContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (valuesContext) Done() <-chan struct{}       { return nil }
+func (valuesContext) Err() error                  { return nil }
+
+func (v valuesContext) Value(key interface{}) interface{} {
+	return v.parent.Value(key)
+}
+
+type closerFunc func() error
+
+func (fn closerFunc) Close() error {
+	return fn()
+}
+
+// revalidate refreshes the given stale entry in the background.
+func (c *ResponseCache) revalidate(key string, entry *cacheEntry, outreq *http.Request, send roundTripFunc) {
+	if !atomic.CompareAndSwapInt32(&entry.revalidating, 0, 1) {
+		return
+	}
+
+	// the client request context ends with the stale response
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), c.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	bgReq := outreq.Clone(ctx)
+	go func() {
+		defer cancel()
+		res, err := c.fetch(key, entry, bgReq, send)
+		if err != nil {
+			atomic.StoreInt32(&entry.revalidating, 0)
+			return
+		}
+		res.Body.Close()
+	}()
+}
+
+// newEntry creates a cache entry of the given backend response. Returns a nil entry and a response
+// with the unread body if the response is not cacheable.
+func (c *ResponseCache) newEntry(req *http.Request, res *http.Response, requestTime time.Time) (*cacheEntry, *http.Response) {
+	ttl, staleWhileRevalidate, ok := freshness(req.Header, res, requestTime, c.defaultTTL)
+	if !ok {
+		return nil, res
+	}
+
+	vary, ok := varyHeaders(res.Header)
+	if !ok {
+		return nil, res
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(res.Body, c.maxBodySize+1))
+	if err != nil || int64(len(buf)) > c.maxBodySize {
+		res.Body = eval.NewReadCloser(io.MultiReader(bytes.NewReader(buf), res.Body), res.Body)
+		return nil, res
+	}
+	res.Body.Close()
+
+	entry := &cacheEntry{
+		body:                 buf,
+		header:               res.Header.Clone(),
+		staleWhileRevalidate: staleWhileRevalidate,
+		status:               res.StatusCode,
+		storedAt:             requestTime.Add(-initialAge(res.Header, requestTime)),
+		ttl:                  ttl,
+		vary:                 vary,
+	}
+	entry.varyValues = entry.values(req.Header)
+	for _, h := range hopHeaders {
+		entry.header.Del(h)
+	}
+	return entry, nil
+}
+
+// freshness returns the freshness lifetime and the stale-while-revalidate duration of the given response.
+// Responses without a lifetime are stored for revalidation if they provide a validator.
+func freshness(reqHeader http.Header, res *http.Response, now time.Time, defaultTTL time.Duration) (time.Duration, time.Duration, bool) {
+	if !cacheableStatus[res.StatusCode] || res.Header.Get("Set-Cookie") != "" {
+		return 0, 0, false
+	}
+
+	directives := parseCacheControl(res.Header)
+	if _, noStore := directives["no-store"]; noStore {
+		return 0, 0, false
+	}
+	if _, private := directives["private"]; private {
+		return 0, 0, false
+	}
+
+	// a shared cache must not reuse responses of authorized requests without explicit permission
+	if reqHeader.Get("Authorization") != "" {
+		_, public := directives["public"]
+		_, sMaxAge := directives["s-maxage"]
+		_, mustRevalidate := directives["must-revalidate"]
+		if !public && !sMaxAge && !mustRevalidate {
+			return 0, 0, false
+		}
+	}
+
+	ttl := defaultTTL
+	if seconds, ok := directives["s-maxage"]; ok {
+		ttl = parseSeconds(seconds)
+	} else if seconds, ok := directives["max-age"]; ok {
+		ttl = parseSeconds(seconds)
+	} else if expires := res.Header.Get("Expires"); expires != "" {
+		ttl = 0
+		if t, err := http.ParseTime(expires); err == nil {
+			date, err := http.ParseTime(res.Header.Get("Date"))
+			if err != nil {
+				date = now
+			}
+			if t.After(date) {
+				ttl = t.Sub(date)
+			}
+		}
+	}
+
+	if _, noCache := directives["no-cache"]; noCache {
+		ttl = 0
+	}
+
+	var staleWhileRevalidate time.Duration
+	if seconds, ok := directives["stale-while-revalidate"]; ok {
+		staleWhileRevalidate = parseSeconds(seconds)
+	}
+	_, mustRevalidate := directives["must-revalidate"]
+	_, proxyRevalidate := directives["proxy-revalidate"]
+	if mustRevalidate || proxyRevalidate {
+		staleWhileRevalidate = 0
+	}
+
+	hasValidator := res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != ""
+	return ttl, staleWhileRevalidate, ttl > 0 || hasValidator
+}
+
+type cacheEntry struct {
+	body                 []byte
+	header               http.Header
+	revalidating         int32
+	staleWhileRevalidate time.Duration
+	status               int
+	storedAt             time.Time
+	ttl                  time.Duration
+	vary                 []string
+	varyValues           []string
+}
+
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	if age := now.Sub(e.storedAt); age > 0 {
+		return age
+	}
+	return 0
+}
+
+func (e *cacheEntry) values(header http.Header) []string {
+	values := make([]string, len(e.vary))
+	for i, name := range e.vary {
+		values[i] = strings.Join(header.Values(name), ",")
+	}
+	return values
+}
+
+// matches reports whether the given request headers have the Vary header values of the entry.
+func (e *cacheEntry) matches(header http.Header) bool {
+	for i, value := range e.values(header) {
+		if value != e.varyValues[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// revalidated returns a copy of the entry with the headers of the given 304 response.
+func (e *cacheEntry) revalidated(header http.Header, requestTime time.Time, defaultTTL time.Duration) *cacheEntry {
+	entry := &cacheEntry{
+		body:                 e.body,
+		header:               e.header.Clone(),
+		staleWhileRevalidate: e.staleWhileRevalidate,
+		status:               e.status,
+		storedAt:             requestTime.Add(-initialAge(header, requestTime)),
+		ttl:                  e.ttl,
+		vary:                 e.vary,
+		varyValues:           e.varyValues,
+	}
+
+	for name, values := range header {
+		if name == "Content-Length" {
+			continue
+		}
+		entry.header[name] = values
+	}
+	for _, h := range hopHeaders {
+		entry.header.Del(h)
+	}
+
+	// the freshness of the revalidated response replaces the previous one
+	res := &http.Response{StatusCode: e.status, Header: entry.header}
+	if ttl, staleWhileRevalidate, ok := freshness(http.Header{}, res, requestTime, defaultTTL); ok {
+		entry.ttl, entry.staleWhileRevalidate = ttl, staleWhileRevalidate
+	}
+	return entry
+}
+
+// response creates a response of the entry. Matching conditional requests are answered with 304.
+func (e *cacheEntry) response(req *http.Request, status string, age time.Duration) *http.Response {
+	header := e.header.Clone()
+	header.Set("Age", strconv.Itoa(int(age.Seconds())))
+	header.Set(logging.HeaderCacheStatus, status)
+
+	res := &http.Response{
+		Header:     header,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Request:    req,
+		StatusCode: e.status,
+	}
+
+	if e.notModified(req.Header) {
+		res.StatusCode = http.StatusNotModified
+		header.Del("Content-Length")
+		res.Body = http.NoBody
+	} else {
+		res.Body = ioutil.NopCloser(bytes.NewReader(e.body))
+		res.ContentLength = int64(len(e.body))
+	}
+	res.Status = fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	return res
+}
+
+// notModified evaluates the conditional headers of the client request.
+func (e *cacheEntry) notModified(header http.Header) bool {
+	if e.status != http.StatusOK {
+		return false
+	}
+
+	if ifNoneMatch := header.Get("If-None-Match"); ifNoneMatch != "" {
+		etag := strings.TrimPrefix(e.header.Get("ETag"), "W/")
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince := header.Get("If-Modified-Since"); ifModifiedSince != "" {
+		since, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		lastModified, err := http.ParseTime(e.header.Get("Last-Modified"))
+		return err == nil && !lastModified.After(since)
+	}
+	return false
+}
+
+func (r cacheFlightResult) response(req *http.Request) (*http.Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.entry != nil {
+		return r.entry.response(req, r.status, r.entry.age(time.Now())), nil
+	}
+	return r.res, nil
+}
+
+// varyHeaders returns the canonical header names of the Vary header, a "*" value is not cacheable.
+func varyHeaders(header http.Header) ([]string, bool) {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil, false
+			}
+			if name != "" {
+				names = append(names, textproto.CanonicalMIMEHeaderKey(name))
+			}
+		}
+	}
+	return names, true
+}
+
+// initialAge returns the age of the response at the time of the request, see https://tools.ietf.org/html/rfc7234#section-4.2.3.
+func initialAge(header http.Header, requestTime time.Time) time.Duration {
+	var age time.Duration
+	if date, err := http.ParseTime(header.Get("Date")); err == nil && requestTime.After(date) {
+		age = requestTime.Sub(date)
+	}
+	if ageValue := parseSeconds(header.Get("Age")); ageValue > age {
+		age = ageValue
+	}
+	return age
+}
+
+func parseCacheControl(header http.Header) map[string]string {
+	directives := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			kv := strings.SplitN(strings.TrimSpace(directive), "=", 2)
+			name := strings.ToLower(kv[0])
+			if name == "" {
+				continue
+			}
+			if len(kv) == 2 {
+				directives[name] = strings.Trim(kv[1], `"`)
+			} else {
+				directives[name] = ""
+			}
+		}
+	}
+	return directives
+}
+
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
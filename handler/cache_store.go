@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxCacheVariants limits the stored responses per cache key with different Vary header values.
+const maxCacheVariants = 8
+
+type cacheItem struct {
+	key      string
+	variants []*cacheEntry
+}
+
+// cacheStore is a least recently used store of the cache entries.
+type cacheStore struct {
+	maxEntries int
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+}
+
+func newCacheStore(maxEntries int) *cacheStore {
+	return &cacheStore{
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		maxEntries: maxEntries,
+	}
+}
+
+// get returns the entry of the given key which matches the Vary headers of the request.
+func (s *cacheStore) get(key string, header http.Header) *cacheEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, exist := s.items[key]
+	if !exist {
+		return nil
+	}
+	s.lru.MoveToFront(elem)
+
+	for _, entry := range elem.Value.(*cacheItem).variants {
+		if entry.matches(header) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// put stores the given entry and evicts the least recently used keys if the store is full.
+func (s *cacheStore) put(key string, entry *cacheEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, exist := s.items[key]
+	if !exist {
+		s.items[key] = s.lru.PushFront(&cacheItem{key: key, variants: []*cacheEntry{entry}})
+		for s.lru.Len() > s.maxEntries {
+			oldest := s.lru.Back()
+			s.lru.Remove(oldest)
+			delete(s.items, oldest.Value.(*cacheItem).key)
+		}
+		return
+	}
+	s.lru.MoveToFront(elem)
+
+	item := elem.Value.(*cacheItem)
+	variants := []*cacheEntry{entry}
+	for _, variant := range item.variants {
+		// a changed Vary header of the backend invalidates the other variants
+		if strings.Join(variant.vary, ",") != strings.Join(entry.vary, ",") ||
+			strings.Join(variant.varyValues, "\n") == strings.Join(entry.varyValues, "\n") {
+			continue
+		}
+		if len(variants) < maxCacheVariants {
+			variants = append(variants, variant)
+		}
+	}
+	item.variants = variants
+}
+
+type cacheFlight struct {
+	done   chan struct{}
+	result cacheFlightResult
+}
+
+type cacheFlightResult struct {
+	entry  *cacheEntry
+	err    error
+	res    *http.Response
+	status string
+}
+
+// cacheFlights collapses concurrent upstream requests of the same key into one.
+type cacheFlights struct {
+	mu    sync.Mutex
+	calls map[string]*cacheFlight
+}
+
+// do calls fn once for concurrent calls of the same key, the waiting calls get the shared result.
+// The call runs in the background, every caller waits for the result as long as its context is not done.
+// A response of the first caller which has been gone meanwhile gets closed.
+func (f *cacheFlights) do(ctx context.Context, key string, fn func() cacheFlightResult) (cacheFlightResult, bool) {
+	f.mu.Lock()
+	if f.calls == nil {
+		f.calls = make(map[string]*cacheFlight)
+	}
+	call, shared := f.calls[key]
+	if !shared {
+		call = &cacheFlight{done: make(chan struct{})}
+		f.calls[key] = call
+		go func() {
+			call.result = fn()
+			f.mu.Lock()
+			delete(f.calls, key)
+			f.mu.Unlock()
+			close(call.done)
+		}()
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result, shared
+	case <-ctx.Done():
+		if !shared {
+			go func() {
+				<-call.done
+				if call.result.res != nil {
+					call.result.res.Body.Close()
+				}
+			}()
+		}
+		return cacheFlightResult{err: ctx.Err()}, shared
+	}
+}
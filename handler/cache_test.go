@@ -0,0 +1,470 @@
+package handler
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/eval"
+	"github.com/avenga/couper/logging"
+)
+
+func TestNewResponseCache(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    *config.Cache
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"defaults", &config.Cache{}, false},
+		{"configured", &config.Cache{DefaultTTL: "10s", MaxBodySize: "2MiB", MaxEntries: 10}, false},
+		{"invalid default ttl", &config.Cache{DefaultTTL: "10 seconds"}, true},
+		{"invalid max body size", &config.Cache{MaxBodySize: "big"}, true},
+		{"negative max entries", &config.Cache{MaxEntries: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			_, err := NewResponseCache(tt.conf, 0, nil)
+			if (err != nil) != tt.wantErr {
+				subT.Errorf("NewResponseCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		status  int
+		header  http.Header
+		auth    bool
+		wantTTL time.Duration
+		wantSWR time.Duration
+		wantOk  bool
+	}{
+		{"max-age", 200, http.Header{"Cache-Control": {"max-age=60"}}, false, time.Minute, 0, true},
+		{"s-maxage", 200, http.Header{"Cache-Control": {"max-age=60, s-maxage=120"}}, false, 2 * time.Minute, 0, true},
+		{"expires", 200, http.Header{
+			"Date":    {now.UTC().Format(http.TimeFormat)},
+			"Expires": {now.Add(time.Hour).UTC().Format(http.TimeFormat)},
+		}, false, time.Hour, 0, true},
+		{"expired", 200, http.Header{"Expires": {"0"}}, false, 0, 0, false},
+		{"no-store", 200, http.Header{"Cache-Control": {"no-store, max-age=60"}}, false, 0, 0, false},
+		{"private", 200, http.Header{"Cache-Control": {"private, max-age=60"}}, false, 0, 0, false},
+		{"set-cookie", 200, http.Header{"Cache-Control": {"max-age=60"}, "Set-Cookie": {"a=b"}}, false, 0, 0, false},
+		{"uncacheable status", 500, http.Header{"Cache-Control": {"max-age=60"}}, false, 0, 0, false},
+		{"not found", 404, http.Header{"Cache-Control": {"max-age=60"}}, false, time.Minute, 0, true},
+		{"no-cache with etag", 200, http.Header{"Cache-Control": {"no-cache, max-age=60"}, "Etag": {`"1"`}}, false, 0, 0, true},
+		{"no-cache without validator", 200, http.Header{"Cache-Control": {"no-cache"}}, false, 0, 0, false},
+		{"stale-while-revalidate", 200, http.Header{"Cache-Control": {"max-age=1, stale-while-revalidate=30"}}, false, time.Second, 30 * time.Second, true},
+		{"must-revalidate", 200, http.Header{"Cache-Control": {"max-age=1, stale-while-revalidate=30, must-revalidate"}}, false, time.Second, 0, true},
+		{"authorization", 200, http.Header{"Cache-Control": {"max-age=60"}}, true, 0, 0, false},
+		{"authorization public", 200, http.Header{"Cache-Control": {"public, max-age=60"}}, true, time.Minute, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			reqHeader := http.Header{}
+			if tt.auth {
+				reqHeader.Set("Authorization", "Basic dXNlcjpwYXNz")
+			}
+			res := &http.Response{StatusCode: tt.status, Header: tt.header}
+			ttl, swr, ok := freshness(reqHeader, res, now, 0)
+			if ok != tt.wantOk {
+				subT.Fatalf("Expected ok: %v, got: %v", tt.wantOk, ok)
+			}
+			if !ok {
+				return
+			}
+			if ttl != tt.wantTTL {
+				subT.Errorf("Expected ttl: %s, got: %s", tt.wantTTL, ttl)
+			}
+			if swr != tt.wantSWR {
+				subT.Errorf("Expected stale-while-revalidate: %s, got: %s", tt.wantSWR, swr)
+			}
+		})
+	}
+}
+
+// testCacheOrigin answers with the given cache headers and counts the requests.
+type testCacheOrigin struct {
+	calls  int32
+	header http.Header
+	// notModified answers conditional requests with 304
+	notModified bool
+	block       chan struct{}
+}
+
+func (o *testCacheOrigin) send(req *http.Request) (*http.Response, error) {
+	n := atomic.AddInt32(&o.calls, 1)
+	if o.block != nil {
+		<-o.block
+	}
+
+	rec := httptest.NewRecorder()
+	for k, v := range o.header {
+		rec.Header()[k] = v
+	}
+	if o.notModified && req.Header.Get("If-None-Match") == o.header.Get("ETag") {
+		rec.WriteHeader(http.StatusNotModified)
+		return rec.Result(), nil
+	}
+	rec.Header().Set("Content-Type", "text/plain")
+	rec.WriteHeader(http.StatusOK)
+	_, _ = rec.WriteString(strings.Repeat("a", int(n)) + req.Header.Get("Accept-Language"))
+	return rec.Result(), nil
+}
+
+func (o *testCacheOrigin) count() int {
+	return int(atomic.LoadInt32(&o.calls))
+}
+
+func cacheRoundTrip(t *testing.T, c *ResponseCache, origin *testCacheOrigin, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	res, err := c.RoundTrip(nil, req, req, origin.send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res, string(b)
+}
+
+func newTestResponseCache(t *testing.T, conf *config.Cache) *ResponseCache {
+	t.Helper()
+	c, err := NewResponseCache(conf, time.Second, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestResponseCache_RoundTrip(t *testing.T) {
+	c := newTestResponseCache(t, &config.Cache{})
+	origin := &testCacheOrigin{header: http.Header{"Cache-Control": {"max-age=60"}, "Etag": {`"v1"`}}}
+
+	for i, exp := range []struct {
+		method string
+		status string
+		body   string
+	}{
+		{http.MethodGet, cacheMiss, "a"},
+		{http.MethodGet, cacheHit, "a"},
+		{http.MethodPost, "", "aa"},
+		{http.MethodGet, cacheHit, "a"},
+		{http.MethodHead, cacheMiss, "aaa"},
+	} {
+		req := httptest.NewRequest(exp.method, "http://backend.couper.io/catalogue", nil)
+		res, body := cacheRoundTrip(t, c, origin, req)
+		if status := res.Header.Get(logging.HeaderCacheStatus); status != exp.status {
+			t.Errorf("#%d: expected cache status %q, got: %q", i, exp.status, status)
+		}
+		if body != exp.body {
+			t.Errorf("#%d: expected body %q, got: %q", i, exp.body, body)
+		}
+	}
+
+	if origin.count() != 3 {
+		t.Errorf("Expected 3 origin requests, got: %d", origin.count())
+	}
+
+	// the cached response answers conditional client requests
+	req := httptest.NewRequest(http.MethodGet, "http://backend.couper.io/catalogue", nil)
+	req.Header.Set("If-None-Match", `"v1"`)
+	res, _ := cacheRoundTrip(t, c, origin, req)
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("Expected status %d, got: %d", http.StatusNotModified, res.StatusCode)
+	}
+	if origin.count() != 3 {
+		t.Errorf("Expected no further origin request, got: %d", origin.count())
+	}
+
+	// uncacheable responses
+	origin = &testCacheOrigin{header: http.Header{"Cache-Control": {"no-store"}}}
+	for i := 0; i < 2; i++ {
+		res, _ = cacheRoundTrip(t, c, origin, httptest.NewRequest(http.MethodGet, "http://backend.couper.io/user", nil))
+		if status := res.Header.Get(logging.HeaderCacheStatus); status != cacheMiss {
+			t.Errorf("Expected cache status %q, got: %q", cacheMiss, status)
+		}
+	}
+	if origin.count() != 2 {
+		t.Errorf("Expected 2 origin requests, got: %d", origin.count())
+	}
+}
+
+func TestResponseCache_Revalidate(t *testing.T) {
+	c := newTestResponseCache(t, &config.Cache{})
+	origin := &testCacheOrigin{
+		header:      http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}},
+		notModified: true,
+	}
+
+	for i, exp := range []string{cacheMiss, cacheRevalidated, cacheRevalidated} {
+		res, body := cacheRoundTrip(t, c, origin, httptest.NewRequest(http.MethodGet, "http://backend.couper.io/", nil))
+		if status := res.Header.Get(logging.HeaderCacheStatus); status != exp {
+			t.Errorf("#%d: expected cache status %q, got: %q", i, exp, status)
+		}
+		if res.StatusCode != http.StatusOK || body != "a" {
+			t.Errorf("#%d: expected the cached response, got: %d %q", i, res.StatusCode, body)
+		}
+	}
+
+	if origin.count() != 3 {
+		t.Errorf("Expected 3 origin requests, got: %d", origin.count())
+	}
+}
+
+func TestResponseCache_StaleWhileRevalidate(t *testing.T) {
+	c := newTestResponseCache(t, &config.Cache{})
+	origin := &testCacheOrigin{header: http.Header{"Cache-Control": {"max-age=1, stale-while-revalidate=60"}}}
+
+	req := httptest.NewRequest(http.MethodGet, "http://backend.couper.io/", nil)
+	cacheRoundTrip(t, c, origin, req)
+
+	entry := c.store.get(http.MethodGet+" "+req.URL.String(), req.Header)
+	if entry == nil {
+		t.Fatal("Expected a cache entry")
+	}
+	entry.storedAt = entry.storedAt.Add(-2 * time.Second)
+
+	res, body := cacheRoundTrip(t, c, origin, req)
+	if status := res.Header.Get(logging.HeaderCacheStatus); status != cacheStale {
+		t.Errorf("Expected cache status %q, got: %q", cacheStale, status)
+	}
+	if body != "a" {
+		t.Errorf("Expected the stale body, got: %q", body)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		res, body = cacheRoundTrip(t, c, origin, req)
+		if body == "aa" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the background revalidation")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if status := res.Header.Get(logging.HeaderCacheStatus); status != cacheHit {
+		t.Errorf("Expected cache status %q, got: %q", cacheHit, status)
+	}
+	if origin.count() != 2 {
+		t.Errorf("Expected 2 origin requests, got: %d", origin.count())
+	}
+}
+
+func TestResponseCache_CollapseMisses(t *testing.T) {
+	c := newTestResponseCache(t, &config.Cache{})
+	origin := &testCacheOrigin{
+		header: http.Header{"Cache-Control": {"max-age=60"}},
+		block:  make(chan struct{}),
+	}
+
+	wg := sync.WaitGroup{}
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "http://backend.couper.io/", nil)
+			_, bodies[i] = cacheRoundTrip(t, c, origin, req)
+		}(i)
+	}
+
+	time.Sleep(time.Millisecond * 50)
+	close(origin.block)
+	wg.Wait()
+
+	if origin.count() != 1 {
+		t.Errorf("Expected 1 origin request, got: %d", origin.count())
+	}
+	for i, body := range bodies {
+		if body != "a" {
+			t.Errorf("#%d: expected the shared body, got: %q", i, body)
+		}
+	}
+}
+
+func TestResponseCache_CollapseMisses_Canceled(t *testing.T) {
+	c := newTestResponseCache(t, &config.Cache{})
+	origin := &testCacheOrigin{
+		header: http.Header{"Cache-Control": {"max-age=60"}},
+		block:  make(chan struct{}),
+	}
+
+	var upstreamErr error
+	send := func(req *http.Request) (*http.Response, error) {
+		res, err := origin.send(req)
+		upstreamErr = req.Context().Err()
+		return res, err
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	waiterCtx, cancelWaiter := context.WithCancel(context.Background())
+	defer cancelWaiter()
+
+	roundTrip := func(ctx context.Context, errCh chan<- error, bodyCh chan<- string) {
+		req := httptest.NewRequest(http.MethodGet, "http://backend.couper.io/", nil).WithContext(ctx)
+		res, err := c.RoundTrip(nil, req, req, send)
+		errCh <- err
+		if err == nil {
+			b, _ := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			bodyCh <- string(b)
+		}
+	}
+
+	firstErr, waiterErr, canceledErr := make(chan error, 1), make(chan error, 1), make(chan error, 1)
+	bodies := make(chan string, 3)
+	go roundTrip(firstCtx, firstErr, bodies)
+	time.Sleep(time.Millisecond * 20)
+	go roundTrip(waiterCtx, waiterErr, bodies)
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	go roundTrip(canceledCtx, canceledErr, bodies)
+	time.Sleep(time.Millisecond * 20)
+
+	// the callers return with their own context while the shared request is still pending
+	cancelFirst()
+	cancel()
+	for _, errCh := range []chan error{firstErr, canceledErr} {
+		select {
+		case err := <-errCh:
+			if err != context.Canceled {
+				t.Errorf("Expected a canceled round trip, got: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected the canceled caller to return")
+		}
+	}
+
+	close(origin.block)
+	if err := <-waiterErr; err != nil {
+		t.Fatalf("Expected the shared response for the waiting caller, got: %v", err)
+	}
+	if body := <-bodies; body != "a" {
+		t.Errorf("Expected the shared body, got: %q", body)
+	}
+	if upstreamErr != nil {
+		t.Errorf("Expected the shared request to outlive the first caller, got: %v", upstreamErr)
+	}
+	if origin.count() != 1 {
+		t.Errorf("Expected 1 origin request, got: %d", origin.count())
+	}
+}
+
+func TestResponseCache_Vary(t *testing.T) {
+	c := newTestResponseCache(t, &config.Cache{})
+	origin := &testCacheOrigin{header: http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}}}
+
+	for i, exp := range []struct {
+		lang   string
+		status string
+		body   string
+	}{
+		{"de", cacheMiss, "ade"},
+		{"en", cacheMiss, "aaen"},
+		{"de", cacheHit, "ade"},
+		{"en", cacheHit, "aaen"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://backend.couper.io/", nil)
+		req.Header.Set("Accept-Language", exp.lang)
+		res, body := cacheRoundTrip(t, c, origin, req)
+		if status := res.Header.Get(logging.HeaderCacheStatus); status != exp.status {
+			t.Errorf("#%d: expected cache status %q, got: %q", i, exp.status, status)
+		}
+		if body != exp.body {
+			t.Errorf("#%d: expected body %q, got: %q", i, exp.body, body)
+		}
+	}
+}
+
+func TestResponseCache_Limits(t *testing.T) {
+	c := newTestResponseCache(t, &config.Cache{MaxBodySize: "3B", MaxEntries: 1})
+	origin := &testCacheOrigin{header: http.Header{"Cache-Control": {"max-age=60"}}}
+
+	for i, exp := range []struct {
+		path   string
+		status string
+		body   string
+	}{
+		{"/a", cacheMiss, "a"},
+		{"/a", cacheHit, "a"},
+		{"/b", cacheMiss, "aa"},
+		{"/b", cacheHit, "aa"},
+		// evicted by /b
+		{"/a", cacheMiss, "aaa"},
+		// exceeds the max body size
+		{"/c", cacheMiss, "aaaa"},
+		{"/a", cacheHit, "aaa"},
+	} {
+		res, body := cacheRoundTrip(t, c, origin, httptest.NewRequest(http.MethodGet, "http://backend.couper.io"+exp.path, nil))
+		if status := res.Header.Get(logging.HeaderCacheStatus); status != exp.status {
+			t.Errorf("#%d: expected cache status %q, got: %q", i, exp.status, status)
+		}
+		if body != exp.body {
+			t.Errorf("#%d: expected body %q, got: %q", i, exp.body, body)
+		}
+	}
+}
+
+func TestResponseCache_Key(t *testing.T) {
+	log, hook := logrustest.NewNullLogger()
+
+	for _, tt := range []struct {
+		key    string
+		expLog bool
+	}{
+		{`req.headers.x-tenant`, false},
+		{`req.headers`, true},
+	} {
+		t.Run(tt.key, func(subT *testing.T) {
+			hook.Reset()
+
+			expr, diags := hclsyntax.ParseExpression([]byte(tt.key), "couper.hcl", hcl.InitialPos)
+			if diags.HasErrors() {
+				subT.Fatal(diags)
+			}
+
+			c, err := NewResponseCache(&config.Cache{Key: expr}, time.Second, log.WithContext(context.Background()))
+			if err != nil {
+				subT.Fatal(err)
+			}
+
+			origin := &testCacheOrigin{header: http.Header{"Cache-Control": {"max-age=60"}}}
+			for _, tenant := range []string{"a", "b", "a"} {
+				req := httptest.NewRequest(http.MethodGet, "http://backend.couper.io/", nil)
+				req.Header.Set("X-Tenant", tenant)
+				if _, err = c.RoundTrip(eval.NewENVContext(nil), req, req, origin.send); err != nil {
+					subT.Fatal(err)
+				}
+			}
+
+			// the tenants are cached separately
+			if !tt.expLog && origin.count() != 2 {
+				subT.Errorf("Expected 2 origin requests, got: %d", origin.count())
+			}
+			if logged := len(hook.AllEntries()) > 0; logged != tt.expLog {
+				subT.Errorf("Expected a logged key error: %v, got: %v", tt.expLog, hook.AllEntries())
+			}
+		})
+	}
+}
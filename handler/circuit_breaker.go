@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/avenga/couper/config"
+	couperErr "github.com/avenga/couper/errors"
+)
+
+const (
+	defaultCircuitFailureThreshold = 5
+	defaultCircuitHalfOpenRequests = 1
+	defaultCircuitMinRequests      = 10
+	defaultCircuitOpenDuration     = time.Second * 30
+	defaultCircuitWindow           = time.Second * 10
+)
+
+type circuitState uint8
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitBreaker stops forwarding requests to a failing backend for the configured open duration.
+// Afterwards a limited amount of half-open probe requests decides whether the circuit closes again.
+type CircuitBreaker struct {
+	backendName      string
+	failureRate      float64
+	failureThreshold int
+	halfOpenRequests int
+	log              *logrus.Entry
+	minRequests      int
+	openDuration     time.Duration
+	window           time.Duration
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	halfOpenActive      int
+	halfOpenSuccesses   int
+	openedAt            time.Time
+	state               circuitState
+	windowFailures      int
+	windowRequests      int
+	windowStart         time.Time
+}
+
+// NewCircuitBreaker validates the given circuit_breaker configuration and creates a CircuitBreaker.
+// The state transitions are logged with the given logger.
+func NewCircuitBreaker(backendName string, conf *config.CircuitBreaker, log *logrus.Entry) (*CircuitBreaker, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	cb := &CircuitBreaker{
+		backendName:      backendName,
+		failureRate:      conf.FailureRate,
+		failureThreshold: conf.FailureThreshold,
+		halfOpenRequests: conf.HalfOpenRequests,
+		log:              log,
+		minRequests:      conf.MinRequests,
+		openDuration:     defaultCircuitOpenDuration,
+		window:           defaultCircuitWindow,
+	}
+
+	if cb.failureRate < 0 || cb.failureRate > 1 {
+		return nil, fmt.Errorf("circuit_breaker: failure_rate must be between 0 and 1")
+	}
+	if cb.failureThreshold < 0 || cb.halfOpenRequests < 0 || cb.minRequests < 0 {
+		return nil, fmt.Errorf("circuit_breaker: thresholds must be positive")
+	}
+
+	if cb.failureRate == 0 && cb.failureThreshold == 0 {
+		cb.failureThreshold = defaultCircuitFailureThreshold
+	}
+	if cb.halfOpenRequests == 0 {
+		cb.halfOpenRequests = defaultCircuitHalfOpenRequests
+	}
+	if cb.minRequests == 0 {
+		cb.minRequests = defaultCircuitMinRequests
+	}
+
+	if conf.OpenDuration != "" {
+		d, err := time.ParseDuration(conf.OpenDuration)
+		if err != nil {
+			return nil, fmt.Errorf("circuit_breaker: open_duration: %v", err)
+		}
+		cb.openDuration = d
+	}
+
+	if conf.Window != "" {
+		d, err := time.ParseDuration(conf.Window)
+		if err != nil {
+			return nil, fmt.Errorf("circuit_breaker: window: %v", err)
+		}
+		cb.window = d
+	}
+
+	if cb.openDuration <= 0 || cb.window <= 0 {
+		return nil, fmt.Errorf("circuit_breaker: open_duration and window must be positive")
+	}
+
+	return cb, nil
+}
+
+// Allow returns an APICircuitOpen error if the request must not be forwarded.
+// Each allowed request must be followed by a Report or Release call.
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitOpen:
+		if time.Since(cb.openedAt) < cb.openDuration {
+			return couperErr.APICircuitOpen
+		}
+		cb.setState(circuitHalfOpen)
+		fallthrough
+	case circuitHalfOpen:
+		if cb.halfOpenActive >= cb.halfOpenRequests {
+			return couperErr.APICircuitOpen
+		}
+		cb.halfOpenActive++
+	}
+	return nil
+}
+
+// Report records the result of an allowed request.
+func (cb *CircuitBreaker) Report(failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitHalfOpen:
+		if failed {
+			cb.setState(circuitOpen)
+			return
+		}
+		cb.halfOpenSuccesses++
+		if cb.halfOpenSuccesses >= cb.halfOpenRequests {
+			cb.setState(circuitClosed)
+		}
+	case circuitClosed:
+		now := time.Now()
+		if now.Sub(cb.windowStart) > cb.window {
+			cb.windowStart, cb.windowRequests, cb.windowFailures = now, 0, 0
+		}
+
+		cb.windowRequests++
+		if !failed {
+			cb.consecutiveFailures = 0
+			return
+		}
+		cb.windowFailures++
+		cb.consecutiveFailures++
+
+		if cb.failureThreshold > 0 && cb.consecutiveFailures >= cb.failureThreshold {
+			cb.setState(circuitOpen)
+			return
+		}
+
+		if cb.failureRate > 0 && cb.windowRequests >= cb.minRequests &&
+			float64(cb.windowFailures)/float64(cb.windowRequests) >= cb.failureRate {
+			cb.setState(circuitOpen)
+		}
+	}
+	// reports of requests which were allowed before the circuit opened are ignored
+}
+
+// Release frees the half-open slot of an allowed request without a result, e.g. a canceled client request.
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == circuitHalfOpen && cb.halfOpenActive > 0 {
+		cb.halfOpenActive--
+	}
+}
+
+// setState resets the counters of the new state and logs the transition.
+func (cb *CircuitBreaker) setState(state circuitState) {
+	from := cb.state
+	cb.state = state
+	cb.consecutiveFailures, cb.halfOpenActive, cb.halfOpenSuccesses = 0, 0, 0
+	cb.windowStart, cb.windowRequests, cb.windowFailures = time.Now(), 0, 0
+
+	if state == circuitOpen {
+		cb.openedAt = time.Now()
+	}
+
+	if cb.log == nil {
+		return
+	}
+
+	entry := cb.log.WithFields(logrus.Fields{
+		"backend": cb.backendName,
+		"circuit": state.String(),
+	})
+	msg := fmt.Sprintf("circuit breaker changed from %s to %s", from, state)
+	if state == circuitOpen {
+		entry.Warn(msg)
+	} else {
+		entry.Info(msg)
+	}
+}
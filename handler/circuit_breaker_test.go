@@ -0,0 +1,179 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/config/runtime/server"
+	"github.com/avenga/couper/errors"
+	"github.com/avenga/couper/eval"
+	"github.com/avenga/couper/handler"
+	"github.com/avenga/couper/internal/test"
+)
+
+func TestNewCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    *config.CircuitBreaker
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"defaults", &config.CircuitBreaker{}, false},
+		{"failure rate", &config.CircuitBreaker{FailureRate: 0.5, MinRequests: 20, Window: "1m"}, false},
+		{"invalid failure rate", &config.CircuitBreaker{FailureRate: 1.5}, true},
+		{"invalid threshold", &config.CircuitBreaker{FailureThreshold: -1}, true},
+		{"invalid open_duration", &config.CircuitBreaker{OpenDuration: "30"}, true},
+		{"invalid window", &config.CircuitBreaker{Window: "0s"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			if _, err := handler.NewCircuitBreaker("test", tt.conf, nil); (err != nil) != tt.wantErr {
+				subT.Errorf("NewCircuitBreaker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_Allow(t *testing.T) {
+	helper := test.New(t)
+
+	expectAllow := func(subT *testing.T, cb *handler.CircuitBreaker, allowed bool) {
+		subT.Helper()
+		err := cb.Allow()
+		if allowed && err != nil {
+			subT.Fatalf("Expected an allowed request, got: %v", err)
+		} else if !allowed && err != errors.APICircuitOpen {
+			subT.Fatalf("Expected error %v, got: %v", errors.APICircuitOpen, err)
+		}
+	}
+
+	t.Run("consecutive failures", func(subT *testing.T) {
+		cb, err := handler.NewCircuitBreaker("test", &config.CircuitBreaker{FailureThreshold: 3, OpenDuration: "50ms"}, nil)
+		helper.Must(err)
+
+		for _, failed := range []bool{true, true, false, true, true} {
+			expectAllow(subT, cb, true)
+			cb.Report(failed)
+		}
+
+		expectAllow(subT, cb, true)
+		cb.Report(true)
+		expectAllow(subT, cb, false)
+
+		time.Sleep(time.Millisecond * 60)
+
+		// one half-open probe
+		expectAllow(subT, cb, true)
+		expectAllow(subT, cb, false)
+		cb.Report(true)
+
+		// failed probe opens again
+		expectAllow(subT, cb, false)
+
+		time.Sleep(time.Millisecond * 60)
+
+		expectAllow(subT, cb, true)
+		cb.Report(false)
+
+		// closed
+		expectAllow(subT, cb, true)
+		expectAllow(subT, cb, true)
+	})
+
+	t.Run("failure rate", func(subT *testing.T) {
+		cb, err := handler.NewCircuitBreaker("test", &config.CircuitBreaker{FailureRate: 0.5, MinRequests: 4}, nil)
+		helper.Must(err)
+
+		for _, failed := range []bool{true, false, false} {
+			expectAllow(subT, cb, true)
+			cb.Report(failed)
+		}
+
+		// the rate is reached with the fourth request
+		expectAllow(subT, cb, true)
+		cb.Report(true)
+
+		expectAllow(subT, cb, false)
+	})
+
+	t.Run("released probe", func(subT *testing.T) {
+		cb, err := handler.NewCircuitBreaker("test", &config.CircuitBreaker{FailureThreshold: 1, OpenDuration: "50ms"}, nil)
+		helper.Must(err)
+
+		expectAllow(subT, cb, true)
+		cb.Report(true)
+		expectAllow(subT, cb, false)
+
+		time.Sleep(time.Millisecond * 60)
+
+		// a canceled probe frees its slot without closing the circuit
+		expectAllow(subT, cb, true)
+		cb.Release()
+		expectAllow(subT, cb, true)
+		expectAllow(subT, cb, false)
+		cb.Report(false)
+
+		// closed
+		expectAllow(subT, cb, true)
+		expectAllow(subT, cb, true)
+	})
+}
+
+func TestProxy_ServeHTTP_CircuitBreaker(t *testing.T) {
+	helper := test.New(t)
+
+	var requests int32
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer origin.Close()
+
+	logger, hook := logrustest.NewNullLogger()
+	log := logger.WithContext(context.Background())
+
+	opts, err := handler.NewProxyOptions(&config.Backend{
+		CircuitBreaker:   &config.CircuitBreaker{FailureThreshold: 2},
+		ConnectTimeout:   "1s",
+		Name:             "failing",
+		RequestBodyLimit: "64MiB",
+		TTFBTimeout:      "1s",
+		Timeout:          "1s",
+	}, nil, test.NewRemainContext("origin", origin.URL), log)
+	helper.Must(err)
+
+	srvOpts := &server.Options{APIErrTpl: errors.DefaultJSON}
+	proxy, err := handler.NewProxy(opts, log, srvOpts, eval.NewENVContext(nil))
+	helper.Must(err)
+
+	for i, wantStatus := range []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusServiceUnavailable} {
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://couper.io/", nil))
+
+		if rec.Code != wantStatus {
+			t.Errorf("%d: Expected status %d, got: %d", i+1, wantStatus, rec.Code)
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected two upstream requests, got: %d", n)
+	}
+
+	var logged bool
+	for _, entry := range hook.AllEntries() {
+		if entry.Data["circuit"] == "open" {
+			logged = true
+		}
+	}
+	if !logged {
+		t.Error("Expected a log entry for the opened circuit")
+	}
+}
@@ -0,0 +1,373 @@
+package handler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/docker/go-units"
+
+	ac "github.com/avenga/couper/accesscontrol"
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/config/runtime/server"
+)
+
+const (
+	defaultCompressionMinSize = 1024
+
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+var (
+	_ http.Handler        = &Compress{}
+	_ ac.ProtectedHandler = &Compress{}
+	_ server.Context      = &Compress{}
+)
+
+// defaultCompressionTypes lists the compressed media types if no types are configured.
+var defaultCompressionTypes = []string{
+	"application/javascript",
+	"application/json",
+	"application/problem+json",
+	"application/xml",
+	"image/svg+xml",
+	"text/*",
+}
+
+// supportedEncodings lists the content encodings in the order of preference.
+var supportedEncodings = []string{encodingBrotli, encodingGzip}
+
+var (
+	brotliWriterPool = sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}}
+	gzipWriterPool = sync.Pool{New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}}
+)
+
+// Compression holds the validated compression settings of a server.
+type Compression struct {
+	minSize int64
+	types   []string
+}
+
+// NewCompression validates the given compression configuration and creates a Compression.
+func NewCompression(conf *config.Compression) (*Compression, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	c := &Compression{
+		minSize: defaultCompressionMinSize,
+		types:   defaultCompressionTypes,
+	}
+
+	if conf.MinSize != "" {
+		size, err := units.FromHumanSize(conf.MinSize)
+		if err != nil {
+			return nil, fmt.Errorf("compression: min_size: %v", err)
+		}
+		c.minSize = size
+	}
+
+	if len(conf.Types) > 0 {
+		c.types = make([]string, len(conf.Types))
+		for i, t := range conf.Types {
+			mediaType, _, err := mime.ParseMediaType(t)
+			if err == nil && !strings.Contains(mediaType, "/") {
+				err = fmt.Errorf("missing subtype")
+			}
+			if err != nil {
+				return nil, fmt.Errorf("compression: types: %q: %v", t, err)
+			}
+			c.types[i] = mediaType
+		}
+	}
+
+	return c, nil
+}
+
+// compressible reports whether the given Content-Type header value matches the configured media types.
+func (c *Compression) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range c.types {
+		if t == "*/*" || t == mediaType ||
+			strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compress encodes the responses of the wrapped handler with the negotiated content encoding.
+type Compress struct {
+	compression *Compression
+	protected   http.Handler
+}
+
+func NewCompress(protected http.Handler, compression *Compression) *Compress {
+	return &Compress{
+		compression: compression,
+		protected:   protected,
+	}
+}
+
+func (c *Compress) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	cw := &compressWriter{
+		compression: c.compression,
+		encoding:    acceptedEncoding(req.Header.Get("Accept-Encoding"), supportedEncodings...),
+		head:        req.Method == http.MethodHead,
+		rw:          rw,
+	}
+	defer cw.close()
+
+	c.protected.ServeHTTP(cw, req)
+}
+
+func (c *Compress) Child() http.Handler {
+	return c.protected
+}
+
+// Options returns the server options of the wrapped handler if available.
+func (c *Compress) Options() *server.Options {
+	if h, ok := c.protected.(server.Context); ok {
+		return h.Options()
+	}
+	return nil
+}
+
+func (c *Compress) String() string {
+	if h, ok := c.protected.(fmt.Stringer); ok {
+		return h.String()
+	}
+	return "Compress"
+}
+
+var (
+	_ http.ResponseWriter = &compressWriter{}
+	_ http.Flusher        = &compressWriter{}
+)
+
+// compressWriter buffers the response body up to the minimum size before it decides whether
+// the response gets compressed.
+type compressWriter struct {
+	buf         []byte
+	compression *Compression
+	encoder     io.WriteCloser
+	encoding    string
+	head        bool
+	rw          http.ResponseWriter
+	status      int
+	// buffering is set while the decision is pending, written once the header is sent.
+	buffering bool
+	written   bool
+}
+
+func (w *compressWriter) Header() http.Header {
+	return w.rw.Header()
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.written || w.buffering {
+		return
+	}
+
+	if status >= 100 && status < 200 {
+		w.rw.WriteHeader(status)
+		return
+	}
+
+	w.status = status
+	if !w.prepare() {
+		w.writeHeader()
+		return
+	}
+	w.buffering = true
+}
+
+// prepare adds the Vary header to compressible responses and reports whether the response could be compressed.
+func (w *compressWriter) prepare() bool {
+	header := w.rw.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" ||
+		w.status == http.StatusNoContent || w.status == http.StatusPartialContent || w.status == http.StatusNotModified ||
+		strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-transform") ||
+		!w.compression.compressible(header.Get("Content-Type")) {
+		return false
+	}
+
+	addVary(header, "Accept-Encoding")
+
+	if w.encoding == "" || w.head {
+		return false
+	}
+
+	if length := header.Get("Content-Length"); length != "" {
+		if size, err := strconv.ParseInt(length, 10, 64); err == nil && size < w.compression.minSize {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.written && !w.buffering {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	if !w.buffering {
+		return w.rw.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if int64(len(w.buf)) >= w.compression.minSize {
+		if err := w.startEncoding(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// startEncoding writes the header of the compressed response and the buffered body to the encoder.
+func (w *compressWriter) startEncoding() error {
+	header := w.rw.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", w.encoding)
+	// the compressed representation is not byte-for-byte identical with the original one
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+
+	w.buffering = false
+	w.writeHeader()
+
+	switch w.encoding {
+	case encodingBrotli:
+		bw := brotliWriterPool.Get().(*brotli.Writer)
+		bw.Reset(w.rw)
+		w.encoder = bw
+	case encodingGzip:
+		gw := gzipWriterPool.Get().(*gzip.Writer)
+		gw.Reset(w.rw)
+		w.encoder = gw
+	}
+
+	buf := w.buf
+	w.buf = nil
+	_, err := w.encoder.Write(buf)
+	return err
+}
+
+func (w *compressWriter) writeHeader() {
+	w.written = true
+	w.rw.WriteHeader(w.status)
+}
+
+// Flush starts the compression of a buffered response and flushes the encoder.
+func (w *compressWriter) Flush() {
+	if w.buffering {
+		_ = w.startEncoding()
+	}
+
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if f, ok := w.rw.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close writes a buffered body below the minimum size uncompressed or completes the encoded one.
+func (w *compressWriter) close() {
+	if w.buffering {
+		w.buffering = false
+		w.writeHeader()
+		if len(w.buf) > 0 {
+			_, _ = w.rw.Write(w.buf)
+		}
+		return
+	}
+
+	if w.encoder == nil {
+		return
+	}
+	_ = w.encoder.Close()
+
+	switch encoder := w.encoder.(type) {
+	case *brotli.Writer:
+		encoder.Reset(nil)
+		brotliWriterPool.Put(encoder)
+	case *gzip.Writer:
+		encoder.Reset(nil)
+		gzipWriterPool.Put(encoder)
+	}
+	w.encoder = nil
+}
+
+// acceptedEncoding returns the preferred encoding of the given ones which is accepted by the Accept-Encoding
+// header value, see https://tools.ietf.org/html/rfc7231#section-5.3.4.
+func acceptedEncoding(acceptEncoding string, encodings ...string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for _, value := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(value, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if f, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+
+	var accepted string
+	var quality float64
+	for _, encoding := range encodings {
+		q, exist := qualities[encoding]
+		if !exist {
+			q, exist = qualities["*"]
+		}
+		if exist && q > quality {
+			accepted, quality = encoding, q
+		}
+	}
+	return accepted
+}
+
+// addVary adds the given header name to the Vary header if it is not listed yet.
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, v := range strings.Split(value, ",") {
+			v = strings.TrimSpace(v)
+			if v == "*" || strings.EqualFold(v, name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/avenga/couper/config"
+)
+
+func TestNewCompression(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    *config.Compression
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"defaults", &config.Compression{}, false},
+		{"configured", &config.Compression{MinSize: "2KiB", Types: []string{"text/*", "application/json"}}, false},
+		{"invalid min size", &config.Compression{MinSize: "small"}, true},
+		{"invalid type", &config.Compression{Types: []string{"text"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			_, err := NewCompression(tt.conf)
+			if (err != nil) != tt.wantErr {
+				subT.Errorf("NewCompression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"GZIP, BR", "br"},
+		{"gzip;q=1.0, br;q=0.8", "gzip"},
+		{"br;q=0, gzip", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(subT *testing.T) {
+			if got := acceptedEncoding(tt.acceptEncoding, supportedEncodings...); got != tt.want {
+				subT.Errorf("acceptedEncoding() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompress_ServeHTTP(t *testing.T) {
+	compression, err := NewCompression(&config.Compression{MinSize: "100B"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	large := strings.Repeat(`{"product":"couper"}`, 50)
+	small := `{"product":"couper"}`
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		header         http.Header
+		status         int
+		body           string
+		expEncoding    string
+		expVary        bool
+		expETag        string
+	}{
+		{"brotli", http.MethodGet, "gzip, br", http.Header{"Content-Type": {"application/json"}}, http.StatusOK, large, "br", true, ""},
+		{"gzip", http.MethodGet, "gzip", http.Header{"Content-Type": {"application/json; charset=utf-8"}}, http.StatusOK, large, "gzip", true, ""},
+		{"content length", http.MethodGet, "gzip", http.Header{"Content-Type": {"application/json"}, "Content-Length": {strconv.Itoa(len(large))}}, http.StatusOK, large, "gzip", true, ""},
+		{"weak etag", http.MethodGet, "gzip", http.Header{"Content-Type": {"text/html"}, "Etag": {`"1"`}}, http.StatusOK, large, "gzip", true, `W/"1"`},
+		{"error status", http.MethodGet, "gzip", http.Header{"Content-Type": {"application/json"}}, http.StatusBadGateway, large, "gzip", true, ""},
+		{"below min size", http.MethodGet, "gzip", http.Header{"Content-Type": {"application/json"}}, http.StatusOK, small, "", true, ""},
+		{"small content length", http.MethodGet, "gzip", http.Header{"Content-Type": {"application/json"}, "Content-Length": {strconv.Itoa(len(small))}}, http.StatusOK, small, "", true, ""},
+		{"not accepted", http.MethodGet, "", http.Header{"Content-Type": {"application/json"}, "Etag": {`"1"`}}, http.StatusOK, large, "", true, `"1"`},
+		{"head", http.MethodHead, "gzip", http.Header{"Content-Type": {"application/json"}}, http.StatusOK, "", "", true, ""},
+		{"other type", http.MethodGet, "gzip", http.Header{"Content-Type": {"image/png"}}, http.StatusOK, large, "", false, ""},
+		{"no content type", http.MethodGet, "gzip", http.Header{}, http.StatusOK, large, "", false, ""},
+		{"encoded", http.MethodGet, "gzip", http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"deflate"}}, http.StatusOK, large, "deflate", false, ""},
+		{"no-transform", http.MethodGet, "gzip", http.Header{"Content-Type": {"application/json"}, "Cache-Control": {"no-transform"}}, http.StatusOK, large, "", false, ""},
+		{"not modified", http.MethodGet, "gzip", http.Header{"Content-Type": {"application/json"}}, http.StatusNotModified, "", "", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			h := NewCompress(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				for k, v := range tt.header {
+					rw.Header()[k] = v
+				}
+				rw.WriteHeader(tt.status)
+				// written in chunks to pass the min size during the writes
+				for i := 0; i < len(tt.body); i += 64 {
+					end := i + 64
+					if end > len(tt.body) {
+						end = len(tt.body)
+					}
+					_, _ = io.WriteString(rw, tt.body[i:end])
+				}
+			}), compression)
+
+			req := httptest.NewRequest(tt.method, "http://couper.io/", nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			res := rec.Result()
+			if res.StatusCode != tt.status {
+				subT.Errorf("Expected status %d, got: %d", tt.status, res.StatusCode)
+			}
+			if encoding := res.Header.Get("Content-Encoding"); encoding != tt.expEncoding {
+				subT.Errorf("Expected Content-Encoding %q, got: %q", tt.expEncoding, encoding)
+			}
+			if vary := res.Header.Get("Vary") == "Accept-Encoding"; vary != tt.expVary {
+				subT.Errorf("Expected Vary header: %v, got: %q", tt.expVary, res.Header.Get("Vary"))
+			}
+			if etag := res.Header.Get("ETag"); etag != tt.expETag && tt.header.Get("ETag") != "" {
+				subT.Errorf("Expected ETag %q, got: %q", tt.expETag, etag)
+			}
+
+			var body io.Reader = res.Body
+			switch tt.expEncoding {
+			case "br":
+				body = brotli.NewReader(res.Body)
+			case "gzip":
+				if res.Header.Get("Content-Length") != "" {
+					subT.Errorf("Expected no Content-Length for the compressed response")
+				}
+				gr, err := gzip.NewReader(res.Body)
+				if err != nil {
+					subT.Fatal(err)
+				}
+				body = gr
+			}
+
+			b, err := ioutil.ReadAll(body)
+			if err != nil {
+				subT.Fatal(err)
+			}
+			if !bytes.Equal(b, []byte(tt.body)) {
+				subT.Errorf("Expected the original body, got: %q", string(b))
+			}
+		})
+	}
+}
+
+func TestCompress_Flush(t *testing.T) {
+	compression, err := NewCompression(&config.Compression{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewCompress(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(rw, "data: couper\n\n")
+		rw.(http.Flusher).Flush()
+	}), compression)
+
+	req := httptest.NewRequest(http.MethodGet, "http://couper.io/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if !rec.Flushed {
+		t.Error("Expected a flushed response")
+	}
+	if encoding := rec.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Errorf("Expected the flushed response to be compressed, got: %q", encoding)
+	}
+
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "data: couper\n\n" {
+		t.Errorf("Unexpected body: %q", string(b))
+	}
+}
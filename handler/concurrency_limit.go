@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/avenga/couper/config"
+	couperErr "github.com/avenga/couper/errors"
+)
+
+const defaultQueueTimeout = time.Second * 10
+
+// ConcurrencyLimit limits the in-flight requests of a backend. Further requests wait in a bounded
+// queue for a free slot until the queue timeout or their deadline is reached.
+type ConcurrencyLimit struct {
+	queueSize    int
+	queueTimeout time.Duration
+	slots        chan struct{}
+
+	mu     sync.Mutex
+	queued int
+}
+
+// NewConcurrencyLimit validates the concurrency settings of the given backend configuration
+// and creates a ConcurrencyLimit. Returns nil if max_concurrent_requests is not configured.
+func NewConcurrencyLimit(conf *config.Backend) (*ConcurrencyLimit, error) {
+	if conf.MaxConcurrentRequests < 0 || conf.MaxQueueSize < 0 {
+		return nil, fmt.Errorf("max_concurrent_requests and max_queue_size must be positive")
+	}
+
+	if conf.MaxConcurrentRequests == 0 {
+		if conf.MaxQueueSize > 0 || conf.QueueTimeout != "" {
+			return nil, fmt.Errorf("max_queue_size and queue_timeout require max_concurrent_requests")
+		}
+		return nil, nil
+	}
+
+	cl := &ConcurrencyLimit{
+		queueSize:    conf.MaxQueueSize,
+		queueTimeout: defaultQueueTimeout,
+		slots:        make(chan struct{}, conf.MaxConcurrentRequests),
+	}
+
+	if conf.QueueTimeout != "" {
+		d, err := time.ParseDuration(conf.QueueTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("queue_timeout: %v", err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("queue_timeout must be positive")
+		}
+		cl.queueTimeout = d
+	}
+
+	return cl, nil
+}
+
+// Acquire returns an APIConcurrencyLimit error if no slot gets free in time.
+// Each successful call must be followed by a Release call.
+func (cl *ConcurrencyLimit) Acquire(ctx context.Context) error {
+	cl.mu.Lock()
+	// queued requests are preferred over new ones
+	if cl.queued == 0 {
+		select {
+		case cl.slots <- struct{}{}:
+			cl.mu.Unlock()
+			return nil
+		default:
+		}
+	}
+
+	if cl.queued >= cl.queueSize {
+		cl.mu.Unlock()
+		return couperErr.APIConcurrencyLimit
+	}
+	cl.queued++
+	cl.mu.Unlock()
+
+	defer func() {
+		cl.mu.Lock()
+		cl.queued--
+		cl.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(cl.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case cl.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return couperErr.APIConcurrencyLimit
+}
+
+// Release frees the slot of an acquired request.
+func (cl *ConcurrencyLimit) Release() {
+	<-cl.slots
+}
+
+type releaseReadCloser struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaseReadCloser) Close() error {
+	defer r.release()
+	return r.ReadCloser.Close()
+}
+
+// releaseReadWriteCloser keeps the writable body of upgraded connections.
+type releaseReadWriteCloser struct {
+	io.ReadWriteCloser
+	release func()
+}
+
+func (r *releaseReadWriteCloser) Close() error {
+	defer r.release()
+	return r.ReadWriteCloser.Close()
+}
+
+// releaseOnClose calls the release function once the given body gets closed.
+func releaseOnClose(body io.ReadCloser, release func()) io.ReadCloser {
+	once := &sync.Once{}
+	releaseOnce := func() { once.Do(release) }
+	if rwc, ok := body.(io.ReadWriteCloser); ok {
+		return &releaseReadWriteCloser{ReadWriteCloser: rwc, release: releaseOnce}
+	}
+	return &releaseReadCloser{ReadCloser: body, release: releaseOnce}
+}
@@ -0,0 +1,157 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/config/runtime/server"
+	"github.com/avenga/couper/errors"
+	"github.com/avenga/couper/eval"
+	"github.com/avenga/couper/handler"
+	"github.com/avenga/couper/internal/test"
+)
+
+func TestNewConcurrencyLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    *config.Backend
+		wantNil bool
+		wantErr bool
+	}{
+		{"not configured", &config.Backend{}, true, false},
+		{"limit", &config.Backend{MaxConcurrentRequests: 10}, false, false},
+		{"queue", &config.Backend{MaxConcurrentRequests: 10, MaxQueueSize: 100, QueueTimeout: "2s"}, false, false},
+		{"negative limit", &config.Backend{MaxConcurrentRequests: -1}, true, true},
+		{"queue without limit", &config.Backend{MaxQueueSize: 10}, true, true},
+		{"queue timeout without limit", &config.Backend{QueueTimeout: "1s"}, true, true},
+		{"invalid queue timeout", &config.Backend{MaxConcurrentRequests: 1, QueueTimeout: "1 second"}, true, true},
+		{"zero queue timeout", &config.Backend{MaxConcurrentRequests: 1, QueueTimeout: "0s"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			cl, err := handler.NewConcurrencyLimit(tt.conf)
+			if (err != nil) != tt.wantErr {
+				subT.Errorf("NewConcurrencyLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (cl == nil) != tt.wantNil {
+				subT.Errorf("NewConcurrencyLimit() = %v, wantNil %v", cl, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimit_Acquire(t *testing.T) {
+	helper := test.New(t)
+
+	ctx := context.Background()
+
+	cl, err := handler.NewConcurrencyLimit(&config.Backend{MaxConcurrentRequests: 1, MaxQueueSize: 1, QueueTimeout: "50ms"})
+	helper.Must(err)
+	helper.Must(cl.Acquire(ctx))
+
+	// the queued request times out
+	start := time.Now()
+	if err = cl.Acquire(ctx); err != errors.APIConcurrencyLimit {
+		t.Fatalf("Expected error %v, got: %v", errors.APIConcurrencyLimit, err)
+	}
+	if d := time.Since(start); d < time.Millisecond*50 {
+		t.Errorf("Expected a queue timeout of 50ms, got: %s", d)
+	}
+
+	cl, err = handler.NewConcurrencyLimit(&config.Backend{MaxConcurrentRequests: 2, MaxQueueSize: 1, QueueTimeout: "5s"})
+	helper.Must(err)
+	helper.Must(cl.Acquire(ctx))
+	helper.Must(cl.Acquire(ctx))
+
+	queued := make(chan error)
+	go func() {
+		queued <- cl.Acquire(ctx)
+	}()
+	time.Sleep(time.Millisecond * 20)
+
+	// further requests exceed the queue size
+	start = time.Now()
+	if err = cl.Acquire(ctx); err != errors.APIConcurrencyLimit {
+		t.Fatalf("Expected error %v, got: %v", errors.APIConcurrencyLimit, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected an immediate rejection, got: %s", d)
+	}
+
+	cl.Release()
+	if err = <-queued; err != nil {
+		t.Errorf("Expected the released slot for the queued request, got: %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err = cl.Acquire(canceled); err != errors.APIConcurrencyLimit {
+		t.Errorf("Expected error %v for a canceled request, got: %v", errors.APIConcurrencyLimit, err)
+	}
+}
+
+func TestProxy_ServeHTTP_ConcurrencyLimit(t *testing.T) {
+	helper := test.New(t)
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		started <- struct{}{}
+		<-unblock
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	opts, err := handler.NewProxyOptions(&config.Backend{
+		ConnectTimeout:        "1s",
+		MaxConcurrentRequests: 1,
+		MaxIdleConnections:    4,
+		Name:                  "limited",
+		RequestBodyLimit:      "64MiB",
+		TTFBTimeout:           "1s",
+		Timeout:               "1s",
+	}, nil, test.NewRemainContext("origin", origin.URL), nil)
+	helper.Must(err)
+
+	logger, _ := logrustest.NewNullLogger()
+	srvOpts := &server.Options{APIErrTpl: errors.DefaultJSON}
+	proxy, err := handler.NewProxy(opts, logger.WithContext(context.Background()), srvOpts, eval.NewENVContext(nil))
+	helper.Must(err)
+
+	inFlight := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		proxy.ServeHTTP(inFlight, httptest.NewRequest(http.MethodGet, "http://couper.io/", nil))
+		close(done)
+	}()
+	<-started
+
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://couper.io/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got: %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if code := rec.Header().Get(errors.HeaderErrorCode); code != `4006 - "API backend concurrency limit exceeded"` {
+		t.Errorf("Expected concurrency limit error code, got: %q", code)
+	}
+
+	close(unblock)
+	<-done
+	if inFlight.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got: %d", http.StatusNoContent, inFlight.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	go func() { <-started }()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://couper.io/", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d after the release, got: %d", http.StatusNoContent, rec.Code)
+	}
+}
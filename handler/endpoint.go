@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	ac "github.com/avenga/couper/accesscontrol"
+	"github.com/avenga/couper/config/request"
+	"github.com/avenga/couper/config/runtime/server"
+)
+
+var (
+	_ ac.ProtectedHandler = &Endpoint{}
+	_ server.Context      = &Endpoint{}
+)
+
+// Endpoint sets the configured endpoint pattern to the request context
+// which gets used by the access log, metrics and the req.endpoint variable.
+type Endpoint struct {
+	handler http.Handler
+	pattern string
+}
+
+func NewEndpoint(pattern string, h http.Handler) *Endpoint {
+	return &Endpoint{handler: h, pattern: pattern}
+}
+
+func (e *Endpoint) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	*req = *req.WithContext(context.WithValue(req.Context(), request.Endpoint, e.pattern))
+	e.handler.ServeHTTP(rw, req)
+}
+
+func (e *Endpoint) Child() http.Handler {
+	return e.handler
+}
+
+func (e *Endpoint) String() string {
+	if s, ok := e.handler.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return ""
+}
+
+// Options returns the server options of the wrapped handler if available.
+func (e *Endpoint) Options() *server.Options {
+	if c, ok := e.handler.(server.Context); ok {
+		return c.Options()
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path"
@@ -15,6 +17,12 @@ import (
 
 const dirIndexFile = "index.html"
 
+// precompressedExtensions maps the supported encodings to the file extension of precompressed files.
+var precompressedExtensions = map[string]string{
+	encodingBrotli: ".br",
+	encodingGzip:   ".gz",
+}
+
 var (
 	_ HasResponse    = &File{}
 	_ http.Handler   = &File{}
@@ -82,8 +90,7 @@ func (f *File) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	// TODO: gzip, br?
-	http.ServeContent(rw, req, reqPath, info.ModTime(), file)
+	f.serveContent(rw, req, reqPath, file, info)
 }
 
 func (f *File) serveDirectory(reqPath string, rw http.ResponseWriter, req *http.Request) {
@@ -102,8 +109,48 @@ func (f *File) serveDirectory(reqPath string, rw http.ResponseWriter, req *http.
 	}
 	defer file.Close()
 
-	// TODO: gzip, br?
-	http.ServeContent(rw, req, reqPath, info.ModTime(), file)
+	f.serveContent(rw, req, reqPath, file, info)
+}
+
+// serveContent serves a precompressed sibling of the requested file if the client accepts its encoding.
+func (f *File) serveContent(rw http.ResponseWriter, req *http.Request, reqPath string, file http.File, info os.FileInfo) {
+	var content io.ReadSeeker = file
+	modTime := info.ModTime()
+
+	// the content type of a precompressed file can't be sniffed
+	if contentType := mime.TypeByExtension(path.Ext(reqPath)); contentType != "" {
+		var encodings []string
+		for _, encoding := range supportedEncodings {
+			if f.hasFile(reqPath + precompressedExtensions[encoding]) {
+				encodings = append(encodings, encoding)
+			}
+		}
+
+		if len(encodings) > 0 {
+			addVary(rw.Header(), "Accept-Encoding")
+		}
+
+		if encoding := acceptedEncoding(req.Header.Get("Accept-Encoding"), encodings...); encoding != "" {
+			encFile, encInfo, err := f.openDocRootFile(reqPath + precompressedExtensions[encoding])
+			if err == nil {
+				defer encFile.Close()
+				rw.Header().Set("Content-Encoding", encoding)
+				rw.Header().Set("Content-Type", contentType)
+				content, modTime = encFile, encInfo.ModTime()
+			}
+		}
+	}
+
+	http.ServeContent(rw, req, reqPath, modTime, content)
+}
+
+func (f *File) hasFile(name string) bool {
+	file, info, err := f.openDocRootFile(name)
+	if err != nil {
+		return false
+	}
+	file.Close()
+	return !info.IsDir()
 }
 
 func (f *File) HasResponse(req *http.Request) bool {
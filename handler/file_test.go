@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"bytes"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -61,3 +64,62 @@ func TestFile_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestFile_ServeHTTP_Precompressed(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srvOpts, _ := server.NewServerOptions(&config.Server{})
+	f, err := NewFile("/", path.Join(wd, "testdata/file"), srvOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		expEncoding    string
+		expVary        bool
+	}{
+		{"brotli", "/precompressed/app.js", "gzip, deflate, br", "br", true},
+		{"gzip preferred", "/precompressed/app.js", "gzip, br;q=0.5", "gzip", true},
+		{"gzip only", "/precompressed/gzip_only.css", "gzip, br", "gzip", true},
+		{"not accepted", "/precompressed/app.js", "deflate", "", true},
+		{"no precompressed file", "/index.html", "gzip, br", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://domain.test"+tt.path, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+
+			rec := httptest.NewRecorder()
+			f.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				subT.Fatalf("Expected status %d, got: %d", http.StatusOK, rec.Code)
+			}
+			if encoding := rec.Header().Get("Content-Encoding"); encoding != tt.expEncoding {
+				subT.Errorf("Expected Content-Encoding %q, got: %q", tt.expEncoding, encoding)
+			}
+			// the type of the uncompressed file
+			if contentType, expType := rec.Header().Get("Content-Type"), mime.TypeByExtension(path.Ext(tt.path)); contentType != expType {
+				subT.Errorf("Expected Content-Type %q, got: %q", expType, contentType)
+			}
+			if vary := rec.Header().Get("Vary") == "Accept-Encoding"; vary != tt.expVary {
+				subT.Errorf("Expected Vary header: %v, got: %q", tt.expVary, rec.Header().Get("Vary"))
+			}
+
+			expBody, err := ioutil.ReadFile(path.Join("testdata/file", tt.path+precompressedExtensions[tt.expEncoding]))
+			if err != nil {
+				subT.Fatal(err)
+			}
+			if !bytes.Equal(rec.Body.Bytes(), expBody) {
+				subT.Errorf("Expected the content of the %q encoded file", tt.expEncoding)
+			}
+		})
+	}
+}
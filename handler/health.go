@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
 	"strings"
 
@@ -9,14 +10,30 @@ import (
 
 const healthPath = "/healthz"
 
+const (
+	healthStatusHealthy   = "healthy"
+	healthStatusDegraded  = "degraded"
+	healthStatusUnhealthy = "unhealthy"
+)
+
 var _ http.Handler = &Health{}
 
 type Health struct {
 	path       string
 	shutdownCh chan struct{}
+	// details enables the detailed view which lists the backends
+	details  bool
+	backends []*BackendHealth
+}
+
+// healthDetails represents the detailed view which is requested with the "details" query parameter.
+// The status is "degraded" if some origins are unhealthy and "unhealthy" if a backend has no healthy origin.
+type healthDetails struct {
+	Backends map[string]map[string]OriginHealthState `json:"backends"`
+	Status   string                                  `json:"status"`
 }
 
-func NewHealthCheck(path string, shutdownCh chan struct{}) *Health {
+func NewHealthCheck(path string, details bool, shutdownCh chan struct{}, backends ...*BackendHealth) *Health {
 	p := path
 	if p == "" {
 		p = healthPath
@@ -29,21 +46,66 @@ func NewHealthCheck(path string, shutdownCh chan struct{}) *Health {
 	return &Health{
 		path:       p,
 		shutdownCh: shutdownCh,
+		details:    details,
+		backends:   backends,
 	}
 }
 
-func (h *Health) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+func (h *Health) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	rw.Header().Set("Cache-Control", "no-store")
-	rw.Header().Set("Content-Type", "text/plain")
 
 	select {
 	case <-h.shutdownCh:
+		rw.Header().Set("Content-Type", "text/plain")
 		errors.SetHeader(rw, errors.ServerShutdown)
 		rw.WriteHeader(http.StatusInternalServerError)
 		_, _ = rw.Write([]byte("server shutting down"))
 	default:
-		_, _ = rw.Write([]byte("healthy"))
+		if _, ok := req.URL.Query()["details"]; ok && h.details {
+			h.serveDetails(rw)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/plain")
+		_, _ = rw.Write([]byte(healthStatusHealthy))
+	}
+}
+
+// serveDetails writes the health states of all backend origins.
+func (h *Health) serveDetails(rw http.ResponseWriter) {
+	details := &healthDetails{
+		Backends: make(map[string]map[string]OriginHealthState),
+		Status:   healthStatusHealthy,
+	}
+
+	for _, backend := range h.backends {
+		origins, ok := details.Backends[backend.Name()]
+		if !ok {
+			origins = make(map[string]OriginHealthState)
+			details.Backends[backend.Name()] = origins
+		}
+
+		var healthy int
+		states := backend.States()
+		for origin, state := range states {
+			origins[origin] = state
+			if state.Healthy {
+				healthy++
+			}
+		}
+
+		switch {
+		case healthy == 0 && len(states) > 0:
+			details.Status = healthStatusUnhealthy
+		case healthy < len(states) && details.Status == healthStatusHealthy:
+			details.Status = healthStatusDegraded
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	if details.Status == healthStatusUnhealthy {
+		rw.WriteHeader(http.StatusServiceUnavailable)
 	}
+	_ = json.NewEncoder(rw).Encode(details)
 }
 
 func (h *Health) Match(req *http.Request) bool {
@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/avenga/couper/config"
 )
 
 func TestHealth_Match(t *testing.T) {
@@ -51,7 +57,7 @@ func TestHealth_ServeHTTP(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			h := NewHealthCheck(tt.fields.path, tt.fields.shutdownCh)
+			h := NewHealthCheck(tt.fields.path, false, tt.fields.shutdownCh)
 			rec := httptest.NewRecorder()
 			if tt.wantStatus >= 500 {
 				close(tt.fields.shutdownCh)
@@ -109,16 +115,93 @@ func TestNewHealthCheck(t *testing.T) {
 		args args
 		want *Health
 	}{
-		{"/w given path", args{"/myhealth", shutdownChan}, &Health{"/myhealth", shutdownChan}},
-		{"/w given path w/o leading slash", args{"myhealth", shutdownChan}, &Health{"/myhealth", shutdownChan}},
-		{"w/o given path", args{"", shutdownChan}, &Health{healthPath, shutdownChan}},
-		{"w/o given path & chan", args{"", nil}, &Health{healthPath, nil}},
+		{"/w given path", args{"/myhealth", shutdownChan}, &Health{path: "/myhealth", shutdownCh: shutdownChan}},
+		{"/w given path w/o leading slash", args{"myhealth", shutdownChan}, &Health{path: "/myhealth", shutdownCh: shutdownChan}},
+		{"w/o given path", args{"", shutdownChan}, &Health{path: healthPath, shutdownCh: shutdownChan}},
+		{"w/o given path & chan", args{"", nil}, &Health{path: healthPath}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewHealthCheck(tt.args.path, tt.args.shutdownCh); !reflect.DeepEqual(got, tt.want) {
+			if got := NewHealthCheck(tt.args.path, false, tt.args.shutdownCh); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewHealthCheck() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestHealth_ServeHTTP_Details(t *testing.T) {
+	newBackendHealth := func(name string, unhealthy ...int) *BackendHealth {
+		lb, err := NewLoadBalancer(&config.LoadBalancer{
+			Origins: []*config.LoadBalancerOrigin{{URL: "http://a.couper.io"}, {URL: "http://b.couper.io"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		backendHealth, err := NewBackendHealth(name, &config.Health{}, lb, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, i := range unhealthy {
+			backendHealth.update(logrus.NewEntry(logrus.New()), lb.origins[i], fmt.Errorf("connection refused"))
+			backendHealth.update(logrus.NewEntry(logrus.New()), lb.origins[i], fmt.Errorf("connection refused"))
+		}
+		return backendHealth
+	}
+
+	tests := []struct {
+		name       string
+		details    bool
+		backends   []*BackendHealth
+		wantStatus int
+		wantHealth string
+	}{
+		{"disabled", false, []*BackendHealth{newBackendHealth("replicas", 1)}, http.StatusOK, ""},
+		{"healthy", true, []*BackendHealth{newBackendHealth("replicas")}, http.StatusOK, healthStatusHealthy},
+		{"degraded", true, []*BackendHealth{newBackendHealth("replicas", 1)}, http.StatusOK, healthStatusDegraded},
+		{"unhealthy", true, []*BackendHealth{newBackendHealth("replicas", 1), newBackendHealth("down", 0, 1)}, http.StatusServiceUnavailable, healthStatusUnhealthy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			h := NewHealthCheck("", tt.details, make(chan struct{}), tt.backends...)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz?details", nil))
+
+			res := rec.Result()
+			if res.StatusCode != tt.wantStatus {
+				subT.Errorf("Expected statusCode: %d, got: %d", tt.wantStatus, res.StatusCode)
+			}
+
+			if !tt.details {
+				if ct := res.Header.Get("Content-Type"); ct != "text/plain" {
+					subT.Errorf("Expected the plain health view, got: %q", ct)
+				}
+				return
+			}
+
+			if ct := res.Header.Get("Content-Type"); ct != "application/json" {
+				subT.Errorf("Expected Content-Type header with 'application/json' value, got: %q", ct)
+			}
+
+			details := &healthDetails{}
+			if err := json.NewDecoder(res.Body).Decode(details); err != nil {
+				subT.Fatal(err)
+			}
+
+			if details.Status != tt.wantHealth {
+				subT.Errorf("Expected status %q, got: %q", tt.wantHealth, details.Status)
+			}
+
+			origins := details.Backends["replicas"]
+			if !origins["http://a.couper.io"].Healthy {
+				subT.Error("Expected a healthy origin a")
+			}
+
+			if state := origins["http://b.couper.io"]; tt.wantHealth != healthStatusHealthy && (state.Healthy || state.Error != "connection refused") {
+				subT.Errorf("Expected an unhealthy origin b, got: %#v", state)
+			}
+		})
+	}
+}
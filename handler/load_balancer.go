@@ -0,0 +1,243 @@
+package handler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/internal/seetie"
+)
+
+// Load balancing strategies.
+const (
+	StrategyHash             = "hash"
+	StrategyLeastConnections = "least_connections"
+	StrategyRandom           = "random"
+	StrategyRoundRobin       = "round_robin"
+)
+
+// originContextKey stores the selected *Origin within the backend request context.
+type originContextKey struct{}
+
+// Origin represents one weighted upstream of a LoadBalancer.
+type Origin struct {
+	URL    *url.URL
+	Weight int
+
+	// active counts the currently running requests
+	active int64
+	// currentWeight is used by the smooth weighted round robin selection
+	currentWeight int
+	// health is set if the backend has configured health checks
+	health *originHealth
+}
+
+func (o *Origin) release() {
+	atomic.AddInt64(&o.active, -1)
+}
+
+// LoadBalancer selects an Origin per request with the configured strategy.
+type LoadBalancer struct {
+	hashKey  hcl.Expression
+	mu       sync.Mutex
+	origins  []*Origin
+	rnd      *rand.Rand
+	strategy string
+}
+
+// NewLoadBalancer validates the given load_balancer configuration and creates a LoadBalancer.
+func NewLoadBalancer(conf *config.LoadBalancer) (*LoadBalancer, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	lb := &LoadBalancer{
+		hashKey:  conf.HashKey,
+		rnd:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		strategy: conf.Strategy,
+	}
+
+	switch lb.strategy {
+	case "":
+		lb.strategy = StrategyRoundRobin
+	case StrategyRoundRobin, StrategyRandom, StrategyLeastConnections:
+	case StrategyHash:
+		if lb.hashKey == nil || isNullExpression(lb.hashKey) {
+			return nil, fmt.Errorf("load_balancer: strategy %q requires a hash_key", StrategyHash)
+		}
+	default:
+		return nil, fmt.Errorf("load_balancer: unsupported strategy: %q", conf.Strategy)
+	}
+
+	if len(conf.Origins) == 0 {
+		return nil, fmt.Errorf("load_balancer: at least one origin is required")
+	}
+
+	for _, o := range conf.Origins {
+		u, err := url.Parse(o.URL)
+		if err != nil {
+			return nil, fmt.Errorf("load_balancer: origin %q: %v", o.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("load_balancer: origin %q: valid http scheme and host required", o.URL)
+		}
+
+		weight := o.Weight
+		if weight == 0 {
+			weight = 1
+		} else if weight < 0 {
+			return nil, fmt.Errorf("load_balancer: origin %q: weight must be positive", o.URL)
+		}
+
+		lb.origins = append(lb.origins, &Origin{URL: u, Weight: weight})
+	}
+
+	return lb, nil
+}
+
+// Next selects a healthy origin for the upcoming request. The given context is used
+// to evaluate the hash_key expression. The returned origin counts as active
+// until it gets released. Returns nil if all origins are unhealthy.
+func (lb *LoadBalancer) Next(ctx *hcl.EvalContext) *Origin {
+	var origin *Origin
+
+	switch lb.strategy {
+	case StrategyHash:
+		if key := lb.evalHashKey(ctx); key != "" {
+			origin = lb.hash(key)
+			break
+		}
+		// requests without a key are distributed evenly
+		origin = lb.roundRobin()
+	case StrategyLeastConnections:
+		origin = lb.leastConnections()
+	case StrategyRandom:
+		origin = lb.random()
+	default:
+		origin = lb.roundRobin()
+	}
+
+	if origin == nil {
+		return nil
+	}
+
+	atomic.AddInt64(&origin.active, 1)
+	return origin
+}
+
+// Origins returns all configured origins.
+func (lb *LoadBalancer) Origins() []*Origin {
+	return lb.origins
+}
+
+func (lb *LoadBalancer) evalHashKey(ctx *hcl.EvalContext) string {
+	val, diags := lb.hashKey.Value(ctx)
+	if diags.HasErrors() || val.IsNull() {
+		return ""
+	}
+	return seetie.ValueToString(val)
+}
+
+// roundRobin implements the smooth weighted round robin selection
+// which spreads the weighted origins evenly over time.
+func (lb *LoadBalancer) roundRobin() *Origin {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	var selected *Origin
+	var totalWeight int
+	for _, o := range lb.origins {
+		if !o.health.healthy() {
+			continue
+		}
+		o.currentWeight += o.Weight
+		totalWeight += o.Weight
+		if selected == nil || o.currentWeight > selected.currentWeight {
+			selected = o
+		}
+	}
+
+	if selected != nil {
+		selected.currentWeight -= totalWeight
+	}
+	return selected
+}
+
+func (lb *LoadBalancer) random() *Origin {
+	var healthy []*Origin
+	var totalWeight int
+	for _, o := range lb.origins {
+		if o.health.healthy() {
+			healthy = append(healthy, o)
+			totalWeight += o.Weight
+		}
+	}
+
+	if totalWeight == 0 {
+		return nil
+	}
+
+	lb.mu.Lock()
+	n := lb.rnd.Intn(totalWeight)
+	lb.mu.Unlock()
+
+	for _, o := range healthy {
+		if n < o.Weight {
+			return o
+		}
+		n -= o.Weight
+	}
+	return healthy[len(healthy)-1]
+}
+
+// leastConnections selects the origin with the lowest amount of active requests relative to its weight.
+func (lb *LoadBalancer) leastConnections() *Origin {
+	var selected *Origin
+	var selectedActive int64
+	for _, o := range lb.origins {
+		if !o.health.healthy() {
+			continue
+		}
+		active := atomic.LoadInt64(&o.active)
+		if selected == nil || active*int64(selected.Weight) < selectedActive*int64(o.Weight) {
+			selected, selectedActive = o, active
+		}
+	}
+	return selected
+}
+
+// hash implements a weighted rendezvous hashing. The same key results in the same origin
+// as long as the origin is healthy and just the keys of an unhealthy origin get redistributed.
+func (lb *LoadBalancer) hash(key string) *Origin {
+	var selected *Origin
+	var selectedScore float64
+	for _, o := range lb.origins {
+		if !o.health.healthy() {
+			continue
+		}
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(o.URL.String()))
+		_, _ = h.Write([]byte(key))
+		// map the hash to (0, 1)
+		x := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		score := float64(o.Weight) / -math.Log(x)
+		if selected == nil || score > selectedScore {
+			selected, selectedScore = o, score
+		}
+	}
+	return selected
+}
+
+// isNullExpression reports whether the expression is a missing or null attribute.
+func isNullExpression(expr hcl.Expression) bool {
+	val, diags := expr.Value(nil)
+	return !diags.HasErrors() && val.IsNull()
+}
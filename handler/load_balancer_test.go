@@ -0,0 +1,150 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/eval"
+	"github.com/avenga/couper/handler"
+)
+
+func newLoadBalancerConf(strategy string, hashKey string, weights ...int) *config.LoadBalancer {
+	conf := &config.LoadBalancer{Strategy: strategy}
+	if hashKey != "" {
+		expr, diags := hclsyntax.ParseExpression([]byte(hashKey), "test.hcl", hcl.InitialPos)
+		if diags.HasErrors() {
+			panic(diags)
+		}
+		conf.HashKey = expr
+	}
+	origins := []string{"http://a.couper.io", "http://b.couper.io", "https://c.couper.io:8443"}
+	for i, w := range weights {
+		conf.Origins = append(conf.Origins, &config.LoadBalancerOrigin{URL: origins[i], Weight: w})
+	}
+	return conf
+}
+
+func TestNewLoadBalancer(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    *config.LoadBalancer
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"default strategy", newLoadBalancerConf("", "", 1, 2), false},
+		{"random", newLoadBalancerConf(handler.StrategyRandom, "", 1), false},
+		{"least connections", newLoadBalancerConf(handler.StrategyLeastConnections, "", 1), false},
+		{"hash", newLoadBalancerConf(handler.StrategyHash, "req.headers.x-user", 1), false},
+		{"hash without key", newLoadBalancerConf(handler.StrategyHash, "", 1), true},
+		{"hash with null key", newLoadBalancerConf(handler.StrategyHash, "null", 1), true},
+		{"unknown strategy", newLoadBalancerConf("fastest", "", 1), true},
+		{"without origins", newLoadBalancerConf("", ""), true},
+		{"negative weight", newLoadBalancerConf("", "", 1, -1), true},
+		{"invalid origin", &config.LoadBalancer{Origins: []*config.LoadBalancerOrigin{{URL: "ftp://couper.io"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			lb, err := handler.NewLoadBalancer(tt.conf)
+			if (err != nil) != tt.wantErr {
+				subT.Fatalf("NewLoadBalancer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.conf == nil && lb != nil {
+				subT.Error("Expected no load balancer")
+			}
+		})
+	}
+}
+
+func TestLoadBalancer_Next(t *testing.T) {
+	evalCtx := eval.NewENVContext(nil)
+
+	t.Run("weighted round robin", func(subT *testing.T) {
+		lb, err := handler.NewLoadBalancer(newLoadBalancerConf(handler.StrategyRoundRobin, "", 3, 1))
+		if err != nil {
+			subT.Fatal(err)
+		}
+
+		var sequence string
+		for i := 0; i < 8; i++ {
+			sequence += lb.Next(evalCtx).URL.Host[:1]
+		}
+
+		// smooth distribution instead of aaab
+		if sequence != "aabaaaba" {
+			subT.Errorf("Expected sequence %q, got: %q", "aabaaaba", sequence)
+		}
+	})
+
+	t.Run("random", func(subT *testing.T) {
+		lb, err := handler.NewLoadBalancer(newLoadBalancerConf(handler.StrategyRandom, "", 1, 1, 1))
+		if err != nil {
+			subT.Fatal(err)
+		}
+
+		seen := make(map[string]int)
+		for i := 0; i < 300; i++ {
+			seen[lb.Next(evalCtx).URL.Host]++
+		}
+
+		if len(seen) != 3 {
+			subT.Errorf("Expected all origins to be selected, got: %v", seen)
+		}
+	})
+
+	t.Run("least connections", func(subT *testing.T) {
+		lb, err := handler.NewLoadBalancer(newLoadBalancerConf(handler.StrategyLeastConnections, "", 1, 2))
+		if err != nil {
+			subT.Fatal(err)
+		}
+
+		// requests are not released and count as active
+		var sequence string
+		for i := 0; i < 6; i++ {
+			sequence += lb.Next(evalCtx).URL.Host[:1]
+		}
+
+		if sequence != "abbabb" {
+			subT.Errorf("Expected sequence %q, got: %q", "abbabb", sequence)
+		}
+	})
+
+	t.Run("hash", func(subT *testing.T) {
+		lb, err := handler.NewLoadBalancer(newLoadBalancerConf(handler.StrategyHash, "req.headers.x-user", 1, 1, 1))
+		if err != nil {
+			subT.Fatal(err)
+		}
+
+		newCtx := func(user string) *hcl.EvalContext {
+			req := httptest.NewRequest(http.MethodGet, "http://couper.io/", nil)
+			if user != "" {
+				req.Header.Set("X-User", user)
+			}
+			return eval.NewHTTPContext(evalCtx, eval.BufferNone, req, nil, nil)
+		}
+
+		seen := make(map[string]bool)
+		for _, user := range []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace"} {
+			selected := lb.Next(newCtx(user)).URL.Host
+			for i := 0; i < 5; i++ {
+				if host := lb.Next(newCtx(user)).URL.Host; host != selected {
+					subT.Fatalf("Expected stable origin %q for %q, got: %q", selected, user, host)
+				}
+			}
+			seen[selected] = true
+		}
+
+		if len(seen) < 2 {
+			subT.Errorf("Expected keys to be distributed, got: %v", seen)
+		}
+
+		if o := lb.Next(newCtx("")); o == nil {
+			subT.Error("Expected an origin for requests without a hash key")
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	ac "github.com/avenga/couper/accesscontrol"
+	"github.com/avenga/couper/errors"
+)
+
+var (
+	_ http.Handler         = &OIDCCallback{}
+	_ errors.ErrorTemplate = &OIDCCallback{}
+	_ http.Handler         = &OIDCLogout{}
+)
+
+// OIDCCallback handles the redirect of the OpenID provider and starts the session.
+type OIDCCallback struct {
+	errorTpl *errors.Template
+	log      logrus.FieldLogger
+	oidc     *ac.OIDC
+}
+
+func NewOIDCCallback(oidc *ac.OIDC, errTpl *errors.Template, log logrus.FieldLogger) *OIDCCallback {
+	return &OIDCCallback{
+		errorTpl: errTpl,
+		log:      log,
+		oidc:     oidc,
+	}
+}
+
+func (o *OIDCCallback) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if err := o.oidc.ServeCallback(rw, req); err != nil {
+		o.log.WithField("oidc", o.oidc.RedirectPath()).Error(err)
+		o.errorTpl.ServeError(errors.AuthorizationFailed).ServeHTTP(rw, req)
+	}
+}
+
+func (o *OIDCCallback) Template() *errors.Template {
+	return o.errorTpl
+}
+
+func (o *OIDCCallback) String() string {
+	return "oidc"
+}
+
+// OIDCLogout removes the session cookie.
+type OIDCLogout struct {
+	oidc *ac.OIDC
+}
+
+func NewOIDCLogout(oidc *ac.OIDC) *OIDCLogout {
+	return &OIDCLogout{oidc: oidc}
+}
+
+func (o *OIDCLogout) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	o.oidc.ServeLogout(rw, req)
+}
+
+func (o *OIDCLogout) String() string {
+	return "oidc"
+}
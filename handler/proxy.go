@@ -6,13 +6,14 @@ import (
 	"crypto/tls"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/hashicorp/hcl/v2"
@@ -27,6 +28,7 @@ import (
 	"github.com/avenga/couper/eval"
 	"github.com/avenga/couper/internal/seetie"
 	"github.com/avenga/couper/logging"
+	"github.com/avenga/couper/tracing"
 	"github.com/avenga/couper/utils"
 )
 
@@ -34,8 +36,6 @@ var (
 	_ http.Handler   = &Proxy{}
 	_ server.Context = &Proxy{}
 
-	transports sync.Map
-
 	// headerBlacklist lists all header keys which will be removed after
 	// context variable evaluation to ensure to not pass them upstream.
 	headerBlacklist = []string{"Authorization", "Cookie"}
@@ -98,6 +98,10 @@ func NewProxy(options *ProxyOptions, log *logrus.Entry, srvOpts *server.Options,
 	logConf.TypeFieldKey = "couper_backend"
 	env.DecodeWithPrefix(&logConf, "BACKEND_")
 
+	if options.TLS != nil && options.TLS.InsecureSkipVerify {
+		log.WithField("backend", options.BackendName).Warn("certificate validation is disabled for upstream connections")
+	}
+
 	proxy := &Proxy{
 		bufferOption: eval.MustBuffer(options.Context),
 		evalContext:  evalCtx,
@@ -111,35 +115,54 @@ func NewProxy(options *ProxyOptions, log *logrus.Entry, srvOpts *server.Options,
 }
 
 func (p *Proxy) getTransport(scheme, origin, hostname string) *http.Transport {
-	key := scheme + "|" + origin + "|" + hostname
-	transport, ok := transports.Load(key)
-	if !ok {
+	pool := p.options.Pool
+	if pool == nil {
+		pool = &PoolOptions{}
+	}
+	// all transport relevant option values are part of the key, reloaded options must not reuse outdated transports
+	key := fmt.Sprintf("%s|%s|%s|%s|%s|%s|%d|%d|%s", scheme, origin, hostname, p.options.BackendName,
+		p.options.ConnectTimeout, p.options.TTFBTimeout, pool.MaxIdleConns, pool.MaxConnsPerHost, pool.IdleConnTimeout)
+	if p.options.TLS != nil {
+		// backend specific tls configurations must not share their connections
+		key += fmt.Sprintf("|%p", p.options.TLS)
+	}
+
+	transports := p.options.Transports
+	if transports == nil {
+		transports = defaultTransports
+	}
+
+	return transports.get(key, func() *http.Transport {
 		var tlsConf *tls.Config
+		if p.options.TLS != nil {
+			tlsConf = p.options.TLS.Clone()
+		}
 		if origin != hostname {
-			tlsConf = &tls.Config{
-				ServerName: hostname,
+			if tlsConf == nil {
+				tlsConf = &tls.Config{}
 			}
+			tlsConf.ServerName = hostname
 		}
 
 		d := &net.Dialer{Timeout: p.options.ConnectTimeout}
+		backendName := p.options.BackendName
 
-		transport = &http.Transport{
+		return &http.Transport{
 			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
 				conn, err := d.DialContext(ctx, network, addr)
 				if err != nil {
-					return nil, fmt.Errorf("connecting to %s %q failed: %w", p.options.BackendName, addr, err)
+					return nil, fmt.Errorf("connecting to %s %q failed: %w", backendName, addr, err)
 				}
 				return conn, nil
 			},
+			IdleConnTimeout:       pool.IdleConnTimeout,
+			MaxConnsPerHost:       pool.MaxConnsPerHost,
+			MaxIdleConns:          pool.MaxIdleConns,
+			MaxIdleConnsPerHost:   pool.MaxIdleConns,
 			ResponseHeaderTimeout: p.options.TTFBTimeout,
 			TLSClientConfig:       tlsConf,
 		}
-		transports.Store(key, transport)
-	}
-	if t, ok := transport.(*http.Transport); ok {
-		return t
-	}
-	return nil
+	})
 }
 
 func (p *Proxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -151,31 +174,81 @@ func (p *Proxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	*req = *req.Clone(context.WithValue(req.Context(), request.BackendName, p.options.BackendName))
-	p.upstreamLog.ServeHTTP(rw, req, logging.RoundtripHandlerFunc(p.roundtrip), startTime)
-}
-
-func (p *Proxy) roundtrip(rw http.ResponseWriter, req *http.Request) {
-	ctx := req.Context()
+	ctx := context.WithValue(req.Context(), request.BackendName, p.options.BackendName)
+	// the deadline includes all retry attempts
 	if p.options.Timeout > 0 {
-		deadline := time.Now().Add(p.options.Timeout)
-		c, cancelFn := context.WithDeadline(req.Context(), deadline)
+		c, cancelFn := context.WithDeadline(ctx, startTime.Add(p.options.Timeout))
 		ctx = c
 		defer cancelFn()
 	}
+	*req = *req.Clone(ctx)
+
+	for attempt := 1; ; attempt++ {
+		// each attempt gets its own upstream log entry and trace context
+		attemptReq := req.Clone(ctx)
+		var retry bool
+		p.upstreamLog.ServeHTTP(rw, attemptReq, logging.RoundtripHandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			retry = p.roundtrip(rw, r, attempt)
+		}), startTime)
+
+		if !retry {
+			return
+		}
+
+		// keep a buffered body for the next attempt
+		req.GetBody = attemptReq.GetBody
+
+		select {
+		case <-time.After(p.options.Retry.backoff(attempt)):
+		case <-ctx.Done():
+			return
+		}
+		startTime = time.Now()
+	}
+}
+
+// roundtrip sends one upstream request attempt and writes the response.
+// Returns true without writing a response if the attempt should be retried.
+func (p *Proxy) roundtrip(rw http.ResponseWriter, req *http.Request, attempt int) bool {
+	ctx, span := tracing.StartSpan(req.Context(), "backend "+p.options.BackendName, tracing.KindClient)
+	defer span.End()
+	if span != nil {
+		ctx = httptrace.WithClientTrace(ctx, span.ClientTrace())
+	}
 
 	outreq := req.Clone(ctx)
 	if req.ContentLength == 0 {
 		outreq.Body = nil // Issue 16036: nil Body for http.Transport retries
+	} else if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			p.srvOptions.APIErrTpl.ServeError(couperErr.APIError).ServeHTTP(rw, req)
+			return false
+		}
+		outreq.Body = body
 	}
 	if outreq.Header == nil {
 		outreq.Header = make(http.Header) // Issue 33142: historical behavior was to always allocate
 	}
 
 	err := p.Director(outreq)
+	// the origin is selected before further errors of the director
+	origin, _ := outreq.Context().Value(originContextKey{}).(*Origin)
+	if origin != nil {
+		defer origin.release()
+	}
 	if err != nil {
 		p.srvOptions.APIErrTpl.ServeError(err).ServeHTTP(rw, req)
-		return
+		return false
+	}
+
+	roundtripInfo := req.Context().Value(request.RoundtripInfo).(*logging.RoundtripInfo)
+	if p.options.Retry != nil {
+		roundtripInfo.Attempt = attempt
+	}
+
+	if origin != nil {
+		roundtripInfo.Origin, roundtripInfo.OriginWeight = origin.URL.String(), origin.Weight
 	}
 
 	outreq.Close = false
@@ -197,7 +270,11 @@ func (p *Proxy) roundtrip(rw http.ResponseWriter, req *http.Request) {
 		outreq.Header.Set("Upgrade", reqUpType)
 	}
 
-	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+	clientIP, _, err := net.SplitHostPort(req.RemoteAddr)
+	if ip, ok := req.Context().Value(request.ClientIP).(string); ok {
+		clientIP, err = ip, nil
+	}
+	if err == nil {
 		// If we aren't the first proxy retain prior
 		// X-Forwarded-For information as a comma+space
 		// separated list and fold multiple headers into one.
@@ -207,19 +284,47 @@ func (p *Proxy) roundtrip(rw http.ResponseWriter, req *http.Request) {
 		outreq.Header.Set("X-Forwarded-For", clientIP)
 	}
 
-	res, err := p.getTransport(outreq.URL.Scheme, outreq.URL.Host, outreq.Host).RoundTrip(outreq)
-	roundtripInfo := req.Context().Value(request.RoundtripInfo).(*logging.RoundtripInfo)
+	tracing.Inject(outreq.Header, span.SpanContext())
+	setClientSpan(span, outreq, p.options.BackendName, roundtripInfo.Attempt)
+
+	var res *http.Response
+	if p.options.Cache != nil {
+		res, err = p.options.Cache.RoundTrip(p.evalContext, req, outreq, p.send)
+	} else {
+		res, err = p.send(outreq)
+	}
+	if code, ok := err.(couperErr.Code); ok {
+		// concurrency limit or open circuit
+		p.srvOptions.APIErrTpl.ServeError(code).ServeHTTP(rw, req)
+		return false
+	}
 	roundtripInfo.BeReq, roundtripInfo.BeResp, roundtripInfo.Err = outreq, res, err
+	if err != nil {
+		span.SetStatus(tracing.StatusError, err.Error())
+	} else {
+		span.SetAttribute("http.status_code", res.StatusCode)
+		if res.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(res.StatusCode))
+		}
+	}
+
+	if p.options.Retry.shouldRetry(outreq, res, err, attempt) {
+		if res != nil {
+			res.Body.Close()
+		}
+		return true
+	}
+
 	if err != nil {
 		p.srvOptions.APIErrTpl.ServeError(couperErr.APIConnect).ServeHTTP(rw, req)
-		return
+		return false
 	}
 
 	// Deal with 101 Switching Protocols responses: (WebSocket, h2c, etc)
 	if res.StatusCode == http.StatusSwitchingProtocols {
 		p.SetRoundtripContext(req, res)
 		p.handleUpgradeResponse(rw, outreq, res)
-		return
+		return false
 	}
 
 	removeConnectionHeaders(res.Header)
@@ -249,7 +354,7 @@ func (p *Proxy) roundtrip(rw http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		defer res.Body.Close()
 		roundtripInfo.Err = err
-		return
+		return false
 	}
 
 	res.Body.Close() // close now, instead of defer, to populate res.Trailer
@@ -265,7 +370,7 @@ func (p *Proxy) roundtrip(rw http.ResponseWriter, req *http.Request) {
 
 	if len(res.Trailer) == announcedTrailers {
 		copyHeader(rw.Header(), res.Trailer)
-		return
+		return false
 	}
 
 	for k, vv := range res.Trailer {
@@ -274,6 +379,100 @@ func (p *Proxy) roundtrip(rw http.ResponseWriter, req *http.Request) {
 			rw.Header().Add(k, v)
 		}
 	}
+	return false
+}
+
+// RoundTrip sends the given request to the configured origin of the backend, e.g. for requests of an access control.
+// In contrast to ServeHTTP there is no upstream logging, retry or response cache. The response body must be closed.
+func (p *Proxy) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := context.WithValue(req.Context(), request.BackendName, p.options.BackendName)
+	cancel := func() {}
+	if p.options.Timeout > 0 {
+		var cancelFn context.CancelFunc
+		ctx, cancelFn = context.WithTimeout(ctx, p.options.Timeout)
+		cancel = cancelFn
+	}
+
+	outreq := req.Clone(ctx)
+	if outreq.Header == nil {
+		outreq.Header = make(http.Header)
+	}
+
+	err := p.Director(outreq)
+	release := cancel
+	// the origin is selected before further errors of the director
+	if origin, ok := outreq.Context().Value(originContextKey{}).(*Origin); ok {
+		release = func() {
+			origin.release()
+			cancel()
+		}
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	removeConnectionHeaders(outreq.Header)
+	removeHopHeaders(outreq.Header)
+
+	res, err := p.send(outreq)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	res.Body = releaseOnClose(res.Body, release)
+	return res, nil
+}
+
+// send forwards the given request within the concurrency limit and circuit breaker of the backend.
+// The concurrency slot is released with the response body.
+func (p *Proxy) send(outreq *http.Request) (*http.Response, error) {
+	limit := p.options.ConcurrencyLimit
+	if limit != nil {
+		if err := limit.Acquire(outreq.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.options.CircuitBreaker != nil {
+		if err := p.options.CircuitBreaker.Allow(); err != nil {
+			if limit != nil {
+				limit.Release()
+			}
+			return nil, err
+		}
+	}
+
+	res, err := p.getTransport(outreq.URL.Scheme, outreq.URL.Host, outreq.Host).RoundTrip(outreq)
+	if cb := p.options.CircuitBreaker; cb != nil {
+		if err != nil && outreq.Context().Err() == context.Canceled {
+			// canceled client requests are neither an upstream failure nor a success
+			cb.Release()
+		} else {
+			cb.Report(err != nil || res.StatusCode >= http.StatusInternalServerError)
+		}
+	}
+
+	if limit != nil {
+		if err != nil {
+			limit.Release()
+		} else {
+			res.Body = releaseOnClose(res.Body, limit.Release)
+		}
+	}
+	return res, err
+}
+
+func setClientSpan(span *tracing.Span, outreq *http.Request, backendName string, attempt int) {
+	if span == nil {
+		return
+	}
+	span.SetAttribute("couper.backend", backendName)
+	if attempt > 0 {
+		span.SetAttribute("couper.attempt", attempt)
+	}
+	span.SetAttribute("http.method", outreq.Method)
+	span.SetAttribute("http.url", outreq.URL.String())
 }
 
 // Director request modification before roundtrip
@@ -294,9 +493,20 @@ func (p *Proxy) Director(req *http.Request) error {
 		}
 	}
 
-	originURL, err := url.Parse(origin)
-	if err != nil {
-		return err
+	var originURL *url.URL
+	if p.options.LoadBalancer != nil {
+		selected := p.options.LoadBalancer.Next(evalContext)
+		if selected == nil {
+			return couperErr.APIUnhealthyOrigin
+		}
+		*req = *req.WithContext(context.WithValue(req.Context(), originContextKey{}, selected))
+		originURL = selected.URL
+	} else {
+		u, err := url.Parse(origin)
+		if err != nil {
+			return err
+		}
+		originURL = u
 	}
 
 	req.URL.Host = originURL.Host
@@ -377,10 +587,19 @@ func (p *Proxy) SetGetBody(req *http.Request) error {
 		return nil
 	}
 
-	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
-		buf := &bytes.Buffer{}
-		lr := io.LimitReader(req.Body, p.options.RequestBodyLimit+1)
-		n, err := buf.ReadFrom(lr)
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	// a body buffered before, e.g. by a token source of an access control, is subject to the limit too
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		n, err := io.Copy(ioutil.Discard, io.LimitReader(body, p.options.RequestBodyLimit+1))
 		if err != nil {
 			return err
 		}
@@ -388,11 +607,23 @@ func (p *Proxy) SetGetBody(req *http.Request) error {
 		if n > p.options.RequestBodyLimit {
 			return couperErr.APIReqBodySizeExceeded
 		}
+		return nil
+	}
 
-		bodyBytes := buf.Bytes()
-		req.GetBody = func() (io.ReadCloser, error) {
-			return eval.NewReadCloser(bytes.NewBuffer(bodyBytes), req.Body), nil
-		}
+	buf := &bytes.Buffer{}
+	lr := io.LimitReader(req.Body, p.options.RequestBodyLimit+1)
+	n, err := buf.ReadFrom(lr)
+	if err != nil {
+		return err
+	}
+
+	if n > p.options.RequestBodyLimit {
+		return couperErr.APIReqBodySizeExceeded
+	}
+
+	bodyBytes := buf.Bytes()
+	req.GetBody = func() (io.ReadCloser, error) {
+		return eval.NewReadCloser(bytes.NewBuffer(bodyBytes), req.Body), nil
 	}
 
 	return nil
@@ -1,35 +1,61 @@
 package handler
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"time"
 
 	"github.com/docker/go-units"
 	"github.com/hashicorp/hcl/v2"
+	"github.com/sirupsen/logrus"
 
 	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/eval"
+	"github.com/avenga/couper/internal/seetie"
+	"github.com/avenga/couper/utils"
 )
 
 type ProxyOptions struct {
 	ConnectTimeout, Timeout, TTFBTimeout time.Duration
 	Context                              []hcl.Body
 	BackendName                          string
+	Cache                                *ResponseCache
+	CircuitBreaker                       *CircuitBreaker
+	ConcurrencyLimit                     *ConcurrencyLimit
 	CORS                                 *CORSOptions
+	Health                               *BackendHealth
+	LoadBalancer                         *LoadBalancer
+	Pool                                 *PoolOptions
 	RequestBodyLimit                     int64
+	Retry                                *RetryOptions
+	// TLS is the base configuration for upstream connections, nil for defaults.
+	TLS *tls.Config
+	// Transports of the server configuration, nil for the default transports.
+	Transports *Transports
 }
 
-func NewProxyOptions(conf *config.Backend, corsOpts *CORSOptions, remainCtx []hcl.Body) (*ProxyOptions, error) {
+// PoolOptions configures the connection pool of the upstream transport, zero values keep the transport defaults.
+// Transports are created per origin, so MaxIdleConns applies to the idle connections per host too.
+type PoolOptions struct {
+	IdleConnTimeout time.Duration
+	MaxConnsPerHost int
+	MaxIdleConns    int
+}
+
+func NewProxyOptions(conf *config.Backend, corsOpts *CORSOptions, remainCtx []hcl.Body, log *logrus.Entry) (*ProxyOptions, error) {
 	totalD, err := time.ParseDuration(conf.Timeout)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("backend %q: timeout: %v", conf.Name, err)
 	}
 	ttfbD, err := time.ParseDuration(conf.TTFBTimeout)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("backend %q: ttfb_timeout: %v", conf.Name, err)
 	}
 	connectD, err := time.ParseDuration(conf.ConnectTimeout)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("backend %q: connect_timeout: %v", conf.Name, err)
 	}
 
 	bodyLimit, err := units.FromHumanSize(conf.RequestBodyLimit)
@@ -42,17 +68,168 @@ func NewProxyOptions(conf *config.Backend, corsOpts *CORSOptions, remainCtx []hc
 		cors = &CORSOptions{}
 	}
 
+	tlsConf, err := newBackendTLSConfig(conf)
+	if err != nil {
+		return nil, fmt.Errorf("backend %q: %v", conf.Name, err)
+	}
+
+	cache, err := NewResponseCache(conf.Cache, totalD, log)
+	if err != nil {
+		return nil, fmt.Errorf("backend %q: %v", conf.Name, err)
+	}
+
+	circuitBreaker, err := NewCircuitBreaker(conf.Name, conf.CircuitBreaker, log)
+	if err != nil {
+		return nil, fmt.Errorf("backend %q: %v", conf.Name, err)
+	}
+
+	concurrencyLimit, err := NewConcurrencyLimit(conf)
+	if err != nil {
+		return nil, fmt.Errorf("backend %q: %v", conf.Name, err)
+	}
+
+	pool, err := newPoolOptions(conf)
+	if err != nil {
+		return nil, fmt.Errorf("backend %q: %v", conf.Name, err)
+	}
+
+	retry, err := NewRetryOptions(conf)
+	if err != nil {
+		return nil, fmt.Errorf("backend %q: %v", conf.Name, err)
+	}
+
+	lbConf := conf.LoadBalancer
+	if lbConf == nil && conf.Health != nil {
+		// health checks require the origin to be part of a load balancer
+		origin, err := staticOrigin(conf.Options)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: health: %v", conf.Name, err)
+		}
+		lbConf = &config.LoadBalancer{Origins: []*config.LoadBalancerOrigin{{URL: origin}}}
+	}
+
+	loadBalancer, err := NewLoadBalancer(lbConf)
+	if err != nil {
+		return nil, fmt.Errorf("backend %q: %v", conf.Name, err)
+	}
+
+	var health *BackendHealth
+	if conf.Health != nil {
+		health, err = NewBackendHealth(conf.Name, conf.Health, loadBalancer, tlsConf)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %v", conf.Name, err)
+		}
+	}
+
 	return &ProxyOptions{
 		BackendName:      conf.Name,
+		Cache:            cache,
+		CircuitBreaker:   circuitBreaker,
+		ConcurrencyLimit: concurrencyLimit,
 		CORS:             cors,
 		ConnectTimeout:   connectD,
 		Context:          remainCtx,
+		Health:           health,
+		LoadBalancer:     loadBalancer,
+		Pool:             pool,
 		RequestBodyLimit: bodyLimit,
+		Retry:            retry,
+		TLS:              tlsConf,
 		TTFBTimeout:      ttfbD,
 		Timeout:          totalD,
 	}, nil
 }
 
+func newPoolOptions(conf *config.Backend) (*PoolOptions, error) {
+	if conf.MaxConnectionsPerHost < 0 || conf.MaxIdleConnections < 0 {
+		return nil, fmt.Errorf("max_connections_per_host and max_idle_connections must be positive")
+	}
+
+	pool := &PoolOptions{
+		MaxConnsPerHost: conf.MaxConnectionsPerHost,
+		MaxIdleConns:    conf.MaxIdleConnections,
+	}
+
+	if conf.IdleConnectionTimeout != "" {
+		d, err := time.ParseDuration(conf.IdleConnectionTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("idle_connection_timeout: %v", err)
+		}
+		pool.IdleConnTimeout = d
+	}
+	return pool, nil
+}
+
+// staticOrigin evaluates the origin attribute of the given backend body
+// which must not depend on request related variables.
+func staticOrigin(body hcl.Body) (string, error) {
+	if body == nil {
+		return "", fmt.Errorf("missing origin")
+	}
+
+	content, _, _ := body.PartialContent(config.Backend{}.Schema(true))
+	attr, ok := content.Attributes["origin"]
+	if !ok {
+		return "", fmt.Errorf("missing origin")
+	}
+
+	val, diags := attr.Expr.Value(eval.NewENVContext(nil))
+	if diags.HasErrors() {
+		return "", fmt.Errorf("origin must be a static value: %v", diags)
+	}
+	return seetie.ValueToString(val), nil
+}
+
+// newBackendTLSConfig creates the tls configuration for upstream connections.
+// Returns nil if the backend has no tls related configuration.
+func newBackendTLSConfig(conf *config.Backend) (*tls.Config, error) {
+	insecure := conf.DisableCertificateValidation != nil && *conf.DisableCertificateValidation
+	if conf.CAFile == "" && conf.ClientCertFile == "" && conf.ClientKeyFile == "" &&
+		conf.MinTLSVersion == "" && !insecure {
+		return nil, nil
+	}
+
+	minVersion, err := utils.ParseTLSVersion(conf.MinTLSVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: insecure,
+		MinVersion:         minVersion,
+	}
+
+	if conf.CAFile != "" {
+		caBundle, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		// the configured ca extends the system pool
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("ca_file: no PEM encoded certificate found: %q", conf.CAFile)
+		}
+		tlsConf.RootCAs = rootCAs
+	}
+
+	if conf.ClientCertFile != "" || conf.ClientKeyFile != "" {
+		if conf.ClientCertFile == "" || conf.ClientKeyFile == "" {
+			return nil, fmt.Errorf("client_cert_file and client_key_file must be configured together")
+		}
+		cert, err := tls.LoadX509KeyPair(conf.ClientCertFile, conf.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConf, nil
+}
+
 func (po *ProxyOptions) Merge(o *ProxyOptions) *ProxyOptions {
 	if o.ConnectTimeout > 0 {
 		po.ConnectTimeout = o.ConnectTimeout
@@ -78,5 +255,41 @@ func (po *ProxyOptions) Merge(o *ProxyOptions) *ProxyOptions {
 		po.RequestBodyLimit = o.RequestBodyLimit
 	}
 
+	if o.Cache != nil {
+		po.Cache = o.Cache
+	}
+
+	if o.CircuitBreaker != nil {
+		po.CircuitBreaker = o.CircuitBreaker
+	}
+
+	if o.ConcurrencyLimit != nil {
+		po.ConcurrencyLimit = o.ConcurrencyLimit
+	}
+
+	if o.Health != nil {
+		po.Health = o.Health
+	}
+
+	if o.LoadBalancer != nil {
+		po.LoadBalancer = o.LoadBalancer
+	}
+
+	if o.Pool != nil {
+		po.Pool = o.Pool
+	}
+
+	if o.Retry != nil {
+		po.Retry = o.Retry
+	}
+
+	if o.TLS != nil {
+		po.TLS = o.TLS
+	}
+
+	if o.Transports != nil {
+		po.Transports = o.Transports
+	}
+
 	return po
 }
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/avenga/couper/config"
+)
+
+const (
+	RetryOnConnect = "connect"
+	RetryOnTimeout = "timeout"
+
+	defaultRetryBackoff = time.Millisecond * 100
+)
+
+// RetryOptions decides whether a failed upstream request attempt gets repeated.
+type RetryOptions struct {
+	Backoff     time.Duration
+	Connect     bool
+	Retries     int
+	StatusCodes map[int]bool
+	Timeout     bool
+}
+
+// NewRetryOptions validates the retry related backend attributes.
+// Returns nil if retries are not configured.
+func NewRetryOptions(conf *config.Backend) (*RetryOptions, error) {
+	if conf.Retries < 0 {
+		return nil, fmt.Errorf("retries must be positive")
+	}
+	if conf.Retries == 0 {
+		return nil, nil
+	}
+
+	ro := &RetryOptions{
+		Backoff:     defaultRetryBackoff,
+		Retries:     conf.Retries,
+		StatusCodes: make(map[int]bool),
+	}
+
+	if conf.RetryBackoff != "" {
+		d, err := time.ParseDuration(conf.RetryBackoff)
+		if err != nil {
+			return nil, fmt.Errorf("retry_backoff: %v", err)
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("retry_backoff must be positive")
+		}
+		ro.Backoff = d
+	}
+
+	retryOn := conf.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = []string{RetryOnConnect}
+	}
+
+	for _, r := range retryOn {
+		switch r {
+		case RetryOnConnect:
+			ro.Connect = true
+		case RetryOnTimeout:
+			ro.Timeout = true
+		default:
+			code, err := strconv.Atoi(r)
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("retry_on: invalid value: %q", r)
+			}
+			ro.StatusCodes[code] = true
+		}
+	}
+
+	return ro, nil
+}
+
+// backoff returns the exponential delay after the given attempt.
+func (ro *RetryOptions) backoff(attempt int) time.Duration {
+	if ro == nil || attempt < 1 {
+		return 0
+	}
+	return ro.Backoff * time.Duration(1<<uint(attempt-1))
+}
+
+// shouldRetry reports whether the given attempt result must be repeated.
+// Only idempotent requests or requests with a buffered body are retried if the
+// remaining time until the request deadline covers the backoff delay.
+func (ro *RetryOptions) shouldRetry(req *http.Request, res *http.Response, err error, attempt int) bool {
+	if ro == nil || attempt > ro.Retries {
+		return false
+	}
+
+	if !ro.matches(res, err) || !isRetryable(req) {
+		return false
+	}
+
+	ctx := req.Context()
+	if ctx.Err() == context.Canceled {
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= ro.backoff(attempt) {
+		return false
+	}
+	return true
+}
+
+func (ro *RetryOptions) matches(res *http.Response, err error) bool {
+	if err == nil {
+		return res != nil && ro.StatusCodes[res.StatusCode]
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ro.Connect
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ro.Timeout
+	}
+	return false
+}
+
+// isRetryable reports whether the request can be sent again.
+func isRetryable(req *http.Request) bool {
+	if req.GetBody != nil {
+		return true
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/config/runtime/server"
+	"github.com/avenga/couper/errors"
+	"github.com/avenga/couper/eval"
+	"github.com/avenga/couper/handler"
+	"github.com/avenga/couper/internal/test"
+)
+
+func TestProxy_ServeHTTP_Retries(t *testing.T) {
+	var hits int32
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// fails twice, succeeds on the third request
+		if atomic.AddInt32(&hits, 1)%3 != 0 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	type testCase struct {
+		name         string
+		originURL    string
+		backend      *config.Backend
+		req          *http.Request
+		wantStatus   int
+		wantAttempts int
+	}
+
+	for _, tc := range []testCase{
+		{"retry on status", origin.URL, &config.Backend{Retries: 2, RetryOn: []string{"503"}},
+			httptest.NewRequest(http.MethodGet, "http://couper.io/", nil), http.StatusNoContent, 3},
+		{"retry budget", origin.URL, &config.Backend{Retries: 1, RetryOn: []string{"503"}},
+			httptest.NewRequest(http.MethodGet, "http://couper.io/", nil), http.StatusServiceUnavailable, 2},
+		{"status not configured", origin.URL, &config.Backend{Retries: 2},
+			httptest.NewRequest(http.MethodGet, "http://couper.io/", nil), http.StatusServiceUnavailable, 1},
+		{"unbuffered body", origin.URL, &config.Backend{Retries: 2, RetryOn: []string{"503"}},
+			httptest.NewRequest(http.MethodPost, "http://couper.io/", strings.NewReader("data")), http.StatusServiceUnavailable, 1},
+		{"timeout budget", origin.URL, &config.Backend{Retries: 2, RetryOn: []string{"503"}, RetryBackoff: "1m"},
+			httptest.NewRequest(http.MethodGet, "http://couper.io/", nil), http.StatusServiceUnavailable, 1},
+		{"retry on connect", unreachable.URL, &config.Backend{Retries: 2, RetryBackoff: "1ms"},
+			httptest.NewRequest(http.MethodGet, "http://couper.io/", nil), http.StatusBadGateway, 3},
+	} {
+		t.Run(tc.name, func(subT *testing.T) {
+			atomic.StoreInt32(&hits, 0)
+
+			tc.backend.ConnectTimeout = "1s"
+			tc.backend.Timeout = "10s"
+			tc.backend.TTFBTimeout = "1s"
+			tc.backend.RequestBodyLimit = "64MiB"
+
+			opts, err := handler.NewProxyOptions(tc.backend, nil, test.NewRemainContext("origin", tc.originURL), nil)
+			if err != nil {
+				subT.Fatal(err)
+			}
+
+			logger, hook := logrustest.NewNullLogger()
+			srvOpts := &server.Options{APIErrTpl: errors.DefaultJSON}
+			proxy, err := handler.NewProxy(opts, logger.WithContext(context.Background()), srvOpts, eval.NewENVContext(nil))
+			if err != nil {
+				subT.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, tc.req)
+
+			if rec.Code != tc.wantStatus {
+				subT.Errorf("Expected status %d, got: %d", tc.wantStatus, rec.Code)
+			}
+
+			entries := hook.AllEntries()
+			if len(entries) != tc.wantAttempts {
+				subT.Fatalf("Expected %d upstream log entries, got: %d", tc.wantAttempts, len(entries))
+			}
+
+			for i, entry := range entries {
+				if attempt, ok := entry.Data["attempt"]; ok && attempt != i+1 {
+					subT.Errorf("Expected attempt %d, got: %v", i+1, attempt)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRetryOptions(t *testing.T) {
+	ro, err := handler.NewRetryOptions(&config.Backend{})
+	if err != nil || ro != nil {
+		t.Errorf("Expected no retry options, got: %v, %v", ro, err)
+	}
+
+	ro, err = handler.NewRetryOptions(&config.Backend{Retries: 1, RetryOn: []string{"timeout", "502"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ro.Connect || !ro.Timeout || !ro.StatusCodes[502] {
+		t.Errorf("Unexpected retry options: %#v", ro)
+	}
+
+	for _, b := range []*config.Backend{
+		{Retries: -1},
+		{Retries: 1, RetryOn: []string{"dns"}},
+		{Retries: 1, RetryOn: []string{"99"}},
+		{Retries: 1, RetryBackoff: "soon"},
+	} {
+		if _, err = handler.NewRetryOptions(b); err == nil {
+			t.Errorf("Expected an error for %#v", b)
+		}
+	}
+}
@@ -3,11 +3,17 @@ package handler_test
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -16,7 +22,9 @@ import (
 	"github.com/sirupsen/logrus"
 	logrustest "github.com/sirupsen/logrus/hooks/test"
 
+	"github.com/avenga/couper/config"
 	"github.com/avenga/couper/config/request"
+	"github.com/avenga/couper/config/runtime/server"
 	"github.com/avenga/couper/errors"
 	"github.com/avenga/couper/eval"
 	"github.com/avenga/couper/handler"
@@ -572,16 +580,19 @@ func TestProxy_SetGetBody_LimitBody_Roundtrip(t *testing.T) {
 	helper := test.New(t)
 
 	type testCase struct {
-		name    string
-		limit   int64
-		payload string
-		wantErr error
+		name     string
+		limit    int64
+		payload  string
+		buffered bool
+		wantErr  error
 	}
 
 	for _, testcase := range []testCase{
-		{"/w well sized limit", 12, "content", nil},
-		{"/w zero limit", 0, "01", errors.APIReqBodySizeExceeded},
-		{"/w limit /w oversize body", 4, "12345", errors.APIReqBodySizeExceeded},
+		{"/w well sized limit", 12, "content", false, nil},
+		{"/w zero limit", 0, "01", false, errors.APIReqBodySizeExceeded},
+		{"/w limit /w oversize body", 4, "12345", false, errors.APIReqBodySizeExceeded},
+		{"/w buffered body", 12, "content", true, nil},
+		{"/w limit /w oversize buffered body", 4, "12345", true, errors.APIReqBodySizeExceeded},
 	} {
 		t.Run(testcase.name, func(subT *testing.T) {
 			proxy, _, _, closeFn := helper.NewProxy(&handler.ProxyOptions{
@@ -591,6 +602,11 @@ func TestProxy_SetGetBody_LimitBody_Roundtrip(t *testing.T) {
 			closeFn() // unused
 
 			req := httptest.NewRequest(http.MethodPut, "/", bytes.NewBufferString(testcase.payload))
+			if testcase.buffered {
+				req.GetBody = func() (io.ReadCloser, error) {
+					return ioutil.NopCloser(strings.NewReader(testcase.payload)), nil
+				}
+			}
 			err := proxy.SetGetBody(req)
 			if !reflect.DeepEqual(err, testcase.wantErr) {
 				subT.Errorf("Expected '%v', got: '%v'", testcase.wantErr, err)
@@ -599,6 +615,34 @@ func TestProxy_SetGetBody_LimitBody_Roundtrip(t *testing.T) {
 	}
 }
 
+func TestProxy_ServeHTTP_ReleaseOrigin(t *testing.T) {
+	helper := test.New(t)
+
+	lb, err := handler.NewLoadBalancer(newLoadBalancerConf(handler.StrategyLeastConnections, "", 1, 1))
+	helper.Must(err)
+
+	logger, _ := logrustest.NewNullLogger()
+	proxy, err := handler.NewProxy(&handler.ProxyOptions{
+		BackendName:      "lb",
+		Context:          helper.NewProxyContext("request_headers = { x = req.post }"), // ensure buffering is enabled
+		LoadBalancer:     lb,
+		RequestBodyLimit: 4,
+	}, logger.WithContext(context.Background()), &server.Options{APIErrTpl: errors.DefaultJSON}, eval.NewENVContext(nil))
+	helper.Must(err)
+
+	// the body limit fails after the origin selection
+	rec := httptest.NewRecorder()
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "http://couper.io/", strings.NewReader("12345")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status %d, got: %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+
+	// a released origin is selected again by the least connections strategy
+	if host := lb.Next(eval.NewENVContext(nil)).URL.Host; host != "a.couper.io" {
+		t.Errorf("Expected the released origin a.couper.io, got: %q", host)
+	}
+}
+
 func Test_IsCredentialed(t *testing.T) {
 	type testCase struct {
 		name           string
@@ -721,3 +765,193 @@ func TestProxy_setRoundtripContext_Variables_json_body(t *testing.T) {
 		}
 	}
 }
+
+func TestProxy_ServeHTTP_BackendTLS(t *testing.T) {
+	helper := test.New(t)
+
+	ca := helper.NewCA("couper test ca")
+	serverCert := helper.NewCertificate(ca, "origin", "127.0.0.1")
+	clientCert := helper.NewCertificate(ca, "couper", "couper.io")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Cert)
+
+	origin := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Client-CN", req.TLS.PeerCertificates[0].Subject.CommonName)
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	origin.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert.TLSCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	origin.StartTLS()
+	defer origin.Close()
+
+	dir, err := ioutil.TempDir("", "couper-backend-tls")
+	helper.Must(err)
+	defer os.RemoveAll(dir)
+
+	caFile, _ := helper.WriteFiles(ca, dir, "ca")
+	clientCertFile, clientKeyFile := helper.WriteFiles(clientCert, dir, "client")
+	insecure, secure := true, false
+
+	type testCase struct {
+		name       string
+		backend    *config.Backend
+		wantStatus int
+		wantCN     string
+	}
+
+	for _, tc := range []testCase{
+		{"unknown authority", &config.Backend{}, http.StatusBadGateway, ""},
+		{"missing client certificate", &config.Backend{CAFile: caFile}, http.StatusBadGateway, ""},
+		{"with ca and client certificate", &config.Backend{
+			CAFile: caFile, ClientCertFile: clientCertFile, ClientKeyFile: clientKeyFile,
+		}, http.StatusNoContent, "couper"},
+		{"without certificate validation", &config.Backend{
+			ClientCertFile: clientCertFile, ClientKeyFile: clientKeyFile, DisableCertificateValidation: &insecure,
+		}, http.StatusNoContent, "couper"},
+		{"with explicit certificate validation", &config.Backend{
+			ClientCertFile: clientCertFile, ClientKeyFile: clientKeyFile, DisableCertificateValidation: &secure,
+		}, http.StatusBadGateway, ""},
+	} {
+		t.Run(tc.name, func(subT *testing.T) {
+			tc.backend.Name = "tls"
+			tc.backend.ConnectTimeout = "10s"
+			tc.backend.Timeout = "30s"
+			tc.backend.TTFBTimeout = "30s"
+			tc.backend.RequestBodyLimit = "64MiB"
+
+			opts, err := handler.NewProxyOptions(tc.backend, nil, test.NewRemainContext("origin", origin.URL), nil)
+			if err != nil {
+				subT.Fatal(err)
+			}
+
+			logger, _ := logrustest.NewNullLogger()
+			srvOpts := &server.Options{APIErrTpl: errors.DefaultJSON}
+			proxy, err := handler.NewProxy(opts, logger.WithContext(context.Background()), srvOpts, eval.NewENVContext(nil))
+			if err != nil {
+				subT.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://couper.io/", nil))
+
+			if rec.Code != tc.wantStatus {
+				subT.Errorf("Expected status %d, got: %d", tc.wantStatus, rec.Code)
+			}
+
+			if cn := rec.Header().Get("X-Client-CN"); cn != tc.wantCN {
+				subT.Errorf("Expected client common name %q, got: %q", tc.wantCN, cn)
+			}
+		})
+	}
+}
+
+func TestProxy_ServeHTTP_Transports(t *testing.T) {
+	var mu sync.Mutex
+	var newConns, closedConns int
+	origin := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	origin.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch state {
+		case http.StateNew:
+			newConns++
+		case http.StateClosed:
+			closedConns++
+		}
+	}
+	origin.Start()
+	defer origin.Close()
+
+	counts := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return newConns, closedConns
+	}
+
+	logger, _ := logrustest.NewNullLogger()
+	serve := func(transports *handler.Transports, connectTimeout time.Duration) {
+		proxy, err := handler.NewProxy(&handler.ProxyOptions{
+			ConnectTimeout: connectTimeout,
+			Context:        test.NewRemainContext("origin", origin.URL),
+			Transports:     transports,
+		}, logger.WithContext(context.Background()), nil, eval.NewENVContext(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://couper.io/", nil))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("Expected status %d, got: %d", http.StatusNoContent, rec.Code)
+		}
+	}
+
+	current, replaced := handler.NewTransports(), handler.NewTransports()
+
+	serve(replaced, time.Second)
+	serve(replaced, time.Second)
+	if n, _ := counts(); n != 1 {
+		t.Errorf("Expected equal options to share their connection, got %d connections", n)
+	}
+
+	serve(replaced, time.Second*2)
+	if n, _ := counts(); n != 2 {
+		t.Errorf("Expected changed options to use a new connection, got %d connections", n)
+	}
+
+	serve(current, time.Second)
+	if n, _ := counts(); n != 3 {
+		t.Errorf("Expected another configuration to use a new connection, got %d connections", n)
+	}
+
+	replaced.CloseIdleConnections()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, closed := counts(); closed == 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if _, closed := counts(); closed != 2 {
+		t.Errorf("Expected the connections of the replaced transports to be closed, got %d closed connections", closed)
+	}
+}
+
+func TestNewProxyOptions_TLS(t *testing.T) {
+	newBackend := func(b *config.Backend) *config.Backend {
+		b.ConnectTimeout, b.Timeout, b.TTFBTimeout, b.RequestBodyLimit = "1s", "1s", "1s", "64MiB"
+		return b
+	}
+
+	opts, err := handler.NewProxyOptions(newBackend(&config.Backend{}), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.TLS != nil {
+		t.Errorf("Expected no tls configuration, got: %v", opts.TLS)
+	}
+
+	opts, err = handler.NewProxyOptions(newBackend(&config.Backend{MinTLSVersion: "TLS1.2"}), nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.TLS == nil || opts.TLS.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected min tls version 1.2, got: %v", opts.TLS)
+	}
+
+	for _, b := range []*config.Backend{
+		{MinTLSVersion: "SSL3"},
+		{CAFile: "./not-there.pem"},
+		{ClientCertFile: "client.crt"},
+	} {
+		if _, err = handler.NewProxyOptions(newBackend(b), nil, nil, nil); err == nil {
+			t.Errorf("Expected an error for %#v", b)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+
+	ac "github.com/avenga/couper/accesscontrol"
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/config/request"
+	"github.com/avenga/couper/errors"
+	"github.com/avenga/couper/eval"
+)
+
+const (
+	rateLimitSlidingWindow = "sliding_window"
+	rateLimitTokenBucket   = "token_bucket"
+)
+
+var (
+	_ http.Handler         = &RateLimit{}
+	_ errors.ErrorTemplate = &RateLimit{}
+	_ ac.ProtectedHandler  = &RateLimit{}
+)
+
+// RateLimiter limits the requests per period of the keys evaluated from the configured key expression.
+// Requests are limited per client ip if no key expression is configured or its value is empty.
+type RateLimiter struct {
+	key   hcl.Expression
+	limit int
+	store *rateLimitStore
+	// usesContext is set for keys which reference the access control data, e.g. req.ctx.myjwt.sub
+	usesContext bool
+}
+
+// NewRateLimiter validates the given rate_limit configuration and creates a RateLimiter.
+func NewRateLimiter(conf *config.RateLimit) (*RateLimiter, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	algorithm := conf.Algorithm
+	switch algorithm {
+	case "":
+		algorithm = rateLimitTokenBucket
+	case rateLimitSlidingWindow, rateLimitTokenBucket:
+	default:
+		return nil, fmt.Errorf("rate_limit: unsupported algorithm: %q", algorithm)
+	}
+
+	period, err := time.ParseDuration(conf.Period)
+	if err != nil {
+		return nil, fmt.Errorf("rate_limit: period: %v", err)
+	}
+	if period <= 0 || conf.PerPeriod <= 0 {
+		return nil, fmt.Errorf("rate_limit: period and per_period must be positive")
+	}
+
+	var usesContext bool
+	if conf.Key != nil {
+		for _, traversal := range conf.Key.Variables() {
+			if len(traversal) < 2 || traversal.RootName() != eval.ClientRequest {
+				continue
+			}
+			if attr, ok := traversal[1].(hcl.TraverseAttr); ok && attr.Name == eval.Context {
+				usesContext = true
+			}
+		}
+	}
+
+	return &RateLimiter{
+		key:         conf.Key,
+		limit:       conf.PerPeriod,
+		store:       newRateLimitStore(algorithm, conf.PerPeriod, period),
+		usesContext: usesContext,
+	}, nil
+}
+
+// UsesAccessControl reports whether the key references access control data. Such limits are checked
+// after the access controls, all others before, so unauthorized requests are limited too.
+func (rl *RateLimiter) UsesAccessControl() bool {
+	return rl != nil && rl.usesContext
+}
+
+// RateLimit rejects requests with the RateLimitExceeded error if the limit of their key is exceeded.
+type RateLimit struct {
+	errorTpl  *errors.Template
+	evalCtx   *hcl.EvalContext
+	limiter   *RateLimiter
+	log       logrus.FieldLogger
+	protected http.Handler
+}
+
+func NewRateLimit(protected http.Handler, limiter *RateLimiter, errTpl *errors.Template, evalCtx *hcl.EvalContext, log logrus.FieldLogger) *RateLimit {
+	return &RateLimit{
+		errorTpl:  errTpl,
+		evalCtx:   evalCtx,
+		limiter:   limiter,
+		log:       log,
+		protected: protected,
+	}
+}
+
+func (r *RateLimit) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	result := r.limiter.store.take(r.key(req), time.Now())
+
+	rw.Header().Set("RateLimit-Limit", strconv.Itoa(r.limiter.limit))
+	rw.Header().Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+	rw.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+
+	if !result.allowed {
+		rw.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.retryAfter)))
+		r.errorTpl.ServeError(errors.RateLimitExceeded).ServeHTTP(rw, req)
+		return
+	}
+
+	r.protected.ServeHTTP(rw, req)
+}
+
+// key evaluates the key expression, the client ip is used for empty or invalid values.
+func (r *RateLimit) key(req *http.Request) string {
+	if r.limiter.key != nil {
+		val, diags := r.limiter.key.Value(eval.NewHTTPContext(r.evalCtx, eval.BufferNone, req, nil, nil))
+		if diags.HasErrors() {
+			r.log.WithField("rate_limit", r.limiter.key.Range().String()).Error(diags.Error())
+		} else if val.IsKnown() && !val.IsNull() {
+			str, err := convert.Convert(val, cty.String)
+			if err != nil {
+				r.log.WithField("rate_limit", r.limiter.key.Range().String()).
+					Errorf("key must evaluate to a string value, got: %s", val.Type().FriendlyName())
+			} else if key := str.AsString(); key != "" {
+				return "key:" + key
+			}
+		}
+	}
+
+	if clientIP, ok := req.Context().Value(request.ClientIP).(string); ok {
+		return "ip:" + clientIP
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func (r *RateLimit) Child() http.Handler {
+	return r.protected
+}
+
+func (r *RateLimit) Template() *errors.Template {
+	return r.errorTpl
+}
+
+func (r *RateLimit) String() string {
+	if h, ok := r.protected.(interface{ String() string }); ok {
+		return h.String()
+	}
+	return "RateLimit"
+}
+
+// ceilSeconds returns the given duration in whole seconds, at least one second.
+func ceilSeconds(d time.Duration) int {
+	if s := int(math.Ceil(d.Seconds())); s > 0 {
+		return s
+	}
+	return 1
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"container/list"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitShards splits the store to reduce the lock contention of concurrent requests.
+	rateLimitShards = 32
+	// maxRateLimitShardEntries limits the entries per shard, the least recently seen ones are evicted.
+	maxRateLimitShardEntries = 4096
+)
+
+// rateLimitResult describes the state of a key after a rate limit decision.
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int
+	reset      time.Duration
+	retryAfter time.Duration
+}
+
+// rateLimitEntry holds the algorithm state of a single key.
+type rateLimitEntry struct {
+	key      string
+	lastSeen time.Time
+	// token bucket
+	tokens float64
+	// sliding window
+	current     int
+	previous    int
+	windowStart time.Time
+}
+
+// rateLimitShard keeps its entries in the order of their last request.
+type rateLimitShard struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// rateLimitStore is an in-memory store of the rate limit states, sharded by the key hash.
+type rateLimitStore struct {
+	algorithm string
+	limit     int
+	period    time.Duration
+	shards    [rateLimitShards]*rateLimitShard
+}
+
+func newRateLimitStore(algorithm string, limit int, period time.Duration) *rateLimitStore {
+	store := &rateLimitStore{
+		algorithm: algorithm,
+		limit:     limit,
+		period:    period,
+	}
+	for i := range store.shards {
+		store.shards[i] = &rateLimitShard{entries: make(map[string]*list.Element), lru: list.New()}
+	}
+	return store
+}
+
+// take consumes one request of the given key.
+func (s *rateLimitStore) take(key string, now time.Time) rateLimitResult {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	var entry *rateLimitEntry
+	if elem, exist := shard.entries[key]; exist {
+		entry = elem.Value.(*rateLimitEntry)
+		shard.lru.MoveToFront(elem)
+	} else {
+		s.cleanup(shard, now)
+		entry = &rateLimitEntry{key: key, tokens: float64(s.limit), windowStart: now}
+		shard.entries[key] = shard.lru.PushFront(entry)
+	}
+	entry.lastSeen = now
+
+	if s.algorithm == rateLimitSlidingWindow {
+		return s.slidingWindow(entry, now)
+	}
+	return s.tokenBucket(entry, now)
+}
+
+// tokenBucket refills the bucket of the given entry with limit tokens per period.
+func (s *rateLimitStore) tokenBucket(entry *rateLimitEntry, now time.Time) rateLimitResult {
+	limit, period := float64(s.limit), float64(s.period)
+	entry.tokens = math.Min(limit, entry.tokens+float64(now.Sub(entry.windowStart))*limit/period)
+	entry.windowStart = now
+
+	result := rateLimitResult{}
+	if entry.tokens >= 1 {
+		entry.tokens--
+		result.allowed = true
+	} else {
+		result.retryAfter = time.Duration((1 - entry.tokens) * period / limit)
+	}
+
+	result.remaining = int(entry.tokens)
+	result.reset = time.Duration((limit - entry.tokens) * period / limit)
+	return result
+}
+
+// slidingWindow weights the count of the previous window by its remaining overlap with the sliding window.
+func (s *rateLimitStore) slidingWindow(entry *rateLimitEntry, now time.Time) rateLimitResult {
+	if elapsed := now.Sub(entry.windowStart); elapsed >= s.period {
+		windows := elapsed / s.period
+		if windows == 1 {
+			entry.previous = entry.current
+		} else {
+			entry.previous = 0
+		}
+		entry.current = 0
+		entry.windowStart = entry.windowStart.Add(windows * s.period)
+	}
+
+	elapsed := now.Sub(entry.windowStart)
+	weight := 1 - float64(elapsed)/float64(s.period)
+	count := float64(entry.previous)*weight + float64(entry.current)
+
+	result := rateLimitResult{reset: s.period - elapsed}
+	if count+1 <= float64(s.limit) {
+		entry.current++
+		count++
+		result.allowed = true
+	} else if entry.current+1 > s.limit {
+		// the current window is exhausted, its count becomes the previous one of the next window
+		wait := 1 - float64(s.limit-1)/float64(entry.current)
+		result.retryAfter = result.reset + time.Duration(wait*float64(s.period))
+	} else {
+		wait := 1 - float64(s.limit-1-entry.current)/float64(entry.previous)
+		result.retryAfter = time.Duration(wait*float64(s.period)) - elapsed
+	}
+
+	if remaining := s.limit - int(math.Ceil(count)); remaining > 0 {
+		result.remaining = remaining
+	}
+	return result
+}
+
+func (s *rateLimitStore) shard(key string) *rateLimitShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return s.shards[h.Sum32()%rateLimitShards]
+}
+
+// cleanup removes the entries of a full shard which have not been seen for two periods. If the shard
+// is still full, the least recently seen entries are evicted to make room for a new one.
+func (s *rateLimitStore) cleanup(shard *rateLimitShard, now time.Time) {
+	if len(shard.entries) < maxRateLimitShardEntries {
+		return
+	}
+
+	for elem := shard.lru.Back(); elem != nil; elem = shard.lru.Back() {
+		entry := elem.Value.(*rateLimitEntry)
+		if len(shard.entries) < maxRateLimitShardEntries && now.Sub(entry.lastSeen) <= 2*s.period {
+			return
+		}
+		shard.lru.Remove(elem)
+		delete(shard.entries, entry.key)
+	}
+}
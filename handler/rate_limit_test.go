@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/errors"
+	"github.com/avenga/couper/eval"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    *config.RateLimit
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"token bucket", &config.RateLimit{Period: "1m", PerPeriod: 60}, false},
+		{"sliding window", &config.RateLimit{Algorithm: "sliding_window", Period: "1s", PerPeriod: 1}, false},
+		{"unknown algorithm", &config.RateLimit{Algorithm: "leaky_bucket", Period: "1m", PerPeriod: 1}, true},
+		{"invalid period", &config.RateLimit{Period: "1 minute", PerPeriod: 1}, true},
+		{"zero period", &config.RateLimit{Period: "0s", PerPeriod: 1}, true},
+		{"zero per period", &config.RateLimit{Period: "1m"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			_, err := NewRateLimiter(tt.conf)
+			if (err != nil) != tt.wantErr {
+				subT.Errorf("NewRateLimiter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRateLimiter_UsesAccessControl(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"", false},
+		{"req.client_ip", false},
+		{"req.headers.x-user", false},
+		{"req.ctx.myjwt.sub", true},
+		{`"${req.ctx.myapikey.owner}-${req.method}"`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(subT *testing.T) {
+			conf := &config.RateLimit{Period: "1m", PerPeriod: 1}
+			if tt.key != "" {
+				expr, diags := hclsyntax.ParseExpression([]byte(tt.key), "", hcl.InitialPos)
+				if diags.HasErrors() {
+					subT.Fatal(diags)
+				}
+				conf.Key = expr
+			}
+
+			limiter, err := NewRateLimiter(conf)
+			if err != nil {
+				subT.Fatal(err)
+			}
+			if got := limiter.UsesAccessControl(); got != tt.want {
+				subT.Errorf("UsesAccessControl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitStore_TokenBucket(t *testing.T) {
+	store := newRateLimitStore(rateLimitTokenBucket, 2, time.Minute)
+	now := time.Now()
+
+	for i, exp := range []struct {
+		offset     time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{0, true, 1, 0},
+		{0, true, 0, 0},
+		{0, false, 0, 30 * time.Second},
+		{15 * time.Second, false, 0, 15 * time.Second},
+		{30 * time.Second, true, 0, 0},
+		{90 * time.Second, true, 1, 0},
+	} {
+		result := store.take("key", now.Add(exp.offset))
+		if result.allowed != exp.allowed || result.remaining != exp.remaining || result.retryAfter != exp.retryAfter {
+			t.Errorf("%d: expected %+v, got: %+v", i, exp, result)
+		}
+	}
+
+	if result := store.take("other", now); !result.allowed {
+		t.Error("expected an own bucket per key")
+	}
+}
+
+func TestRateLimitStore_SlidingWindow(t *testing.T) {
+	store := newRateLimitStore(rateLimitSlidingWindow, 2, time.Minute)
+	now := time.Now()
+
+	for i, exp := range []struct {
+		offset     time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{0, true, 1, 0},
+		{10 * time.Second, true, 0, 0},
+		{20 * time.Second, false, 0, 70 * time.Second},
+		// previous window weighted by 3/4
+		{75 * time.Second, false, 0, 15 * time.Second},
+		{90 * time.Second, true, 0, 0},
+		{240 * time.Second, true, 1, 0},
+	} {
+		result := store.take("key", now.Add(exp.offset))
+		if result.allowed != exp.allowed || result.remaining != exp.remaining || result.retryAfter != exp.retryAfter {
+			t.Errorf("%d: expected %+v, got: %+v", i, exp, result)
+		}
+	}
+}
+
+func TestRateLimitStore_MaxEntries(t *testing.T) {
+	store := newRateLimitStore(rateLimitTokenBucket, 1, time.Hour)
+	now := time.Now()
+
+	store.take("recent", now)
+	for i := 0; i < rateLimitShards*maxRateLimitShardEntries*2; i++ {
+		store.take(strconv.Itoa(i), now)
+		if i%1000 == 0 {
+			store.take("recent", now)
+		}
+	}
+
+	for i, shard := range store.shards {
+		if len(shard.entries) > maxRateLimitShardEntries || shard.lru.Len() != len(shard.entries) {
+			t.Errorf("shard %d: expected at most %d entries, got: %d", i, maxRateLimitShardEntries, len(shard.entries))
+		}
+	}
+
+	if store.take("recent", now).allowed {
+		t.Error("expected the recently seen key to be kept")
+	}
+	if !store.take("0", now).allowed {
+		t.Error("expected the least recently seen key to be evicted")
+	}
+}
+
+func TestRateLimit_ServeHTTP(t *testing.T) {
+	log, _ := logrustest.NewNullLogger()
+
+	keyExpr, diags := hclsyntax.ParseExpression([]byte(`req.headers.x-user`), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.Fatal(diags)
+	}
+
+	limiter, err := NewRateLimiter(&config.RateLimit{Key: keyExpr, Period: "1h", PerPeriod: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rl := NewRateLimit(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}), limiter, errors.DefaultJSON, eval.NewENVContext(nil), log.WithContext(nil))
+
+	for _, tc := range []struct {
+		name          string
+		user          string
+		remoteAddr    string
+		expStatus     int
+		expRetryAfter string
+	}{
+		{"first", "alice", "192.0.2.1:1234", http.StatusNoContent, ""},
+		{"limited", "alice", "192.0.2.2:1234", http.StatusTooManyRequests, "3600"},
+		{"other key", "bob", "192.0.2.1:1234", http.StatusNoContent, ""},
+		{"client ip fallback", "", "192.0.2.1:1234", http.StatusNoContent, ""},
+		{"client ip limited", "", "192.0.2.1:4321", http.StatusTooManyRequests, "3600"},
+	} {
+		t.Run(tc.name, func(subT *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://couper.io/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.user != "" {
+				req.Header.Set("X-User", tc.user)
+			}
+
+			rec := httptest.NewRecorder()
+			rl.ServeHTTP(rec, req)
+
+			if rec.Code != tc.expStatus {
+				subT.Errorf("expected status %d, got: %d", tc.expStatus, rec.Code)
+			}
+			if retryAfter := rec.Header().Get("Retry-After"); retryAfter != tc.expRetryAfter {
+				subT.Errorf("expected Retry-After %q, got: %q", tc.expRetryAfter, retryAfter)
+			}
+			if limit := rec.Header().Get("RateLimit-Limit"); limit != "1" {
+				subT.Errorf("expected RateLimit-Limit 1, got: %q", limit)
+			}
+			if remaining := rec.Header().Get("RateLimit-Remaining"); remaining != "0" {
+				subT.Errorf("expected RateLimit-Remaining 0, got: %q", remaining)
+			}
+			if tc.expStatus == http.StatusTooManyRequests && rec.Header().Get(errors.HeaderErrorCode) == "" {
+				subT.Error("expected an error code header")
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+)
+
+// defaultTransports is used by proxies without their own transport set.
+var defaultTransports = NewTransports()
+
+// Transports holds the upstream transports of one server configuration. The idle connections
+// of a replaced configuration must be closed with CloseIdleConnections.
+type Transports struct {
+	m sync.Map
+}
+
+func NewTransports() *Transports {
+	return &Transports{}
+}
+
+// get returns the transport of the given key or stores the created one.
+func (t *Transports) get(key string, create func() *http.Transport) *http.Transport {
+	if transport, ok := t.m.Load(key); ok {
+		return transport.(*http.Transport)
+	}
+	transport, _ := t.m.LoadOrStore(key, create())
+	return transport.(*http.Transport)
+}
+
+// CloseIdleConnections closes the idle connections of all transports.
+func (t *Transports) CloseIdleConnections() {
+	if t == nil {
+		return
+	}
+	t.m.Range(func(_, transport interface{}) bool {
+		transport.(*http.Transport).CloseIdleConnections()
+		return true
+	})
+}
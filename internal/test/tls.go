@@ -0,0 +1,109 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+// Certificate represents a generated certificate and its private key.
+type Certificate struct {
+	Cert    *x509.Certificate
+	CertPEM []byte
+	Key     *ecdsa.PrivateKey
+	KeyPEM  []byte
+}
+
+// NewCA creates a self-signed certificate authority.
+func (h *Helper) NewCA(commonName string) *Certificate {
+	h.tb.Helper()
+	return h.newCertificate(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: commonName},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}, nil)
+}
+
+// NewCertificate creates a server and client usable certificate signed by the given ca.
+// The given names are added as DNS or IP subject alternative names.
+func (h *Helper) NewCertificate(ca *Certificate, commonName string, names ...string) *Certificate {
+	h.tb.Helper()
+	tpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: commonName},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+			continue
+		}
+		tpl.DNSNames = append(tpl.DNSNames, name)
+	}
+	return h.newCertificate(tpl, ca)
+}
+
+func (h *Helper) newCertificate(tpl *x509.Certificate, parent *Certificate) *Certificate {
+	h.tb.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	h.Must(err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	h.Must(err)
+
+	tpl.SerialNumber = serial
+	tpl.NotBefore = time.Now().Add(-time.Hour)
+	tpl.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := tpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.Cert, parent.Key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, parentCert, &key.PublicKey, parentKey)
+	h.Must(err)
+
+	cert, err := x509.ParseCertificate(der)
+	h.Must(err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	h.Must(err)
+
+	return &Certificate{
+		Cert:    cert,
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:     key,
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+	}
+}
+
+// TLSCertificate returns the tls package representation.
+func (c *Certificate) TLSCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{c.Cert.Raw},
+		PrivateKey:  c.Key,
+		Leaf:        c.Cert,
+	}
+}
+
+// WriteFiles writes the PEM encoded certificate and key to the given directory
+// and returns both file paths.
+func (h *Helper) WriteFiles(c *Certificate, dir, name string) (string, string) {
+	h.tb.Helper()
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	h.Must(ioutil.WriteFile(certFile, c.CertPEM, 0600))
+	h.Must(ioutil.WriteFile(keyFile, c.KeyPEM, 0600))
+	return certFile, keyFile
+}
@@ -12,18 +12,29 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/avenga/couper/config/request"
 	"github.com/avenga/couper/errors"
+	"github.com/avenga/couper/metrics"
+	"github.com/avenga/couper/tracing"
 )
 
+// HeaderCacheStatus is the response header of the backend response cache.
+const HeaderCacheStatus = "Couper-Cache"
+
 type RoundtripInfo struct {
 	BeReq  *http.Request
 	BeResp *http.Response
 	Err    error
+	// Origin and OriginWeight are set if the origin got selected by a load balancer.
+	Origin       string
+	OriginWeight int
+	// Attempt is the number of the upstream request attempt if retries are configured.
+	Attempt int
 }
 
 type RoundtripHandlerFunc http.HandlerFunc
@@ -50,16 +61,24 @@ func (log *AccessLog) ServeHTTP(rw http.ResponseWriter, req *http.Request, nextH
 	handlerType := reflect.ValueOf(nextHandler).Type()
 	isUpstreamRequest := handlerType == handlerFuncType
 
+	// the trace hooks are called by the transport, e.g. from parallel dial goroutines
+	var mu sync.Mutex
 	timings := Fields{}
+	phases := make(map[string]time.Duration)
 	var timeTTFB, timeGotConn time.Time
 	trace := &httptrace.ClientTrace{
 		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
 			timeGotConn = time.Now()
 		},
 		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
 			timeTTFB = time.Now()
 			if isUpstreamRequest {
-				timings["ttfb"] = roundMS(timeTTFB.Sub(timeGotConn))
+				phases[metrics.PhaseTTFB] = timeTTFB.Sub(timeGotConn)
+				timings["ttfb"] = roundMS(phases[metrics.PhaseTTFB])
 			}
 		},
 	}
@@ -67,25 +86,40 @@ func (log *AccessLog) ServeHTTP(rw http.ResponseWriter, req *http.Request, nextH
 	if isUpstreamRequest {
 		var timeConnect, timeDNS, timeTLS time.Time
 		trace.ConnectStart = func(_, _ string) {
+			mu.Lock()
+			defer mu.Unlock()
 			timeConnect = time.Now()
 		}
 		trace.DNSStart = func(_ httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
 			timeDNS = time.Now()
 		}
 		trace.TLSHandshakeStart = func() {
+			mu.Lock()
+			defer mu.Unlock()
 			timeTLS = time.Now()
 		}
 		trace.ConnectDone = func(network, addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
 			if err == nil {
-				timings["connect"] = roundMS(time.Since(timeConnect))
+				phases[metrics.PhaseConnect] = time.Since(timeConnect)
+				timings["connect"] = roundMS(phases[metrics.PhaseConnect])
 			}
 		}
 		trace.DNSDone = func(_ httptrace.DNSDoneInfo) {
-			timings["dns"] = roundMS(time.Since(timeDNS))
+			mu.Lock()
+			defer mu.Unlock()
+			phases[metrics.PhaseDNS] = time.Since(timeDNS)
+			timings["dns"] = roundMS(phases[metrics.PhaseDNS])
 		}
 		trace.TLSHandshakeDone = func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
 			if err == nil {
-				timings["tls"] = roundMS(time.Since(timeTLS))
+				phases[metrics.PhaseTLS] = time.Since(timeTLS)
+				timings["tls"] = roundMS(phases[metrics.PhaseTLS])
 			}
 		}
 	}
@@ -132,14 +166,15 @@ func (log *AccessLog) ServeHTTP(rw http.ResponseWriter, req *http.Request, nextH
 		"uid":     uniqueID,
 	}
 
-	if h, ok := nextHandler.(fmt.Stringer); ok {
+	if h, ok := nextHandler.(fmt.Stringer); ok && h.String() != "" {
 		fields["handler"] = h.String()
 	}
 
+	endpointName, _ := reqCtx.Context().Value(request.Endpoint).(string)
+
 	if isUpstreamRequest {
 		backendName, _ := reqCtx.Context().Value(request.BackendName).(string)
 		if backendName == "" {
-			endpointName, _ := reqCtx.Context().Value(request.Endpoint).(string)
 			backendName = serverName + ":" + endpointName
 		}
 		fields["backend"] = backendName
@@ -187,23 +222,57 @@ func (log *AccessLog) ServeHTTP(rw http.ResponseWriter, req *http.Request, nextH
 	}
 	fields["scheme"] = scheme
 
+	if cacheStatus := rw.Header().Get(HeaderCacheStatus); cacheStatus != "" {
+		fields["cache"] = cacheStatus
+	}
+
 	var err error
 	if isUpstreamRequest && roundtripInfo != nil {
+		// late trace hooks of unused connections must not modify the logged timings
+		mu.Lock()
+		timings, phases = copyTimings(timings, phases)
+		ttfb := timeTTFB
+		mu.Unlock()
+
 		err = roundtripInfo.Err
+		if roundtripInfo.Origin != "" {
+			fields["origin"] = Fields{
+				"url":    roundtripInfo.Origin,
+				"weight": roundtripInfo.OriginWeight,
+			}
+		}
+		if roundtripInfo.Attempt > 0 {
+			fields["attempt"] = roundtripInfo.Attempt
+		}
 		if roundtripInfo.BeResp != nil {
+			// retried attempts are not written to the client
+			fields["status"] = roundtripInfo.BeResp.StatusCode
 			fields["timings"] = timings
-			timings["ttlb"] = roundMS(serveDone.Sub(timeTTFB))
+			timings["ttlb"] = roundMS(serveDone.Sub(ttfb))
 		} else {
 			fields["status"] = 0
 			fields["scheme"] = reqCtx.URL.Scheme
 		}
+		backendName, _ := fields["backend"].(string)
+		status, _ := fields["status"].(int)
+		metrics.ObserveBackendRequest(serverName, endpointName, backendName, status, serveDone.Sub(startTime), phases)
 	} else if !isUpstreamRequest {
-		fields["client_ip"], _ = splitHostPort(reqCtx.RemoteAddr)
+		if clientIP, ok := reqCtx.Context().Value(request.ClientIP).(string); ok {
+			fields["client_ip"] = clientIP
+		} else {
+			fields["client_ip"], _ = splitHostPort(reqCtx.RemoteAddr)
+		}
 		if couperErr := statusRecorder.Header().Get(errors.HeaderErrorCode); couperErr != "" {
 			i, _ := strconv.Atoi(couperErr[:4])
 			err = errors.Code(i)
 			fields["code"] = i
 		}
+		metrics.ObserveRequest(serverName, endpointName, statusRecorder.status, serveDone.Sub(startTime))
+
+		if span := tracing.SpanFromContext(reqCtx.Context()); span != nil {
+			fields["trace_id"] = span.SpanContext().TraceID.String()
+			setServerSpan(span, reqCtx, serverName, endpointName, statusRecorder.status)
+		}
 	}
 
 	var entry *logrus.Entry
@@ -225,6 +294,34 @@ func (log *AccessLog) ServeHTTP(rw http.ResponseWriter, req *http.Request, nextH
 	}
 }
 
+func copyTimings(timings Fields, phases map[string]time.Duration) (Fields, map[string]time.Duration) {
+	timingsCopy := make(Fields, len(timings)+1)
+	for k, v := range timings {
+		timingsCopy[k] = v
+	}
+	phasesCopy := make(map[string]time.Duration, len(phases))
+	for k, v := range phases {
+		phasesCopy[k] = v
+	}
+	return timingsCopy, phasesCopy
+}
+
+// setServerSpan names the client request span by its route and adds the response status.
+func setServerSpan(span *tracing.Span, req *http.Request, serverName, endpointName string, status int) {
+	if endpointName != "" {
+		span.SetName(req.Method + " " + endpointName)
+	}
+	span.SetAttribute("couper.server", serverName)
+	span.SetAttribute("couper.uid", req.Context().Value(request.UID))
+	span.SetAttribute("http.host", req.Host)
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.status_code", status)
+	span.SetAttribute("http.target", req.URL.RequestURI())
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(tracing.StatusError, http.StatusText(status))
+	}
+}
+
 func filterHeader(list []string, src http.Header) map[string]string {
 	header := make(map[string]string)
 	for _, key := range list {
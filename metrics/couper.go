@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// Default is the registry which gets exposed by the metrics endpoint.
+var Default = NewRegistry()
+
+// Upstream request phases measured by the access log.
+const (
+	PhaseConnect = "connect"
+	PhaseDNS     = "dns"
+	PhaseTLS     = "tls"
+	PhaseTTFB    = "ttfb"
+)
+
+var (
+	requests = Default.NewCounterVec("couper_requests_total",
+		"Number of client requests.", "server", "endpoint", "status_class")
+	requestDuration = Default.NewHistogramVec("couper_request_duration_seconds",
+		"Duration of client requests.", DefaultBuckets, "server", "endpoint")
+
+	backendRequests = Default.NewCounterVec("couper_backend_requests_total",
+		"Number of upstream requests.", "server", "endpoint", "backend", "status_class")
+	backendDuration = Default.NewHistogramVec("couper_backend_request_duration_seconds",
+		"Duration of upstream requests.", DefaultBuckets, "server", "endpoint", "backend")
+	backendPhaseDuration = Default.NewHistogramVec("couper_backend_phase_duration_seconds",
+		"Duration of the upstream request phases dns, connect, tls and ttfb.", DefaultBuckets, "backend", "phase")
+)
+
+// ObserveRequest records a client request.
+func ObserveRequest(server, endpoint string, status int, d time.Duration) {
+	requests.Inc(server, endpoint, StatusClass(status))
+	requestDuration.Observe(d.Seconds(), server, endpoint)
+}
+
+// ObserveBackendRequest records an upstream request and its measured phases.
+// A status of zero marks a request without a response.
+func ObserveBackendRequest(server, endpoint, backend string, status int, d time.Duration, phases map[string]time.Duration) {
+	backendRequests.Inc(server, endpoint, backend, StatusClass(status))
+	backendDuration.Observe(d.Seconds(), server, endpoint, backend)
+	for phase, pd := range phases {
+		backendPhaseDuration.Observe(pd.Seconds(), backend, phase)
+	}
+}
+
+// StatusClass returns the class of the given status code, e.g. "2xx".
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
@@ -0,0 +1,58 @@
+package metrics_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/avenga/couper/metrics"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.NewCounterVec("test_total", "Test counter.", "name")
+	h := r.NewHistogramVec("test_seconds", "Test histogram.", []float64{.1, 1}, "name")
+
+	c.Inc("b")
+	c.Inc("a\"\n")
+	c.Add(2, "b")
+	h.Observe(.05, "x")
+	h.Observe(.5, "x")
+	h.Observe(5, "x")
+
+	buf := &bytes.Buffer{}
+	if err := r.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP test_total Test counter.
+# TYPE test_total counter
+test_total{name="a\"\n"} 1
+test_total{name="b"} 3
+# HELP test_seconds Test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{name="x",le="0.1"} 1
+test_seconds_bucket{name="x",le="1"} 2
+test_seconds_bucket{name="x",le="+Inf"} 3
+test_seconds_sum{name="x"} 5.55
+test_seconds_count{name="x"} 3
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Expected content type %q, got: %q", metrics.ContentType, ct)
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for status, class := range map[int]string{0: "error", 200: "2xx", 304: "3xx", 404: "4xx", 503: "5xx"} {
+		if got := metrics.StatusClass(status); got != class {
+			t.Errorf("StatusClass(%d) = %q, want: %q", status, got, class)
+		}
+	}
+}
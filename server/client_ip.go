@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/avenga/couper/utils"
+)
+
+// getClientIP determines the client address of the given request. The X-Forwarded-For or Forwarded
+// headers are only considered if the immediate peer is a trusted proxy. Trusted entries of the
+// X-Forwarded-For header are removed, so proxies append the resolved client address to the remaining list.
+func getClientIP(req *http.Request, trustedProxies []*net.IPNet) string {
+	peer := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+
+	if !utils.ContainsIP(trustedProxies, net.ParseIP(peer)) {
+		return peer
+	}
+
+	if xff := forwardedFor(req.Header.Values("X-Forwarded-For")); len(xff) > 0 {
+		idx := resolveClient(xff, trustedProxies)
+		if idx < 0 {
+			return peer
+		}
+
+		if idx == 0 {
+			req.Header.Del("X-Forwarded-For")
+		} else {
+			req.Header.Set("X-Forwarded-For", strings.Join(xff[:idx], ", "))
+		}
+		return xff[idx]
+	}
+
+	if forwarded := forwardedNodes(req.Header.Values("Forwarded")); len(forwarded) > 0 {
+		if idx := resolveClient(forwarded, trustedProxies); idx >= 0 {
+			return forwarded[idx]
+		}
+	}
+	return peer
+}
+
+// resolveClient returns the index of the rightmost untrusted address, the leftmost one if all are trusted.
+// Unparsable addresses stop the search, their trusted right neighbour is the client then.
+func resolveClient(addrs []string, trustedProxies []*net.IPNet) int {
+	client := -1
+	for i := len(addrs) - 1; i >= 0; i-- {
+		ip := net.ParseIP(addrs[i])
+		if ip == nil {
+			break
+		}
+		client = i
+		if !utils.ContainsIP(trustedProxies, ip) {
+			break
+		}
+	}
+	return client
+}
+
+func forwardedFor(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, addr := range strings.Split(value, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				list = append(list, addr)
+			}
+		}
+	}
+	return list
+}
+
+// forwardedNodes returns the addresses of the "for" parameters, see https://tools.ietf.org/html/rfc7239.
+func forwardedNodes(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 || strings.ToLower(kv[0]) != "for" {
+					continue
+				}
+
+				node := strings.Trim(kv[1], `"`)
+				if strings.HasPrefix(node, "[") {
+					// quoted ipv6 address with optional port
+					if end := strings.Index(node, "]"); end > 0 {
+						node = node[1:end]
+					}
+				} else if host, _, err := net.SplitHostPort(node); err == nil {
+					node = host
+				}
+				list = append(list, node)
+			}
+		}
+	}
+	return list
+}
@@ -2,9 +2,11 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/xid"
@@ -17,6 +19,9 @@ import (
 	"github.com/avenga/couper/handler"
 	"github.com/avenga/couper/internal/test"
 	"github.com/avenga/couper/logging"
+	"github.com/avenga/couper/metrics"
+	"github.com/avenga/couper/tracing"
+	"github.com/avenga/couper/utils"
 )
 
 // HTTPServer represents a configured HTTP server.
@@ -24,15 +29,25 @@ type HTTPServer struct {
 	accessLog  *logging.AccessLog
 	commandCtx context.Context
 	config     *runtime.HTTPConfig
+	drainCh    chan struct{}
 	listener   net.Listener
 	log        logrus.FieldLogger
-	mux        *Mux
-	port       string
+	port       runtime.Port
 	shutdownCh chan struct{}
 	srv        *http.Server
+	state      atomic.Value // *muxState
+	tls        bool
+	trusted    []*net.IPNet
 	uidFn      func() string
 }
 
+// muxState holds the configuration dependent parts of a HTTPServer which get replaced on reloads.
+type muxState struct {
+	mux    *Mux
+	tls    *tls.Config
+	tracer *tracing.Tracer
+}
+
 // NewServerList creates a list of all configured HTTP server.
 func NewServerList(cmdCtx context.Context, log logrus.FieldLogger, conf *runtime.HTTPConfig, srvConf *runtime.ServerConfiguration) ([]*HTTPServer, func()) {
 	var list []*HTTPServer
@@ -41,6 +56,11 @@ func NewServerList(cmdCtx context.Context, log logrus.FieldLogger, conf *runtime
 		list = append(list, New(cmdCtx, log, conf, port, srvMux))
 	}
 
+	// the internal metrics port is not reachable via configured server hosts
+	if conf.MetricsPort != 0 {
+		list = append(list, New(cmdCtx, log, conf, runtime.Port(conf.MetricsPort), nil))
+	}
+
 	handleShutdownFn := func() {
 		<-cmdCtx.Done()
 		time.Sleep(conf.Timings.ShutdownDelay + conf.Timings.ShutdownTimeout) // wait for max amount, TODO: feedback per server
@@ -66,25 +86,28 @@ func New(cmdCtx context.Context, log logrus.FieldLogger, conf *runtime.HTTPConfi
 		}
 	}
 
+	// trusted proxies are validated with the server configuration
+	trusted, err := utils.ParseNetworks(conf.TrustedProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	logConf := *logging.DefaultConfig
 	logConf.TypeFieldKey = "couper_access"
 	env.DecodeWithPrefix(&logConf, "ACCESS_")
 
-	shutdownCh := make(chan struct{})
-
-	mux := NewMux(muxOpts)
-	mux.MustAddRoute(http.MethodGet, conf.HealthPath, handler.NewHealthCheck(conf.HealthPath, shutdownCh))
-
 	httpSrv := &HTTPServer{
 		accessLog:  logging.NewAccessLog(&logConf, log),
 		commandCtx: cmdCtx,
 		config:     conf,
+		drainCh:    make(chan struct{}),
 		log:        log,
-		mux:        mux,
-		port:       p.String(),
-		shutdownCh: shutdownCh,
+		port:       p,
+		shutdownCh: make(chan struct{}),
+		trusted:    trusted,
 		uidFn:      uidFn,
 	}
+	httpSrv.state.Store(httpSrv.newMuxState(muxOpts))
 
 	srv := &http.Server{
 		Addr:              ":" + p.String(),
@@ -93,11 +116,55 @@ func New(cmdCtx context.Context, log logrus.FieldLogger, conf *runtime.HTTPConfi
 		ReadHeaderTimeout: conf.Timings.ReadHeaderTimeout,
 	}
 
+	if muxOpts != nil && muxOpts.TLS != nil {
+		httpSrv.tls = true
+		// the certificates are looked up per connection to apply reloaded configurations
+		srv.TLSConfig = &tls.Config{
+			GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return httpSrv.getState().tls.GetCertificate(hello)
+			},
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				return httpSrv.getState().tls.GetConfigForClient(hello)
+			},
+		}
+	}
+
 	httpSrv.srv = srv
 
 	return httpSrv
 }
 
+// newMuxState creates the mux with the health and metrics routes for the given options.
+func (s *HTTPServer) newMuxState(muxOpts *runtime.MuxOptions) *muxState {
+	var backendHealth []*handler.BackendHealth
+	state := &muxState{}
+	if muxOpts != nil {
+		backendHealth = muxOpts.BackendHealth
+		state.tls = muxOpts.TLS
+		state.tracer = muxOpts.Tracer
+	}
+
+	state.mux = NewMux(muxOpts)
+	state.mux.MustAddRoute(http.MethodGet, s.config.HealthPath, handler.NewHealthCheck(s.config.HealthPath, s.config.HealthDetails, s.shutdownCh, backendHealth...))
+
+	if metricsPath := getMetricsPath(s.config); metricsPath != "" && (s.config.MetricsPort == 0 || s.config.MetricsPort == int(s.port)) {
+		state.mux.MustAddRoute(http.MethodGet, metricsPath, metrics.Default)
+	}
+	return state
+}
+
+func (s *HTTPServer) getState() *muxState {
+	return s.state.Load().(*muxState)
+}
+
+// getMetricsPath returns the path of the metrics endpoint, empty if metrics are disabled.
+func getMetricsPath(conf *runtime.HTTPConfig) string {
+	if conf.MetricsPath == "" && conf.MetricsPort != 0 {
+		return "/metrics"
+	}
+	return conf.MetricsPath
+}
+
 // Addr returns the listener address.
 func (s *HTTPServer) Addr() string {
 	if s.listener != nil {
@@ -108,24 +175,43 @@ func (s *HTTPServer) Addr() string {
 
 // Listen initiates the configured http handler and start listing on given port.
 func (s *HTTPServer) Listen() {
+	if err := s.listen(); err != nil {
+		s.log.Fatal(err)
+	}
+}
+
+func (s *HTTPServer) listen() error {
 	if s.srv.Addr == "" {
 		s.srv.Addr = ":http"
 	}
 	ln, err := net.Listen("tcp4", s.srv.Addr)
 	if err != nil {
-		s.log.Fatal(err)
+		return err
 	}
 
 	s.listener = ln
-	s.log.Infof("couper is serving: %s", ln.Addr().String())
+
+	scheme := "http"
+	if s.tls {
+		scheme = "https"
+	}
+	s.log.Infof("couper is serving %s: %s", scheme, ln.Addr().String())
 
 	go s.listenForCtx()
 
 	go func() {
-		if err := s.srv.Serve(ln); err != nil {
+		var err error
+		if s.tls {
+			// certificates are provided by the tls config
+			err = s.srv.ServeTLS(ln, "", "")
+		} else {
+			err = s.srv.Serve(ln)
+		}
+		if err != nil {
 			s.log.Errorf("%s: %v", ln.Addr().String(), err.Error())
 		}
 	}()
+	return nil
 }
 
 // Close closes the listener
@@ -135,6 +221,8 @@ func (s *HTTPServer) Close() error {
 
 func (s *HTTPServer) listenForCtx() {
 	select {
+	case <-s.drainCh:
+		return
 	case <-s.commandCtx.Done():
 		logFields := logrus.Fields{
 			"delay":    s.config.Timings.ShutdownDelay.String(),
@@ -164,11 +252,15 @@ func (s *HTTPServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 
 	uid := s.uidFn()
 	ctx := context.WithValue(req.Context(), request.UID, uid)
+	ctx = context.WithValue(ctx, request.ClientIP, getClientIP(req, s.trusted))
+	state := s.getState()
+	ctx, span := state.tracer.Start(ctx, req.Method, req.Header)
+	defer span.End()
 	*req = *req.WithContext(ctx)
 
 	req.Host = s.getHost(req)
 
-	h := s.mux.FindHandler(req)
+	h := state.mux.FindHandler(req)
 	s.accessLog.ServeHTTP(NewHeaderWriter(rw), req, h, startTime)
 }
 
@@ -195,5 +287,5 @@ func (s *HTTPServer) getHost(req *http.Request) string {
 }
 
 func (s *HTTPServer) cleanHostAppendPort(host string) string {
-	return strings.TrimSuffix(host, ".") + ":" + s.port
+	return strings.TrimSuffix(host, ".") + ":" + s.port.String()
 }
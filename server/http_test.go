@@ -2,23 +2,42 @@ package server_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/big"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"text/template"
 	"time"
 
+	"github.com/andybalholm/brotli"
+	"github.com/dgrijalva/jwt-go/v4"
 	logrustest "github.com/sirupsen/logrus/hooks/test"
 
 	"github.com/avenga/couper/config"
 	"github.com/avenga/couper/config/runtime"
+	"github.com/avenga/couper/errors"
 	"github.com/avenga/couper/internal/test"
+	"github.com/avenga/couper/logging"
 	"github.com/avenga/couper/server"
 )
 
@@ -268,3 +287,1549 @@ func TestHTTPServer_ServeHTTP_UUID_Option(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPServer_ServeHTTP_TLS(t *testing.T) {
+	helper := test.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "couper_tls")
+	helper.Must(err)
+	defer os.RemoveAll(tmpDir)
+
+	ca := helper.NewCA("couper test ca")
+	certFile1, keyFile1 := helper.WriteFiles(helper.NewCertificate(ca, "couper.io", "couper.io"), tmpDir, "couper.io")
+	certFile2, keyFile2 := helper.WriteFiles(helper.NewCertificate(ca, "example.com", "example.com"), tmpDir, "example.com")
+
+	confBytes := []byte(fmt.Sprintf(`
+server "first" {
+  hosts = ["*", "couper.io"]
+  files {
+    document_root = "testdata/integration/vhosts/htdocs_01"
+  }
+  tls {
+    cert_file = %q
+    key_file = %q
+  }
+}
+
+server "second" {
+  hosts = ["example.com"]
+  files {
+    document_root = "testdata/integration/vhosts/htdocs_02"
+  }
+  tls {
+    cert_file = %q
+    key_file = %q
+    min_version = "TLS1.3"
+  }
+}
+`, certFile1, keyFile1, certFile2, keyFile2))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, _ := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(nil)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.Cert)
+
+	for _, testCase := range []struct {
+		host         string
+		maxVersion   uint16
+		expectedBody string
+		expectErr    bool
+	}{
+		{"couper.io", 0, "FS_01", false},
+		{"couper.io", tls.VersionTLS12, "FS_01", false},
+		{"example.com", 0, "FS_02", false},
+		{"example.com", tls.VersionTLS12, "", true}, // min_version
+	} {
+		t.Run(fmt.Sprintf("%s_%x", testCase.host, testCase.maxVersion), func(subT *testing.T) {
+			h := test.New(subT)
+			client := http.Client{
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+						return net.Dial("tcp4", couper.Addr())
+					},
+					TLSClientConfig: &tls.Config{RootCAs: rootCAs, MaxVersion: testCase.maxVersion},
+				},
+			}
+
+			res, err := client.Get(fmt.Sprintf("https://%s:%s/", testCase.host, port))
+			if testCase.expectErr {
+				if err == nil {
+					subT.Error("expected a tls handshake error")
+				}
+				return
+			}
+			h.Must(err)
+
+			result, err := ioutil.ReadAll(res.Body)
+			h.Must(err)
+			h.Must(res.Body.Close())
+
+			if res.StatusCode != http.StatusOK {
+				subT.Errorf("expected status %d, got %d", http.StatusOK, res.StatusCode)
+			}
+
+			if !bytes.Contains(result, []byte(testCase.expectedBody)) {
+				subT.Errorf("expected body to contain %q, got: %s", testCase.expectedBody, string(result))
+			}
+
+			if res.TLS == nil || res.TLS.PeerCertificates[0].Subject.CommonName != testCase.host {
+				subT.Errorf("expected certificate for %q", testCase.host)
+			}
+		})
+	}
+}
+
+func TestHTTPServer_ServeHTTP_MTLS(t *testing.T) {
+	helper := test.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "couper_mtls")
+	helper.Must(err)
+	defer os.RemoveAll(tmpDir)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Client-CN", req.Header.Get("X-Client-CN"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	ca := helper.NewCA("couper test ca")
+	certFile, keyFile := helper.WriteFiles(helper.NewCertificate(ca, "couper.io", "couper.io"), tmpDir, "couper.io")
+	caFile, _ := helper.WriteFiles(ca, tmpDir, "ca")
+
+	for _, reference := range []string{"client", "clients"} {
+		confBytes := []byte(fmt.Sprintf(`
+	server "mtls" {
+	  hosts = ["couper.io"]
+	  api {
+	    endpoint "/" {
+	      access_control = [%q]
+	      backend {
+	        origin = %q
+	        request_headers = {
+	          x-client-cn = req.ctx.client.common_name
+	        }
+	      }
+	    }
+	  }
+	  tls {
+	    cert_file = %q
+	    key_file = %q
+	  }
+	}
+
+	definitions {
+	  mtls "client" {
+	    ca_file = %q
+	    subject_pattern = "^CN=client-a$"
+	  }
+	  basic_auth "ba" {
+	    password = "secret"
+	  }
+	  # the client certificate gets requested for group members too
+	  access_control_group "clients" {
+	    access_control = ["ba", "client"]
+	  }
+	}
+	`, reference, origin.URL, certFile, keyFile, caFile))
+
+		conf, err := config.LoadBytes(confBytes, "couper.hcl")
+		helper.Must(err)
+
+		log, _ := logrustest.NewNullLogger()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		httpConf := runtime.NewHTTPConfig(nil)
+		httpConf.ListenPort = 0 // random
+
+		srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+		helper.Must(err)
+
+		port := runtime.Port(httpConf.ListenPort)
+		couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+		couper.Listen()
+		defer couper.Close()
+
+		rootCAs := x509.NewCertPool()
+		rootCAs.AddCert(ca.Cert)
+
+		for _, testCase := range []struct {
+			name           string
+			clientCert     *test.Certificate
+			expectedStatus int
+			expectedCN     string
+		}{
+			{"without client certificate", nil, http.StatusUnauthorized, ""},
+			{"with client certificate", helper.NewCertificate(ca, "client-a"), http.StatusNoContent, "client-a"},
+			{"with unexpected subject", helper.NewCertificate(ca, "client-b"), http.StatusForbidden, ""},
+			{"with unknown ca", helper.NewCertificate(helper.NewCA("other"), "client-a"), http.StatusForbidden, ""},
+		} {
+			t.Run(reference+" "+testCase.name, func(subT *testing.T) {
+				h := test.New(subT)
+
+				tlsConf := &tls.Config{RootCAs: rootCAs}
+				if testCase.clientCert != nil {
+					tlsConf.Certificates = []tls.Certificate{testCase.clientCert.TLSCertificate()}
+				}
+
+				client := http.Client{
+					Transport: &http.Transport{
+						DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+							return net.Dial("tcp4", couper.Addr())
+						},
+						TLSClientConfig: tlsConf,
+					},
+				}
+
+				res, err := client.Get(fmt.Sprintf("https://couper.io:%s/", port))
+				h.Must(err)
+				h.Must(res.Body.Close())
+
+				if res.StatusCode != testCase.expectedStatus {
+					subT.Errorf("expected status %d, got %d", testCase.expectedStatus, res.StatusCode)
+				}
+
+				if cn := res.Header.Get("X-Client-CN"); cn != testCase.expectedCN {
+					subT.Errorf("expected forwarded common name %q, got %q", testCase.expectedCN, cn)
+				}
+			})
+		}
+
+	}
+}
+
+func TestHTTPServer_ServeHTTP_LoadBalancer(t *testing.T) {
+	helper := test.New(t)
+
+	newOrigin := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Origin", name)
+			rw.WriteHeader(http.StatusNoContent)
+		}))
+	}
+
+	originA, originB := newOrigin("a"), newOrigin("b")
+	defer originA.Close()
+	defer originB.Close()
+
+	confBytes := []byte(fmt.Sprintf(`
+server "lb" {
+  api {
+    endpoint "/" {
+      backend = "replicas"
+    }
+  }
+}
+
+definitions {
+  backend "replicas" {
+    load_balancer {
+      origin %q {
+        weight = 2
+      }
+      origin %q {}
+    }
+  }
+}
+`, originA.URL, originB.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, hook := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(nil)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	var sequence string
+	for i := 0; i < 6; i++ {
+		hook.Reset()
+
+		res, err := http.Get("http://" + couper.Addr() + "/")
+		helper.Must(err)
+		helper.Must(res.Body.Close())
+
+		if res.StatusCode != http.StatusNoContent {
+			t.Fatalf("expected status %d, got %d", http.StatusNoContent, res.StatusCode)
+		}
+		sequence += res.Header.Get("X-Origin")
+
+		var origin interface{}
+		for _, entry := range hook.AllEntries() {
+			if entry.Data["type"] == "couper_backend" {
+				origin = entry.Data["origin"]
+			}
+		}
+
+		if origin == nil {
+			t.Fatal("expected an origin field within the upstream log")
+		}
+	}
+
+	if sequence != "abaaba" {
+		t.Errorf("expected weighted sequence %q, got %q", "abaaba", sequence)
+	}
+}
+
+func TestHTTPServer_ServeHTTP_BackendHealth(t *testing.T) {
+	helper := test.New(t)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/health" {
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	confBytes := []byte(fmt.Sprintf(`
+server "health" {
+  api {
+    endpoint "/a" {
+      backend = "checked"
+    }
+    endpoint "/b" {
+      backend = "checked"
+    }
+  }
+}
+
+definitions {
+  backend "checked" {
+    origin = %q
+    health {
+      path = "/health"
+      interval = "10ms"
+      failure_threshold = 1
+    }
+  }
+}
+`, origin.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, _ := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(nil)
+	httpConf.HealthDetails = true
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	if len(srvConf.BackendHealth) != 1 {
+		t.Fatalf("expected one shared health check, got %d", len(srvConf.BackendHealth))
+	}
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	srvConf.StartHealthChecks(ctx, log.WithContext(ctx))
+	time.Sleep(time.Millisecond * 100)
+
+	for _, p := range []string{"/a", "/b"} {
+		res, err := http.Get("http://" + couper.Addr() + p)
+		helper.Must(err)
+		helper.Must(res.Body.Close())
+
+		if res.StatusCode != http.StatusBadGateway {
+			t.Errorf("%s: expected status %d, got %d", p, http.StatusBadGateway, res.StatusCode)
+		}
+
+		if code := res.Header.Get("Couper-Error"); code != `4004 - "API upstream is unhealthy"` {
+			t.Errorf("%s: expected unhealthy error code, got %q", p, code)
+		}
+	}
+
+	res, err := http.Get("http://" + couper.Addr() + "/healthz?details")
+	helper.Must(err)
+	body, err := ioutil.ReadAll(res.Body)
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d for a backend without healthy origins, got %d", http.StatusServiceUnavailable, res.StatusCode)
+	}
+
+	if !bytes.Contains(body, []byte(`"healthy":false`)) || !bytes.Contains(body, []byte(`"status":"unhealthy"`)) {
+		t.Errorf("expected an unhealthy origin within the detailed health view, got: %s", body)
+	}
+}
+
+func TestHTTPServer_ServeHTTP_Metrics(t *testing.T) {
+	helper := test.New(t)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	confBytes := []byte(fmt.Sprintf(`
+server "metrics" {
+  api {
+    endpoint "/measured" {
+      backend = "measured"
+    }
+  }
+}
+
+definitions {
+  backend "measured" {
+    origin = %q
+  }
+}
+`, origin.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, _ := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// obtain a free port for the internal metrics server
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	helper.Must(err)
+	metricsPort := ln.Addr().(*net.TCPAddr).Port
+	helper.Must(ln.Close())
+
+	httpConf := runtime.NewHTTPConfig(nil)
+	httpConf.ListenPort = 0 // random
+	httpConf.MetricsPort = metricsPort
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	internal := server.New(ctx, log.WithContext(ctx), httpConf, runtime.Port(metricsPort), nil)
+	internal.Listen()
+	defer internal.Close()
+
+	res, err := http.Get("http://" + couper.Addr() + "/measured")
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, res.StatusCode)
+	}
+
+	res, err = http.Get("http://" + couper.Addr() + "/metrics")
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+
+	if res.StatusCode == http.StatusOK {
+		t.Error("expected no metrics endpoint on the public port")
+	}
+
+	res, err = http.Get("http://" + internal.Addr() + "/metrics")
+	helper.Must(err)
+	body, err := ioutil.ReadAll(res.Body)
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+
+	for _, expected := range []string{
+		`couper_requests_total{server="metrics",endpoint="/measured",status_class="2xx"} 1`,
+		`couper_backend_requests_total{server="metrics",endpoint="/measured",backend="measured",status_class="2xx"} 1`,
+		`couper_request_duration_seconds_count{server="metrics",endpoint="/measured"} 1`,
+		`couper_backend_phase_duration_seconds_count{backend="measured",phase="ttfb"} 1`,
+	} {
+		if !bytes.Contains(body, []byte(expected)) {
+			t.Errorf("expected metric %q, got:\n%s", expected, body)
+		}
+	}
+
+	_, err = runtime.NewServerConfiguration(conf, &runtime.HTTPConfig{ListenPort: metricsPort, MetricsPort: metricsPort}, log.WithContext(nil))
+	if err == nil {
+		t.Error("expected an error for a metrics port which is used by a server")
+	}
+}
+
+func TestHTTPServer_ServeHTTP_Tracing(t *testing.T) {
+	helper := test.New(t)
+
+	var upstreamTraceparent string
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		upstreamTraceparent = req.Header.Get("Traceparent")
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	dir, err := ioutil.TempDir("", "couper-tracing")
+	helper.Must(err)
+	defer os.RemoveAll(dir)
+	traceFile := filepath.Join(dir, "traces.json")
+
+	confBytes := []byte(fmt.Sprintf(`
+server "traced" {
+  api {
+    endpoint "/traced" {
+      backend = "traced"
+    }
+  }
+}
+
+definitions {
+  backend "traced" {
+    origin = %q
+  }
+}
+
+settings {
+  tracing {
+    exporter = "file"
+    file = %q
+  }
+}
+`, origin.URL, traceFile))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, _ := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(nil)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	if srvConf.Tracer == nil {
+		t.Fatal("expected a configured tracer")
+	}
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, err := http.NewRequest(http.MethodGet, "http://"+couper.Addr()+"/traced", nil)
+	helper.Must(err)
+	req.Header.Set("Traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	res, err := http.DefaultClient.Do(req)
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+
+	if res.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, res.StatusCode)
+	}
+
+	if !regexp.MustCompile(`^00-`+traceID+`-[0-9a-f]{16}-01$`).MatchString(upstreamTraceparent) ||
+		strings.Contains(upstreamTraceparent, "00f067aa0ba902b7") {
+		t.Errorf("expected a propagated traceparent with a new parent id, got %q", upstreamTraceparent)
+	}
+
+	helper.Must(srvConf.Tracer.Shutdown(context.Background()))
+
+	traces, err := ioutil.ReadFile(traceFile)
+	helper.Must(err)
+
+	for _, expected := range []string{
+		`"name":"GET /traced"`,
+		`"name":"backend traced"`,
+		`"parentSpanId":"00f067aa0ba902b7"`,
+		`"name":"got_conn"`,
+		`"traceId":"` + traceID + `"`,
+	} {
+		if !strings.Contains(string(traces), expected) {
+			t.Errorf("expected %s within exported spans, got: %s", expected, traces)
+		}
+	}
+}
+
+func TestHTTPServer_ServeHTTP_Authorize(t *testing.T) {
+	helper := test.New(t)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// the body buffered for the authorization is forwarded
+		if b, _ := ioutil.ReadAll(req.Body); req.URL.Path == "/body" && string(b) != `{"amount":50}` {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	confBytes := []byte(fmt.Sprintf(`
+server "authorize" {
+  api {
+    access_control = ["myjwt"]
+    authorize = req.ctx.myjwt.level >= 2
+
+    endpoint "/orders" {
+      authorize = contains(req.ctx.myjwt.roles, "orders:write")
+      backend = "origin"
+    }
+
+    endpoint "/scope" {
+      authorize = contains(req.ctx.myjwt.scope, "read") && req.method == "GET"
+      backend = "origin"
+    }
+
+    endpoint "/invalid" {
+      authorize = req.ctx.myjwt.level
+      backend = "origin"
+    }
+
+    endpoint "/body" {
+      authorize = req.json_body.amount <= 100
+      backend = "origin"
+    }
+  }
+}
+
+definitions {
+  backend "origin" {
+    origin = %q
+  }
+
+  jwt "myjwt" {
+    header = "Authorization"
+    signature_algorithm = "HS256"
+    key = "s3cr3t"
+  }
+}
+`, origin.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, hook := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(nil)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	newToken := func(claims jwt.MapClaims) string {
+		token, tokenErr := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("s3cr3t"))
+		helper.Must(tokenErr)
+		return token
+	}
+
+	admin := newToken(jwt.MapClaims{"level": 3, "roles": []string{"orders:read", "orders:write"}, "scope": "read write"})
+	reader := newToken(jwt.MapClaims{"level": 2, "roles": []string{"orders:read"}, "scope": "read"})
+	guest := newToken(jwt.MapClaims{"level": 1, "roles": []string{"orders:write"}, "scope": "read"})
+
+	for _, tc := range []struct {
+		name, method, path, token, body string
+		expStatus                       int
+	}{
+		{"admin orders", http.MethodGet, "/orders", admin, "", http.StatusNoContent},
+		{"reader orders", http.MethodGet, "/orders", reader, "", http.StatusForbidden},
+		{"guest orders", http.MethodGet, "/orders", guest, "", http.StatusForbidden},
+		{"reader scope", http.MethodGet, "/scope", reader, "", http.StatusNoContent},
+		{"reader scope POST", http.MethodPost, "/scope", reader, "", http.StatusForbidden},
+		{"missing token", http.MethodGet, "/scope", "", "", http.StatusUnauthorized},
+		{"non bool expression", http.MethodGet, "/invalid", admin, "", http.StatusForbidden},
+		{"json body", http.MethodPost, "/body", admin, `{"amount":50}`, http.StatusNoContent},
+		{"json body exceeded", http.MethodPost, "/body", admin, `{"amount":500}`, http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(subT *testing.T) {
+			hook.Reset()
+
+			req, err := http.NewRequest(tc.method, "http://"+couper.Addr()+tc.path, strings.NewReader(tc.body))
+			helper.Must(err)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			helper.Must(err)
+			helper.Must(res.Body.Close())
+
+			if res.StatusCode != tc.expStatus {
+				subT.Errorf("expected status %d, got: %d", tc.expStatus, res.StatusCode)
+			}
+
+			if tc.expStatus == http.StatusForbidden && res.Header.Get(errors.HeaderErrorCode) != fmt.Sprintf("%d - %q", errors.AuthorizationFailed, errors.AuthorizationFailed.Error()) {
+				subT.Errorf("expected error code %d, got: %q", errors.AuthorizationFailed, res.Header.Get(errors.HeaderErrorCode))
+			}
+		})
+	}
+}
+
+func TestHTTPServer_ServeHTTP_OAuth2Introspection(t *testing.T) {
+	helper := test.New(t)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Sub", req.Header.Get("X-Sub"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	idp := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/oauth2/introspect" || req.Method != http.MethodPost {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		helper.Must(req.ParseForm())
+		rw.Header().Set("Content-Type", "application/json")
+		if req.PostForm.Get("token") == "valid" {
+			_, _ = rw.Write([]byte(`{"active":true,"sub":"john","scope":"orders"}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"active":false}`))
+	}))
+	defer idp.Close()
+
+	confBytes := []byte(fmt.Sprintf(`
+server "introspection" {
+  api {
+    endpoint "/orders" {
+      access_control = ["intro"]
+      authorize = contains(req.ctx.intro.scope, "orders")
+      backend {
+        origin = %q
+        request_headers = {
+          x-sub = req.ctx.intro.sub
+        }
+      }
+    }
+  }
+}
+
+definitions {
+  backend "idp" {
+    origin = %q
+    path = "/oauth2/introspect"
+  }
+
+  oauth2_introspection "intro" {
+    backend = "idp"
+    header = "Authorization"
+    client_id = "couper"
+    client_secret = "s3cr3t"
+  }
+}
+`, origin.URL, idp.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, _ := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(nil)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	for _, tc := range []struct {
+		token     string
+		expStatus int
+		expSub    string
+	}{
+		{"valid", http.StatusNoContent, "john"},
+		{"revoked", http.StatusForbidden, ""},
+		{"", http.StatusUnauthorized, ""},
+	} {
+		req, err := http.NewRequest(http.MethodGet, "http://"+couper.Addr()+"/orders", nil)
+		helper.Must(err)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		helper.Must(err)
+		helper.Must(res.Body.Close())
+
+		if res.StatusCode != tc.expStatus {
+			t.Errorf("%q: expected status %d, got: %d", tc.token, tc.expStatus, res.StatusCode)
+		}
+		if sub := res.Header.Get("X-Sub"); sub != tc.expSub {
+			t.Errorf("%q: expected sub %q, got: %q", tc.token, tc.expSub, sub)
+		}
+	}
+}
+
+func TestHTTPServer_ServeHTTP_OIDC(t *testing.T) {
+	helper := test.New(t)
+
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	helper.Must(err)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Sub", req.Header.Get("X-Sub"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	type authRequest struct{ challenge, nonce string }
+	codes := make(map[string]authRequest)
+
+	var idp *httptest.Server
+	idp = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/.well-known/openid-configuration":
+			rw.Header().Set("Content-Type", "application/json")
+			helper.Must(json.NewEncoder(rw).Encode(map[string]string{
+				"authorization_endpoint": idp.URL + "/authorize",
+				"issuer":                 idp.URL,
+				"jwks_uri":               idp.URL + "/jwks",
+				"token_endpoint":         idp.URL + "/token",
+			}))
+		case "/authorize":
+			query := req.URL.Query()
+			if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" ||
+				query.Get("client_id") != "couper" || !strings.Contains(query.Get("scope"), "openid") {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			codes["code-1"] = authRequest{query.Get("code_challenge"), query.Get("nonce")}
+			http.Redirect(rw, req, query.Get("redirect_uri")+"?code=code-1&state="+url.QueryEscape(query.Get("state")), http.StatusFound)
+		case "/token":
+			helper.Must(req.ParseForm())
+			authReq, exist := codes[req.PostForm.Get("code")]
+			challenge := sha256.Sum256([]byte(req.PostForm.Get("code_verifier")))
+			if user, pass, ok := req.BasicAuth(); !exist || !ok || user != "couper" || pass != "s3cr3t" ||
+				base64.RawURLEncoding.EncodeToString(challenge[:]) != authReq.challenge {
+				rw.WriteHeader(http.StatusBadRequest)
+				_, _ = rw.Write([]byte(`{"error":"invalid_grant"}`))
+				return
+			}
+			delete(codes, req.PostForm.Get("code"))
+
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+				"aud":   "couper",
+				"exp":   time.Now().Add(time.Minute).Unix(),
+				"iss":   idp.URL,
+				"nonce": authReq.nonce,
+				"sub":   "john",
+			})
+			token.Header["kid"] = "idp-key"
+			idToken, err := token.SignedString(privKey)
+			helper.Must(err)
+
+			rw.Header().Set("Content-Type", "application/json")
+			helper.Must(json.NewEncoder(rw).Encode(map[string]string{"access_token": "opaque", "id_token": idToken}))
+		case "/jwks":
+			rw.Header().Set("Content-Type", "application/json")
+			helper.Must(json.NewEncoder(rw).Encode(map[string]interface{}{"keys": []map[string]string{{
+				"alg": "RS256",
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privKey.E)).Bytes()),
+				"kid": "idp-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(privKey.N.Bytes()),
+				"use": "sig",
+			}}}))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer idp.Close()
+
+	// the redirect_uri requires the listen port in advance
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	helper.Must(err)
+	listenPort := ln.Addr().(*net.TCPAddr).Port
+	helper.Must(ln.Close())
+
+	confBytes := []byte(fmt.Sprintf(`
+server "oidc" {
+  files {
+    document_root = "testdata/integration/vhosts/htdocs_01"
+    access_control = ["login"]
+  }
+
+  api {
+    endpoint "/me" {
+      access_control = ["login"]
+      backend {
+        origin = %q
+        request_headers = {
+          x-sub = req.ctx.login.sub
+        }
+      }
+    }
+  }
+}
+
+definitions {
+  oidc "login" {
+    configuration_url = "%s/.well-known/openid-configuration"
+    client_id = "couper"
+    client_secret = "s3cr3t"
+    redirect_uri = "http://127.0.0.1:%d/oidc/callback"
+    logout_path = "/oidc/logout"
+    session_secret = "a-session-secret-with-at-least-32-characters"
+  }
+}
+`, origin.URL, idp.URL, listenPort))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, _ := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(nil)
+	httpConf.ListenPort = listenPort
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	jar, err := cookiejar.New(nil)
+	helper.Must(err)
+	client := &http.Client{Jar: jar}
+
+	newRequest := func(path string, html bool) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d%s", listenPort, path), nil)
+		helper.Must(err)
+		if html {
+			req.Header.Set("Accept", "text/html,application/xhtml+xml")
+		}
+		return req
+	}
+
+	// api requests without a session are not redirected
+	res, err := client.Do(newRequest("/me", false))
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d without session, got: %d", http.StatusUnauthorized, res.StatusCode)
+	}
+
+	// the login is followed through the provider and back to the requested page
+	res, err = client.Do(newRequest("/index.html", true))
+	helper.Must(err)
+	b, err := ioutil.ReadAll(res.Body)
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+	if res.StatusCode != http.StatusOK || !bytes.Contains(b, []byte("<html")) {
+		t.Fatalf("expected the protected file after login, got: %d %s", res.StatusCode, string(b))
+	}
+	if res.Request.URL.Path != "/index.html" {
+		t.Errorf("expected a redirect to the initial path, got: %q", res.Request.URL.Path)
+	}
+
+	res, err = client.Do(newRequest("/me", false))
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+	if res.StatusCode != http.StatusNoContent || res.Header.Get("X-Sub") != "john" {
+		t.Errorf("expected the session claims, got: %d %q", res.StatusCode, res.Header.Get("X-Sub"))
+	}
+
+	// a callback with a foreign state must not start a session
+	res, err = http.DefaultClient.Do(newRequest("/oidc/callback?code=code-1&state=foreign", true))
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+	if res.StatusCode != http.StatusForbidden {
+		t.Errorf("expected status %d for a callback without state, got: %d", http.StatusForbidden, res.StatusCode)
+	}
+
+	// do not follow the logout redirect which would start a new login
+	noRedirectClient := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err = noRedirectClient.Do(newRequest("/oidc/logout", true))
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+	if res.StatusCode != http.StatusSeeOther {
+		t.Errorf("expected status %d for logout, got: %d", http.StatusSeeOther, res.StatusCode)
+	}
+
+	res, err = client.Do(newRequest("/me", false))
+	helper.Must(err)
+	helper.Must(res.Body.Close())
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d after logout, got: %d", http.StatusUnauthorized, res.StatusCode)
+	}
+}
+
+func TestHTTPServer_ServeHTTP_IPFilter(t *testing.T) {
+	helper := test.New(t)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("X-Origin-XFF", req.Header.Get("X-Forwarded-For"))
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	confBytes := []byte(fmt.Sprintf(`
+server "ip" {
+  api {
+    endpoint "/admin" {
+      access_control = ["office"]
+      backend {
+        origin = %q
+      }
+    }
+  }
+}
+
+definitions {
+  ip_filter "office" {
+    allow = ["203.0.113.0/24"]
+    deny = ["203.0.113.13"]
+  }
+}
+
+settings {
+  trusted_proxies = ["127.0.0.1", "10.0.0.0/8"]
+}
+`, origin.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, hook := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(conf)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	for _, tc := range []struct {
+		name      string
+		header    http.Header
+		expStatus int
+		expXFF    string
+		expIP     string
+	}{
+		{"forwarded for", http.Header{"X-Forwarded-For": {"203.0.113.7"}}, http.StatusNoContent, "203.0.113.7", "203.0.113.7"},
+		{"trusted hops", http.Header{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7, 10.1.2.3"}}, http.StatusNoContent, "198.51.100.1, 203.0.113.7", "203.0.113.7"},
+		{"spoofed", http.Header{"X-Forwarded-For": {"203.0.113.7, 198.51.100.1"}}, http.StatusForbidden, "", "198.51.100.1"},
+		{"denied", http.Header{"X-Forwarded-For": {"203.0.113.13"}}, http.StatusForbidden, "", "203.0.113.13"},
+		{"forwarded", http.Header{"Forwarded": {`for="203.0.113.8:4711";proto=https`}}, http.StatusNoContent, "203.0.113.8", "203.0.113.8"},
+		{"peer", http.Header{}, http.StatusForbidden, "", "127.0.0.1"},
+	} {
+		t.Run(tc.name, func(subT *testing.T) {
+			hook.Reset()
+
+			req, err := http.NewRequest(http.MethodGet, "http://"+couper.Addr()+"/admin", nil)
+			helper.Must(err)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			helper.Must(err)
+			helper.Must(res.Body.Close())
+
+			if res.StatusCode != tc.expStatus {
+				subT.Errorf("expected status %d, got: %d", tc.expStatus, res.StatusCode)
+			}
+			if xff := res.Header.Get("X-Origin-XFF"); xff != tc.expXFF {
+				subT.Errorf("expected X-Forwarded-For %q, got: %q", tc.expXFF, xff)
+			}
+
+			var clientIP interface{}
+			for _, entry := range hook.AllEntries() {
+				if entry.Data["type"] == "couper_access" {
+					clientIP = entry.Data["client_ip"]
+				}
+			}
+			if clientIP != tc.expIP {
+				subT.Errorf("expected client_ip %q, got: %v", tc.expIP, clientIP)
+			}
+		})
+	}
+}
+
+func TestHTTPServer_ServeHTTP_RateLimit(t *testing.T) {
+	helper := test.New(t)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	confBytes := []byte(fmt.Sprintf(`
+server "limited" {
+  rate_limit {
+    key = req.client_ip
+    period = "1h"
+    per_period = 4
+  }
+
+  api {
+    backend {
+      origin = %q
+    }
+
+    endpoint "/open" {}
+
+    endpoint "/strict" {
+      rate_limit {
+        algorithm = "sliding_window"
+        key = req.headers.x-user
+        period = "1h"
+        per_period = 1
+      }
+    }
+  }
+}
+`, origin.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, _ := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(conf)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	for _, tc := range []struct {
+		name         string
+		path         string
+		user         string
+		expStatus    int
+		expRemaining string
+	}{
+		{"strict", "/strict", "alice", http.StatusNoContent, "0"},
+		{"strict limited", "/strict", "alice", http.StatusTooManyRequests, "0"},
+		{"strict other user", "/strict", "bob", http.StatusNoContent, "0"},
+		{"open", "/open", "", http.StatusNoContent, "0"},
+		{"server limited", "/open", "", http.StatusTooManyRequests, "0"},
+		{"server limited strict", "/strict", "carol", http.StatusTooManyRequests, "0"},
+	} {
+		t.Run(tc.name, func(subT *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://"+couper.Addr()+tc.path, nil)
+			helper.Must(err)
+			if tc.user != "" {
+				req.Header.Set("X-User", tc.user)
+			}
+
+			res, err := http.DefaultClient.Do(req)
+			helper.Must(err)
+			helper.Must(res.Body.Close())
+
+			if res.StatusCode != tc.expStatus {
+				subT.Errorf("expected status %d, got: %d", tc.expStatus, res.StatusCode)
+			}
+			if remaining := res.Header.Get("RateLimit-Remaining"); remaining != tc.expRemaining {
+				subT.Errorf("expected RateLimit-Remaining %q, got: %q", tc.expRemaining, remaining)
+			}
+
+			if tc.expStatus != http.StatusTooManyRequests {
+				return
+			}
+			if res.Header.Get("Retry-After") == "" {
+				subT.Error("expected a Retry-After header")
+			}
+			if code := res.Header.Get(errors.HeaderErrorCode); code != `1005 - "Rate limit exceeded"` {
+				subT.Errorf("expected rate limit error code, got: %q", code)
+			}
+		})
+	}
+}
+
+func TestHTTPServer_ServeHTTP_RateLimit_AccessControl(t *testing.T) {
+	helper := test.New(t)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	confBytes := []byte(fmt.Sprintf(`
+server "limited" {
+  api {
+    backend {
+      origin = %q
+    }
+
+    endpoint "/protected" {
+      access_control = ["ba"]
+      rate_limit {
+        period = "1h"
+        per_period = 2
+      }
+    }
+
+    endpoint "/user" {
+      access_control = ["ba", "local"]
+      rate_limit {
+        key = req.ctx.local.client_ip
+        period = "1h"
+        per_period = 1
+      }
+    }
+  }
+}
+
+definitions {
+  basic_auth "ba" {
+    user = "user"
+    password = "secret"
+  }
+
+  ip_filter "local" {
+    allow = ["127.0.0.1"]
+  }
+}
+`, origin.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, _ := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(conf)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	for _, tc := range []struct {
+		name      string
+		path      string
+		password  string
+		expStatus int
+	}{
+		{"unauthorized", "/protected", "wrong", http.StatusUnauthorized},
+		{"authorized", "/protected", "secret", http.StatusNoContent},
+		{"unauthorized limited", "/protected", "wrong", http.StatusTooManyRequests},
+		// limits with access control data as key count authorized requests only
+		{"unauthorized user", "/user", "wrong", http.StatusUnauthorized},
+		{"authorized user", "/user", "secret", http.StatusNoContent},
+		{"authorized user limited", "/user", "secret", http.StatusTooManyRequests},
+	} {
+		t.Run(tc.name, func(subT *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://"+couper.Addr()+tc.path, nil)
+			helper.Must(err)
+			req.SetBasicAuth("user", tc.password)
+
+			res, err := http.DefaultClient.Do(req)
+			helper.Must(err)
+			helper.Must(res.Body.Close())
+
+			if res.StatusCode != tc.expStatus {
+				subT.Errorf("expected status %d, got: %d", tc.expStatus, res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestHTTPServer_ServeHTTP_Cache(t *testing.T) {
+	helper := test.New(t)
+
+	var calls int32
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		rw.Header().Set("Cache-Control", "max-age=60")
+		_, _ = fmt.Fprintf(rw, "%s %d", req.URL.Path, n)
+	}))
+	defer origin.Close()
+
+	confBytes := []byte(fmt.Sprintf(`
+server "cached" {
+  api {
+    backend {
+      origin = %q
+    }
+
+    endpoint "/api-backend" {}
+
+    endpoint "/api-backend/cached" {
+      cache {}
+    }
+
+    endpoint "/referenced" {
+      backend = "catalogue"
+    }
+
+    endpoint "/shared" {
+      backend = "catalogue"
+      path = "/referenced"
+    }
+
+    endpoint "/inline" {
+      cache {
+        key = "inline"
+      }
+      backend {
+        origin = %q
+      }
+    }
+  }
+}
+
+definitions {
+  backend "catalogue" {
+    origin = %q
+    cache {
+      max_entries = 10
+    }
+  }
+}
+`, origin.URL, origin.URL, origin.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, hook := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(conf)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	for _, tc := range []struct {
+		name      string
+		path      string
+		expStatus string
+		expBody   string
+	}{
+		{"api backend", "/api-backend", "", "/api-backend 1"},
+		{"api backend not cached", "/api-backend", "", "/api-backend 2"},
+		{"endpoint cache miss", "/api-backend/cached", "MISS", "/api-backend/cached 3"},
+		{"endpoint cache hit", "/api-backend/cached", "HIT", "/api-backend/cached 3"},
+		{"backend cache miss", "/referenced", "MISS", "/referenced 4"},
+		{"backend cache hit", "/referenced", "HIT", "/referenced 4"},
+		{"shared backend cache hit", "/shared", "HIT", "/referenced 4"},
+		{"key miss", "/inline?id=1", "MISS", "/inline 5"},
+		{"key hit", "/inline?id=2", "HIT", "/inline 5"},
+	} {
+		t.Run(tc.name, func(subT *testing.T) {
+			hook.Reset()
+
+			res, err := http.Get("http://" + couper.Addr() + tc.path)
+			helper.Must(err)
+			body, err := ioutil.ReadAll(res.Body)
+			helper.Must(err)
+			helper.Must(res.Body.Close())
+
+			if string(body) != tc.expBody {
+				subT.Errorf("expected body %q, got: %q", tc.expBody, string(body))
+			}
+			if status := res.Header.Get(logging.HeaderCacheStatus); status != tc.expStatus {
+				subT.Errorf("expected cache status %q, got: %q", tc.expStatus, status)
+			}
+
+			var logged interface{}
+			for _, entry := range hook.AllEntries() {
+				if entry.Data["type"] == "couper_access" {
+					logged = entry.Data["cache"]
+				}
+			}
+			if tc.expStatus != "" && logged != tc.expStatus {
+				subT.Errorf("expected logged cache status %q, got: %v", tc.expStatus, logged)
+			}
+		})
+	}
+}
+
+func TestHTTPServer_ServeHTTP_Compression(t *testing.T) {
+	helper := test.New(t)
+
+	payload := strings.Repeat(`{"product":"couper"}`, 100)
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(rw, payload)
+	}))
+	defer origin.Close()
+
+	docRoot, err := ioutil.TempDir("", "couper-compression")
+	helper.Must(err)
+	defer os.RemoveAll(docRoot)
+
+	gzipped := &bytes.Buffer{}
+	gw := gzip.NewWriter(gzipped)
+	_, err = io.WriteString(gw, "precompressed")
+	helper.Must(err)
+	helper.Must(gw.Close())
+	helper.Must(ioutil.WriteFile(filepath.Join(docRoot, "app.js"), []byte("plain"), 0644))
+	helper.Must(ioutil.WriteFile(filepath.Join(docRoot, "app.js.gz"), gzipped.Bytes(), 0644))
+
+	confBytes := []byte(fmt.Sprintf(`
+server "compressed" {
+  compression {
+    min_size = "1KiB"
+    types = ["application/json", "text/*"]
+  }
+
+  files {
+    document_root = %q
+  }
+
+  api {
+    base_path = "/api"
+
+    endpoint "/products" {
+      backend {
+        origin = %q
+      }
+    }
+  }
+}
+`, docRoot, origin.URL))
+
+	conf, err := config.LoadBytes(confBytes, "couper.hcl")
+	helper.Must(err)
+
+	log, _ := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(conf)
+	httpConf.ListenPort = 0 // random
+
+	srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+	helper.Must(err)
+
+	port := runtime.Port(httpConf.ListenPort)
+	couper := server.New(ctx, log.WithContext(ctx), httpConf, port, srvConf.PortOptions[port])
+	couper.Listen()
+	defer couper.Close()
+
+	for _, tc := range []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		expEncoding    string
+		expBody        string
+	}{
+		{"proxied brotli", "/api/products", "gzip, br", "br", payload},
+		{"proxied gzip", "/api/products", "gzip", "gzip", payload},
+		{"proxied identity", "/api/products", "", "", payload},
+		{"precompressed file", "/app.js", "gzip, br", "gzip", "precompressed"},
+		{"plain file", "/app.js", "", "", "plain"},
+	} {
+		t.Run(tc.name, func(subT *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "http://"+couper.Addr()+tc.path, nil)
+			helper.Must(err)
+			// a set Accept-Encoding header disables the transparent decompression of the client
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+
+			res, err := http.DefaultClient.Do(req)
+			helper.Must(err)
+			defer res.Body.Close()
+
+			if res.StatusCode != http.StatusOK {
+				subT.Fatalf("expected status %d, got: %d", http.StatusOK, res.StatusCode)
+			}
+			if encoding := res.Header.Get("Content-Encoding"); encoding != tc.expEncoding {
+				subT.Errorf("expected Content-Encoding %q, got: %q", tc.expEncoding, encoding)
+			}
+			if vary := res.Header.Get("Vary"); vary != "Accept-Encoding" {
+				subT.Errorf("expected Vary header, got: %q", vary)
+			}
+
+			var body io.Reader = res.Body
+			switch tc.expEncoding {
+			case "br":
+				body = brotli.NewReader(res.Body)
+			case "gzip":
+				body, err = gzip.NewReader(res.Body)
+				helper.Must(err)
+			}
+			b, err := ioutil.ReadAll(body)
+			helper.Must(err)
+			if string(b) != tc.expBody {
+				subT.Errorf("expected body %q, got: %q", tc.expBody, string(b))
+			}
+		})
+	}
+}
@@ -250,16 +250,23 @@ func (m *Mux) hasFileResponse(req *http.Request) (http.Handler, *server.Options,
 
 	route := node.Value.(*openapi3filter.Route)
 	fileHandler := route.Handler
-	if p, isProtected := fileHandler.(ac.ProtectedHandler); isProtected {
+	// unwrap the protected file handler for the lookup but keep the access control for the response
+	for {
+		p, isProtected := fileHandler.(ac.ProtectedHandler)
+		if !isProtected {
+			break
+		}
 		fileHandler = p.Child()
-		srvCtxOpts = p.Child().(server.Context).Options()
+	}
+	if c, ok := fileHandler.(server.Context); ok {
+		srvCtxOpts = c.Options()
 	}
 
 	if fh, ok := fileHandler.(handler.HasResponse); ok {
-		return fileHandler, srvCtxOpts, fh.HasResponse(req)
+		return route.Handler, srvCtxOpts, fh.HasResponse(req)
 	}
 
-	return fileHandler, srvCtxOpts, false
+	return route.Handler, srvCtxOpts, false
 }
 
 func unwrapServerOptions(suffix pathpattern.Suffix) *server.Options {
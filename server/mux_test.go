@@ -6,9 +6,12 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/avenga/couper/accesscontrol"
 	"github.com/avenga/couper/config/request"
 	"github.com/avenga/couper/config/runtime"
+	srvOptions "github.com/avenga/couper/config/runtime/server"
 	"github.com/avenga/couper/errors"
+	"github.com/avenga/couper/handler"
 	"github.com/avenga/couper/server"
 )
 
@@ -99,3 +102,53 @@ func TestMux_FindHandler_PathParamContext(t *testing.T) {
 		})
 	}
 }
+
+func TestMux_FindHandler_ProtectedFiles(t *testing.T) {
+	srvOpts := &srvOptions.Options{
+		FileErrTpl:   errors.DefaultHTML,
+		ServerErrTpl: errors.DefaultHTML,
+	}
+
+	files, err := handler.NewFile("/", "testdata/file_serving/htdocs", srvOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ba, err := accesscontrol.NewBasicAuth("ba", "user", "secret", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := server.NewMux(&runtime.MuxOptions{
+		FileRoutes: map[string]http.Handler{
+			"/": handler.NewAccessControl(files, errors.DefaultHTML, ba),
+		},
+	})
+
+	tests := []struct {
+		name      string
+		path      string
+		user      string
+		expStatus int
+	}{
+		{"existing file without credentials", "/robots.txt", "", http.StatusUnauthorized},
+		{"existing file with wrong credentials", "/robots.txt", "other", http.StatusUnauthorized},
+		{"existing file", "/robots.txt", "user", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, "secret")
+			}
+
+			rec := httptest.NewRecorder()
+			mux.FindHandler(req).ServeHTTP(rec, req)
+
+			if rec.Code != tt.expStatus {
+				subT.Errorf("Expected status %d, got: %d", tt.expStatus, rec.Code)
+			}
+		})
+	}
+}
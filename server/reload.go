@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/avenga/couper/config/runtime"
+)
+
+// Reload applies the given server configuration to the running servers and returns the new server list.
+// Servers of new ports are listening before the muxes of the existing servers get swapped,
+// servers of removed ports are drained afterwards. The running servers are left untouched on errors.
+func Reload(cmdCtx context.Context, log logrus.FieldLogger, conf *runtime.HTTPConfig, servers []*HTTPServer, srvConf *runtime.ServerConfiguration) (list []*HTTPServer, err error) {
+	running := make(map[runtime.Port]*HTTPServer)
+	for _, srv := range servers {
+		running[srv.port] = srv
+	}
+
+	states := make(map[runtime.Port]*muxState)
+	var added []*HTTPServer
+
+	defer func() {
+		// route configuration errors are panics
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("%v", rec)
+		}
+		if err != nil {
+			for _, srv := range added {
+				srv.drain()
+			}
+			list = nil
+		}
+	}()
+
+	for port, muxOpts := range srvConf.PortOptions {
+		srv, exist := running[port]
+		if !exist {
+			srv = New(cmdCtx, log, conf, port, muxOpts)
+			if err = srv.listen(); err != nil {
+				return nil, err
+			}
+			added = append(added, srv)
+			continue
+		}
+
+		if (muxOpts.TLS != nil) != srv.tls {
+			return nil, fmt.Errorf("port %d: switching between http and https requires a restart", port)
+		}
+		states[port] = srv.newMuxState(muxOpts)
+	}
+
+	for port, state := range states {
+		running[port].state.Store(state)
+		list = append(list, running[port])
+	}
+	list = append(list, added...)
+
+	for port, srv := range running {
+		if _, exist := srvConf.PortOptions[port]; exist {
+			continue
+		}
+		// the internal metrics server is not part of the server configuration
+		if conf.MetricsPort != 0 && int(port) == conf.MetricsPort {
+			list = append(list, srv)
+			continue
+		}
+		go srv.drain()
+	}
+
+	return list, nil
+}
+
+// drain stops listening and waits for the running requests of a removed port.
+func (s *HTTPServer) drain() {
+	close(s.drainCh)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.Timings.ShutdownTimeout)
+	defer cancel()
+
+	s.log.WithField("deadline", s.config.Timings.ShutdownTimeout.String()).
+		Warnf("draining removed port %s", s.port)
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.log.Error(err)
+	}
+}
@@ -0,0 +1,120 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/config/runtime"
+	"github.com/avenga/couper/internal/test"
+	"github.com/avenga/couper/server"
+)
+
+func TestReload(t *testing.T) {
+	helper := test.New(t)
+
+	origin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer origin.Close()
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	helper.Must(err)
+	extraPort := ln.Addr().(*net.TCPAddr).Port
+	helper.Must(ln.Close())
+
+	log, hook := logrustest.NewNullLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	httpConf := runtime.NewHTTPConfig(nil)
+	httpConf.ListenPort = 0 // random
+	httpConf.Timings.ShutdownTimeout = time.Second
+
+	newServerConfiguration := func(endpoint string, hosts ...string) *runtime.ServerConfiguration {
+		hostList := `"*"`
+		for _, h := range hosts {
+			hostList += fmt.Sprintf(", %q", h)
+		}
+		conf, err := config.LoadBytes([]byte(fmt.Sprintf(`
+server "reload" {
+  hosts = [%s]
+  api {
+    endpoint %q {
+      backend {
+        origin = %q
+      }
+    }
+  }
+}
+`, hostList, endpoint, origin.URL)), "couper.hcl")
+		helper.Must(err)
+
+		srvConf, err := runtime.NewServerConfiguration(conf, httpConf, log.WithContext(nil))
+		helper.Must(err)
+		return srvConf
+	}
+
+	srvConf := newServerConfiguration("/a")
+	servers, _ := server.NewServerList(ctx, log, httpConf, srvConf)
+	if len(servers) != 1 {
+		t.Fatalf("expected one server, got: %d", len(servers))
+	}
+	couper := servers[0]
+	couper.Listen()
+	defer couper.Close()
+
+	expectStatus := func(addr, path string, status int) {
+		t.Helper()
+		res, err := http.Get("http://" + addr + path)
+		helper.Must(err)
+		helper.Must(res.Body.Close())
+		if res.StatusCode != status {
+			t.Errorf("%s%s: expected status %d, got: %d", addr, path, status, res.StatusCode)
+		}
+	}
+
+	expectStatus(couper.Addr(), "/a", http.StatusNoContent)
+
+	newConf := newServerConfiguration("/b", fmt.Sprintf("*:%d", extraPort))
+	servers, err = server.Reload(ctx, log, httpConf, servers, newConf)
+	helper.Must(err)
+
+	if len(servers) != 2 {
+		t.Fatalf("expected two servers after reload, got: %d", len(servers))
+	}
+
+	expectStatus(couper.Addr(), "/b", http.StatusNoContent)
+	expectStatus(couper.Addr(), "/a", http.StatusNotFound)
+	expectStatus(fmt.Sprintf("127.0.0.1:%d", extraPort), "/b", http.StatusNoContent)
+
+	hook.Reset()
+	servers, err = server.Reload(ctx, log, httpConf, servers, srvConf)
+	helper.Must(err)
+
+	if len(servers) != 1 || servers[0] != couper {
+		t.Fatalf("expected the initial server only, got: %d", len(servers))
+	}
+
+	time.Sleep(time.Millisecond * 100) // drain
+	if _, err = http.Get(fmt.Sprintf("http://127.0.0.1:%d/b", extraPort)); err == nil {
+		t.Error("expected the removed port to be closed")
+	}
+	expectStatus(couper.Addr(), "/a", http.StatusNoContent)
+
+	diff := runtime.NewDiff(newConf, srvConf)
+	if len(diff.RemovedPorts) != 1 || int(diff.RemovedPorts[0]) != extraPort {
+		t.Errorf("expected removed port %d, got: %v", extraPort, diff.RemovedPorts)
+	}
+	if len(diff.AddedRoutes) != 1 || diff.AddedRoutes[0] != "0:/a" {
+		t.Errorf("expected added route 0:/a, got: %v", diff.AddedRoutes)
+	}
+}
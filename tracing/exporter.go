@@ -0,0 +1,275 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/avenga/couper/config"
+)
+
+// Exporter names of the tracing configuration.
+const (
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+const otlpTimeout = time.Second * 10
+
+// Exporter sends finished spans to a trace backend.
+type Exporter interface {
+	Export(serviceName string, spans []*Span) error
+	Close() error
+}
+
+// NewExporter creates the configured exporter.
+func NewExporter(conf *config.Tracing) (Exporter, error) {
+	switch conf.Exporter {
+	case ExporterOTLP:
+		if conf.Endpoint == "" {
+			return nil, fmt.Errorf("the otlp exporter requires an endpoint")
+		}
+		u, err := url.Parse(conf.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid endpoint: %q", conf.Endpoint)
+		}
+		return NewOTLPExporter(conf.Endpoint, conf.Headers), nil
+	case ExporterStdout:
+		return NewWriterExporter(os.Stdout), nil
+	case ExporterFile:
+		if conf.File == "" {
+			return nil, fmt.Errorf("the file exporter requires a file")
+		}
+		f, err := os.OpenFile(conf.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return NewWriterExporter(f), nil
+	default:
+		return nil, fmt.Errorf("unsupported exporter: %q", conf.Exporter)
+	}
+}
+
+// OTLPExporter sends spans as OTLP/HTTP JSON requests to a collector.
+type OTLPExporter struct {
+	client   *http.Client
+	endpoint string
+	headers  map[string]string
+}
+
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{
+		client:   &http.Client{Timeout: otlpTimeout},
+		endpoint: endpoint,
+		headers:  headers,
+	}
+}
+
+func (e *OTLPExporter) Export(serviceName string, spans []*Span) error {
+	b, err := json.Marshal(newOTLPRequest(serviceName, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected collector status code: %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Close() error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// WriterExporter writes one OTLP JSON request per line for local debugging.
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+func (e *WriterExporter) Export(serviceName string, spans []*Span) error {
+	b, err := json.Marshal(newOTLPRequest(serviceName, spans))
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.w.Write(append(b, '\n'))
+	return err
+}
+
+func (e *WriterExporter) Close() error {
+	if c, ok := e.w.(io.Closer); ok && e.w != os.Stdout {
+		return c.Close()
+	}
+	return nil
+}
+
+// OTLP/JSON representation, see https://github.com/open-telemetry/opentelemetry-proto
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Kind              SpanKind       `json:"kind"`
+	Name              string         `json:"name"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	SpanID            string         `json:"spanId"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	Status            otlpStatus     `json:"status"`
+	TraceID           string         `json:"traceId"`
+	TraceState        string         `json:"traceState,omitempty"`
+}
+
+type otlpEvent struct {
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	Name         string         `json:"name"`
+	TimeUnixNano string         `json:"timeUnixNano"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func newOTLPRequest(serviceName string, spans []*Span) *otlpRequest {
+	list := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		list = append(list, newOTLPSpan(s))
+	}
+
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: newOTLPAttributes(map[string]interface{}{
+			"service.name": serviceName,
+		})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: defaultServiceName},
+			Spans: list,
+		}},
+	}}}
+}
+
+func newOTLPSpan(s *Span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := otlpSpan{
+		Attributes:        newOTLPAttributes(s.attributes),
+		EndTimeUnixNano:   unixNano(s.end),
+		Kind:              s.kind,
+		Name:              s.name,
+		SpanID:            s.context.SpanID.String(),
+		StartTimeUnixNano: unixNano(s.start),
+		Status:            otlpStatus{Code: s.status, Message: s.statusMessage},
+		TraceID:           s.context.TraceID.String(),
+		TraceState:        s.context.TraceState,
+	}
+
+	if s.parent.IsValid() {
+		span.ParentSpanID = s.parent.String()
+	}
+
+	for _, e := range s.events {
+		span.Events = append(span.Events, otlpEvent{
+			Attributes:   newOTLPAttributes(e.Attributes),
+			Name:         e.Name,
+			TimeUnixNano: unixNano(e.Time),
+		})
+	}
+	return span
+}
+
+func newOTLPAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	list := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		var value map[string]interface{}
+		switch v := attributes[k].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		list = append(list, otlpKeyValue{Key: k, Value: value})
+	}
+	return list
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
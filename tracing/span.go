@@ -0,0 +1,174 @@
+package tracing
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+type SpanKind uint8
+
+// Span kinds as defined by OpenTelemetry.
+const (
+	KindInternal SpanKind = iota + 1
+	KindServer
+	KindClient
+)
+
+type StatusCode uint8
+
+// Span status codes as defined by OpenTelemetry.
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+type spanContextKey struct{}
+
+// Event is a timestamped annotation of a span.
+type Event struct {
+	Attributes map[string]interface{}
+	Name       string
+	Time       time.Time
+}
+
+// Span represents a single operation within a trace.
+// All methods are safe to be called on a nil Span which is returned if tracing is disabled.
+type Span struct {
+	context SpanContext
+	kind    SpanKind
+	name    string
+	parent  SpanID
+	start   time.Time
+	tracer  *Tracer
+
+	mu            sync.Mutex
+	attributes    map[string]interface{}
+	end           time.Time
+	ended         bool
+	events        []Event
+	status        StatusCode
+	statusMessage string
+}
+
+// SpanFromContext returns the current span of the given context or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// StartSpan starts a child span of the current span within the given context.
+// Returns a nil span if the context has no span.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.start(ctx, name, kind, parent.context, false)
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+// SetName replaces the initial span name, e.g. with the matched route.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+func (s *Span) AddEvent(name string, attributes map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.events = append(s.events, Event{Attributes: attributes, Name: name, Time: time.Now()})
+	s.mu.Unlock()
+}
+
+func (s *Span) SetStatus(code StatusCode, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status, s.statusMessage = code, message
+	s.mu.Unlock()
+}
+
+// End completes the span and hands it over to the exporter if the trace is sampled.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.context.IsSampled() {
+		s.tracer.enqueue(s)
+	}
+}
+
+// ClientTrace records the phases of an outgoing request as span events.
+func (s *Span) ClientTrace() *httptrace.ClientTrace {
+	if s == nil {
+		return &httptrace.ClientTrace{}
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			s.AddEvent("dns_start", map[string]interface{}{"host": info.Host})
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			s.AddEvent("dns_done", nil)
+		},
+		ConnectStart: func(_, addr string) {
+			s.AddEvent("connect_start", map[string]interface{}{"addr": addr})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			s.AddEvent("connect_done", errorAttribute(err))
+		},
+		TLSHandshakeStart: func() {
+			s.AddEvent("tls_handshake_start", nil)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			s.AddEvent("tls_handshake_done", errorAttribute(err))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			s.AddEvent("got_conn", map[string]interface{}{"reused": info.Reused})
+		},
+		GotFirstResponseByte: func() {
+			s.AddEvent("first_response_byte", nil)
+		},
+	}
+}
+
+func errorAttribute(err error) map[string]interface{} {
+	if err == nil {
+		return nil
+	}
+	return map[string]interface{}{"error": err.Error()}
+}
@@ -0,0 +1,77 @@
+package tracing_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/avenga/couper/tracing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+		sampled bool
+	}{
+		{"valid", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", false, false},
+		{"future version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, true},
+		{"version 00 with extra field", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", true, false},
+		{"zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", true, false},
+		{"upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", true, false},
+		{"short trace id", "00-4bf92f3577b34da6-00f067aa0ba902b7-01", true, false},
+		{"empty", "", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(subT *testing.T) {
+			sc, err := tracing.ParseTraceparent(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					subT.Errorf("Expected an error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				subT.Fatal(err)
+			}
+			if sc.IsSampled() != tt.sampled {
+				subT.Errorf("Expected sampled: %v", tt.sampled)
+			}
+			if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" {
+				subT.Errorf("Unexpected span context: %s", sc.Traceparent())
+			}
+		})
+	}
+}
+
+func TestInjectExtract(t *testing.T) {
+	header := http.Header{}
+	header.Set(tracing.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Add(tracing.HeaderTracestate, "a=1")
+	header.Add(tracing.HeaderTracestate, "b=2")
+
+	sc, ok := tracing.Extract(header)
+	if !ok {
+		t.Fatal("Expected a span context")
+	}
+	if sc.TraceState != "a=1,b=2" {
+		t.Errorf("Expected combined tracestate, got: %q", sc.TraceState)
+	}
+
+	out := http.Header{}
+	tracing.Inject(out, sc)
+	if out.Get(tracing.HeaderTraceparent) != header.Get(tracing.HeaderTraceparent) {
+		t.Errorf("Expected traceparent %q, got: %q", header.Get(tracing.HeaderTraceparent), out.Get(tracing.HeaderTraceparent))
+	}
+	if out.Get(tracing.HeaderTracestate) != "a=1,b=2" {
+		t.Errorf("Expected tracestate, got: %q", out.Get(tracing.HeaderTracestate))
+	}
+
+	if _, ok = tracing.Extract(http.Header{}); ok {
+		t.Error("Expected no span context without traceparent")
+	}
+}
@@ -0,0 +1,190 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/avenga/couper/config"
+)
+
+const (
+	defaultServiceName = "couper"
+
+	batchSize     = 512
+	batchInterval = time.Second * 5
+	queueSize     = 2048
+)
+
+// Tracer creates spans and exports the sampled ones in batches.
+// A nil Tracer is valid and creates no spans.
+type Tracer struct {
+	exporter    Exporter
+	log         logrus.FieldLogger
+	sampleBound uint64
+	sampleAll   bool
+	serviceName string
+
+	closeOnce sync.Once
+	done      chan struct{}
+	queue     chan *Span
+	stopped   chan struct{}
+}
+
+// New creates a tracer for the given tracing configuration. Returns nil if tracing is not configured.
+func New(conf *config.Tracing, log logrus.FieldLogger) (*Tracer, error) {
+	if conf == nil {
+		return nil, nil
+	}
+
+	exporter, err := NewExporter(conf)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %v", err)
+	}
+
+	ratio := 1.0
+	if conf.SampleRatio != nil {
+		ratio = *conf.SampleRatio
+	}
+	if ratio < 0 || ratio > 1 {
+		return nil, fmt.Errorf("tracing: sample_ratio must be between 0 and 1")
+	}
+
+	return NewTracer(exporter, conf.ServiceName, ratio, log), nil
+}
+
+// NewTracer creates a tracer which samples the given ratio of new traces.
+func NewTracer(exporter Exporter, serviceName string, sampleRatio float64, log logrus.FieldLogger) *Tracer {
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	t := &Tracer{
+		done:        make(chan struct{}),
+		exporter:    exporter,
+		log:         log,
+		queue:       make(chan *Span, queueSize),
+		sampleAll:   sampleRatio >= 1,
+		sampleBound: uint64(sampleRatio * math.MaxUint64),
+		serviceName: serviceName,
+		stopped:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Start starts a server span for the given incoming request header which may contain a remote parent.
+func (t *Tracer) Start(ctx context.Context, name string, header http.Header) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	parent, _ := Extract(header)
+	return t.start(ctx, name, KindServer, parent, true)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind SpanKind, parent SpanContext, remote bool) (context.Context, *Span) {
+	sc := SpanContext{SpanID: newSpanID()}
+	if parent.IsValid() {
+		sc.TraceID, sc.Flags, sc.TraceState = parent.TraceID, parent.Flags, parent.TraceState
+	} else {
+		sc.TraceID = newTraceID()
+		if t.sample(sc.TraceID) {
+			sc.Flags = flagSampled
+		}
+	}
+
+	span := &Span{
+		attributes: make(map[string]interface{}),
+		context:    sc,
+		kind:       kind,
+		name:       name,
+		parent:     parent.SpanID,
+		start:      time.Now(),
+		tracer:     t,
+	}
+
+	if remote && parent.IsValid() {
+		span.attributes["couper.remote_parent"] = true
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// sample decides about new traces by their random trace id.
+func (t *Tracer) sample(id TraceID) bool {
+	if t.sampleAll {
+		return true
+	}
+	return binary.BigEndian.Uint64(id[8:]) < t.sampleBound
+}
+
+func (t *Tracer) enqueue(s *Span) {
+	select {
+	case <-t.done:
+	case t.queue <- s:
+	default:
+		// the queue is full, drop the span instead of blocking the request
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(batchInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(t.serviceName, batch); err != nil && t.log != nil {
+			t.log.WithField("spans", len(batch)).Errorf("tracing: export failed: %v", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case s := <-t.queue:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.done:
+			for {
+				select {
+				case s := <-t.queue:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Shutdown exports the remaining spans and stops the tracer.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+	select {
+	case <-t.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Close()
+}
@@ -0,0 +1,165 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/avenga/couper/config"
+	"github.com/avenga/couper/tracing"
+)
+
+type otlpSpan struct {
+	Events []struct {
+		Name string `json:"name"`
+	} `json:"events"`
+	Kind         int    `json:"kind"`
+	Name         string `json:"name"`
+	ParentSpanID string `json:"parentSpanId"`
+	SpanID       string `json:"spanId"`
+	TraceID      string `json:"traceId"`
+}
+
+func decodeSpans(t *testing.T, b []byte) []otlpSpan {
+	var spans []otlpSpan
+	for _, line := range bytes.Split(bytes.TrimSpace(b), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []otlpSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal(line, &req); err != nil {
+			t.Fatal(err)
+		}
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func TestTracer_Start(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(buf), "test", 1, nil)
+
+	header := http.Header{}
+	header.Set(tracing.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, server := tracer.Start(context.Background(), "GET", header)
+	_, client := tracing.StartSpan(ctx, "backend", tracing.KindClient)
+	client.AddEvent("got_conn", nil)
+	client.End()
+	server.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := decodeSpans(t, buf.Bytes())
+	if len(spans) != 2 {
+		t.Fatalf("Expected two spans, got: %d", len(spans))
+	}
+
+	backend, srv := spans[0], spans[1]
+	if srv.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || backend.TraceID != srv.TraceID {
+		t.Errorf("Expected the remote trace id, got: %q, %q", srv.TraceID, backend.TraceID)
+	}
+	if srv.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the remote parent span id, got: %q", srv.ParentSpanID)
+	}
+	if backend.ParentSpanID != srv.SpanID {
+		t.Errorf("Expected the server span as parent, got: %q", backend.ParentSpanID)
+	}
+	if srv.Kind != int(tracing.KindServer) || backend.Kind != int(tracing.KindClient) {
+		t.Errorf("Unexpected span kinds: %d, %d", srv.Kind, backend.Kind)
+	}
+	if len(backend.Events) != 1 || backend.Events[0].Name != "got_conn" {
+		t.Errorf("Expected a got_conn event, got: %#v", backend.Events)
+	}
+}
+
+func TestTracer_Sampling(t *testing.T) {
+	buf := &bytes.Buffer{}
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(buf), "test", 0, nil)
+
+	_, span := tracer.Start(context.Background(), "GET", http.Header{})
+	if !span.SpanContext().IsValid() || span.SpanContext().IsSampled() {
+		t.Errorf("Expected a valid and unsampled span context, got: %s", span.SpanContext().Traceparent())
+	}
+	span.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("Expected no exported spans, got: %s", buf.String())
+	}
+
+	// disabled tracing
+	var disabled *tracing.Tracer
+	ctx, span := disabled.Start(context.Background(), "GET", http.Header{})
+	if span != nil || tracing.SpanFromContext(ctx) != nil {
+		t.Error("Expected no span")
+	}
+	span.SetAttribute("key", "value")
+	span.End()
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		buf := &bytes.Buffer{}
+		_, _ = buf.ReadFrom(req.Body)
+		body = buf.Bytes()
+	}))
+	defer collector.Close()
+
+	tracer, err := tracing.New(&config.Tracing{
+		Endpoint: collector.URL + "/v1/traces",
+		Exporter: tracing.ExporterOTLP,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := tracer.Start(context.Background(), "GET /", http.Header{})
+	span.End()
+
+	if err = tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(body), `"stringValue":"couper"`) {
+		t.Errorf("Expected the default service name, got: %s", body)
+	}
+	if spans := decodeSpans(t, body); len(spans) != 1 || spans[0].Name != "GET /" {
+		t.Errorf("Expected one exported span, got: %s", body)
+	}
+
+	for _, conf := range []*config.Tracing{
+		{Exporter: "zipkin"},
+		{Exporter: tracing.ExporterOTLP},
+		{Exporter: tracing.ExporterOTLP, Endpoint: "collector:4318"},
+		{Exporter: tracing.ExporterFile},
+	} {
+		if _, err = tracing.New(conf, nil); err == nil {
+			t.Errorf("Expected an error for %#v", conf)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"TLS1.0": tls.VersionTLS10,
+	"TLS1.1": tls.VersionTLS11,
+	"TLS1.2": tls.VersionTLS12,
+	"TLS1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion maps the configured version name like "TLS1.2" to its tls package constant.
+// An empty version results in zero which lets the tls package choose its default.
+func ParseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+
+	// allow both notations: TLS1.2 and TLSv1.2
+	v, ok := tlsVersions[strings.Replace(strings.ToUpper(version), "V", "", 1)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported tls version: %q", version)
+	}
+	return v, nil
+}
+
+// ParseCipherSuites maps the given IANA cipher suite names to their tls package ids.
+// Insecure cipher suites are rejected.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		id, ok := suites[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported cipher suite: %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}